@@ -0,0 +1,142 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 账户（钱包）的数据库操作
+
+const accountColumns = "id, name, kind, opening_balance, currency, archived, created_at, updated_at"
+
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var a models.Account
+	var updatedAt sql.NullString
+	if err := row.Scan(&a.ID, &a.Name, &a.Kind, &a.OpeningBalance, &a.Currency, &a.Archived, &a.CreatedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	a.UpdatedAt = updatedAt.String
+	return &a, nil
+}
+
+// CreateAccount 新增账户，返回插入的 ID
+func CreateAccount(userDB *sql.DB, account *models.Account) (int64, error) {
+	insertSQL := "INSERT INTO accounts (name, kind, opening_balance, currency, archived) VALUES (?, ?, ?, ?, ?)"
+	result, err := userDB.Exec(insertSQL, account.Name, account.Kind, account.OpeningBalance, account.Currency, account.Archived)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetAccounts 返回所有账户（includeArchived 为 false 时不含已归档账户）
+func GetAccounts(userDB *sql.DB, includeArchived bool) ([]models.Account, error) {
+	querySQL := "SELECT " + accountColumns + " FROM accounts"
+	if !includeArchived {
+		querySQL += " WHERE archived = 0"
+	}
+	querySQL += " ORDER BY archived, id"
+	rows, err := userDB.Query(querySQL)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		a, err := scanAccount(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		accounts = append(accounts, *a)
+	}
+	return accounts, nil
+}
+
+// GetAccountByID 返回账户，不存在时返回 ErrAccountNotFound
+func GetAccountByID(db DBTX, accountID int64) (*models.Account, error) {
+	a, err := scanAccount(db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE id = ?", accountID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrAccountNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return a, nil
+}
+
+// UpdateAccount 更新账户（nil 表示不更新该字段）
+func UpdateAccount(userDB *sql.DB, accountID int64, name *string, kind *string, openingBalance *int64, currency *string, archived *bool) error {
+	var queryParts []string
+	var args []interface{}
+
+	if name != nil {
+		queryParts = append(queryParts, "name = ?")
+		args = append(args, *name)
+	}
+	if kind != nil {
+		queryParts = append(queryParts, "kind = ?")
+		args = append(args, *kind)
+	}
+	if openingBalance != nil {
+		queryParts = append(queryParts, "opening_balance = ?")
+		args = append(args, *openingBalance)
+	}
+	if currency != nil {
+		queryParts = append(queryParts, "currency = ?")
+		args = append(args, *currency)
+	}
+	if archived != nil {
+		queryParts = append(queryParts, "archived = ?")
+		args = append(args, *archived)
+	}
+	if len(queryParts) == 0 {
+		return nil
+	}
+	queryParts = append(queryParts, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, accountID)
+
+	query := "UPDATE accounts SET " + strings.Join(queryParts, ", ") + " WHERE id = ?"
+	result, err := userDB.Exec(query, args...)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrAccountNotFound
+	}
+	return nil
+}
+
+// DeleteAccount 删除账户；账户下仍有账单时拒绝删除（避免余额被悄悄改变），应改为归档
+func DeleteAccount(userDB *sql.DB, accountID int64) error {
+	var count int
+	if err := userDB.QueryRow("SELECT COUNT(*) FROM transactions WHERE account_id = ?", accountID).Scan(&count); err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if count > 0 {
+		return utils.ErrAccountInUse
+	}
+	result, err := userDB.Exec("DELETE FROM accounts WHERE id = ?", accountID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrAccountNotFound
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// 账单附件的数据库操作
+// 附件文件保存在每个用户自己的附件目录中（与用户数据库目录并列），数据库只记录元数据。
+
+const (
+	AttachmentsDir = "database_files/attachments"
+)
+
+// UserAttachmentDir 返回用户附件目录的路径
+func UserAttachmentDir(userID int64) string {
+	return filepath.Join(AttachmentsDir, fmt.Sprintf("user_%d", userID))
+}
+
+const attachmentColumns = "id, transaction_id, file_name, stored_name, COALESCE(thumbnail_name, ''), mime_type, size, created_at"
+
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	var a models.Attachment
+	if err := row.Scan(&a.ID, &a.TransactionID, &a.FileName, &a.StoredName, &a.ThumbnailName,
+		&a.MimeType, &a.Size, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	a.HasThumbnail = a.ThumbnailName != ""
+	return &a, nil
+}
+
+// CreateAttachment 记录一个已保存到附件目录的文件，返回插入的 ID
+func CreateAttachment(userDB DBTX, a *models.Attachment) (int64, error) {
+	result, err := userDB.Exec(`
+INSERT INTO attachments (transaction_id, file_name, stored_name, thumbnail_name, mime_type, size)
+VALUES (?, ?, ?, NULLIF(?, ''), ?, ?)`, a.TransactionID, a.FileName, a.StoredName, a.ThumbnailName, a.MimeType, a.Size)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetAttachments 按上传顺序返回账单的全部附件
+func GetAttachments(userDB DBTX, transactionID int64) ([]models.Attachment, error) {
+	rows, err := userDB.Query("SELECT "+attachmentColumns+" FROM attachments WHERE transaction_id = ? ORDER BY id", transactionID)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		attachments = append(attachments, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return attachments, nil
+}
+
+// GetAttachmentByID 返回附件，不存在时返回 ErrAttachmentNotFound
+func GetAttachmentByID(userDB DBTX, attachmentID int64) (*models.Attachment, error) {
+	a, err := scanAttachment(userDB.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", attachmentID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrAttachmentNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return a, nil
+}
+
+// DeleteAttachment 删除附件记录（文件由调用方删除）
+func DeleteAttachment(userDB DBTX, attachmentID int64) error {
+	result, err := userDB.Exec("DELETE FROM attachments WHERE id = ?", attachmentID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrAttachmentNotFound
+	}
+	return nil
+}
+
+// GetAttachmentFileNames 返回数据库中仍被引用的全部附件文件名（含缩略图）
+func GetAttachmentFileNames(userDB DBTX) (map[string]bool, error) {
+	rows, err := userDB.Query(`
+SELECT stored_name FROM attachments
+UNION ALL
+SELECT thumbnail_name FROM attachments WHERE thumbnail_name IS NOT NULL`)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		names[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return names, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"AccountingAssistant/utils"
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+)
+
+// 用户数据库的备份与恢复
+// 备份按表逐行读出全部数据（新增的表无需额外处理）；恢复时先新建一个备份时版本的数据库文件，
+// 写入数据后迁移到当前版本，最后替换用户原来的数据库文件。
+
+// sqliteTimeLayout DATETIME 列的默认文本格式（CURRENT_TIMESTAMP）
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// UserDBTables 返回数据库中的所有数据表（不含 SQLite 内部表），按名称排序
+func UserDBTables(db DBTX) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return tables, nil
+}
+
+// TableColumns 返回表的列名，表不存在时返回空
+func TableColumns(db DBTX, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		columns = append(columns, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return columns, nil
+}
+
+// GetUserDBVersion 返回数据库的结构版本（PRAGMA user_version）
+func GetUserDBVersion(db DBTX) (int, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return version, nil
+}
+
+// EachTableRow 按 rowid 顺序逐行读取一张表，values 与 columns 一一对应（int64、float64、string、[]byte 或 nil）。
+// 驱动会把 DATETIME 列解析为 time.Time，这里还原为 SQLite 的文本格式。
+func EachTableRow(db DBTX, table string, fn func(columns []string, values []interface{}) error) error {
+	rows, err := db.Query("SELECT * FROM " + quoteIdentifier(table) + " ORDER BY rowid")
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return utils.WrapError(utils.ErrReadFailed, err)
+	}
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return utils.WrapError(utils.ErrReadFailed, err)
+		}
+		for i, v := range values {
+			if t, ok := v.(time.Time); ok {
+				values[i] = t.UTC().Format(sqliteTimeLayout)
+			}
+		}
+		if err := fn(columns, values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return nil
+}
+
+// InsertTableRow 向表中写入一行（表名、列名由调用方校验）
+func InsertTableRow(db DBTX, table string, columns []string, values []interface{}) error {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdentifier(c)
+	}
+	insertSQL := "INSERT INTO " + quoteIdentifier(table) + " (" + strings.Join(quoted, ", ") +
+		") VALUES (" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	if _, err := db.Exec(insertSQL, values...); err != nil {
+		return utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return nil
+}
+
+// CreateRestoreDatabase 新建一个用于恢复的数据库文件（与用户数据库同目录），表结构为 schemaVersion 版本。
+// 返回打开的数据库与文件路径，调用方写入数据后用 ReplaceUserDatabase 替换用户数据库。
+func CreateRestoreDatabase(userID int64, schemaVersion int) (*sql.DB, string, error) {
+	if schemaVersion < 0 || schemaVersion > len(userDBMigrations) {
+		return nil, "", utils.ErrBackupUnsupported
+	}
+	if err := os.MkdirAll(UsersDataDir, 0755); err != nil {
+		return nil, "", utils.WrapError(utils.ErrCreateDirFailed, err)
+	}
+	path := UserDBPath(userID) + ".restore"
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, "", utils.WrapError(utils.ErrCreateDirFailed, err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, "", utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	if err := createBaseTables(db); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, "", err
+	}
+	if err := migrateUserDBTo(db, schemaVersion); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, "", err
+	}
+	return db, path, nil
+}
+
+// ReplaceUserDatabase 把恢复好的数据库迁移到当前版本并替换用户数据库。
+// 原数据库保留为 user_%d.db.bak（只保留最近一次恢复前的版本）。
+func ReplaceUserDatabase(userID int64, restoreDB *sql.DB, restorePath string) error {
+	if err := migrateUserDB(restoreDB); err != nil {
+		return err
+	}
+	if err := restoreDB.Close(); err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	path := UserDBPath(userID)
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+	}
+	if err := os.Rename(restorePath, path); err != nil {
+		os.Rename(path+".bak", path) // 放回原数据库
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	migratedUsers.Store(userID, true)
+	return nil
+}
+
+// quoteIdentifier 给表名、列名加上双引号
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 预算的数据库操作
+
+const budgetColumns = `b.id, COALESCE(b.category_id, 0), COALESCE(c.name, ''), b.period, b.amount, b.rollover,
+	b.start_date, b.created_at, b.updated_at`
+
+const budgetJoins = " FROM budgets b LEFT JOIN categories c ON b.category_id = c.id AND c.deleted_at IS NULL"
+
+// 各种预算周期在 SQL 中对应的"周期开始时间"表达式，与 services 中计算的周期开始时间格式一致
+// 周从周一开始：先前进到本周日（'weekday 0'），再退回 6 天
+var budgetPeriodKeys = map[string]string{
+	"week":  "datetime(date(occurred_at, 'weekday 0', '-6 days'))",
+	"month": "strftime('%Y-%m-01 00:00:00', occurred_at)",
+	"year":  "strftime('%Y-01-01 00:00:00', occurred_at)",
+}
+
+func scanBudget(row rowScanner) (*models.Budget, error) {
+	var b models.Budget
+	var updatedAt sql.NullString
+	if err := row.Scan(&b.ID, &b.CategoryID, &b.CategoryName, &b.Period, &b.Amount, &b.Rollover,
+		&b.StartDate, &b.CreatedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	b.UpdatedAt = updatedAt.String
+	return &b, nil
+}
+
+// CreateBudget 新增预算，返回插入的 ID；同一类别同一周期已有预算时返回 ErrBudgetExists
+func CreateBudget(userDB *sql.DB, b *models.Budget) (int64, error) {
+	var count int
+	if err := userDB.QueryRow("SELECT COUNT(*) FROM budgets WHERE COALESCE(category_id, 0) = ? AND period = ?",
+		b.CategoryID, b.Period).Scan(&count); err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if count > 0 {
+		return 0, utils.ErrBudgetExists
+	}
+	insertSQL := "INSERT INTO budgets (category_id, period, amount, rollover, start_date) VALUES (?, ?, ?, ?, ?)"
+	result, err := userDB.Exec(insertSQL, nullableID(b.CategoryID), b.Period, b.Amount, b.Rollover, b.StartDate)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetBudgets 返回所有预算（period 非空时只返回该周期的预算）；类别在回收站中的预算不返回
+func GetBudgets(userDB *sql.DB, period string) ([]models.Budget, error) {
+	querySQL := "SELECT " + budgetColumns + budgetJoins + " WHERE (b.category_id IS NULL OR c.id IS NOT NULL)"
+	var args []interface{}
+	if period != "" {
+		querySQL += " AND b.period = ?"
+		args = append(args, period)
+	}
+	// 总体预算排在前面
+	querySQL += " ORDER BY b.category_id IS NOT NULL, b.category_id, b.period"
+	rows, err := userDB.Query(querySQL, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	budgets := []models.Budget{}
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		budgets = append(budgets, *b)
+	}
+	return budgets, nil
+}
+
+// GetBudgetByID 返回预算，不存在时返回 ErrBudgetNotFound
+func GetBudgetByID(userDB *sql.DB, budgetID int64) (*models.Budget, error) {
+	b, err := scanBudget(userDB.QueryRow("SELECT "+budgetColumns+budgetJoins+" WHERE b.id = ?", budgetID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrBudgetNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return b, nil
+}
+
+// UpdateBudget 更新预算（nil 表示不更新该字段）
+func UpdateBudget(userDB *sql.DB, budgetID int64, amount *int64, rollover *bool, startDate *string) error {
+	var queryParts []string
+	var args []interface{}
+
+	if amount != nil {
+		queryParts = append(queryParts, "amount = ?")
+		args = append(args, *amount)
+	}
+	if rollover != nil {
+		queryParts = append(queryParts, "rollover = ?")
+		args = append(args, *rollover)
+	}
+	if startDate != nil {
+		queryParts = append(queryParts, "start_date = ?")
+		args = append(args, *startDate)
+	}
+	if len(queryParts) == 0 {
+		return nil
+	}
+	queryParts = append(queryParts, "updated_at = CURRENT_TIMESTAMP")
+	args = append(args, budgetID)
+
+	query := "UPDATE budgets SET " + strings.Join(queryParts, ", ") + " WHERE id = ?"
+	result, err := userDB.Exec(query, args...)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrBudgetNotFound
+	}
+	return nil
+}
+
+// DeleteBudget 删除预算
+func DeleteBudget(userDB *sql.DB, budgetID int64) error {
+	result, err := userDB.Exec("DELETE FROM budgets WHERE id = ?", budgetID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrBudgetNotFound
+	}
+	return nil
+}
+
+// GetBudgetSpending 统计 [start, end) 内每个周期的支出（分，正数），按周期开始时间分组。
+// 与 GetMonthlyStats 一样只统计支出、不含转账；categoryID 为 0 时统计全部类别，否则包含其子类别（拆分账单按拆分行的类别计）。
+func GetBudgetSpending(userDB *sql.DB, period string, categoryID int64, start string, end string) (map[string]int64, error) {
+	periodKey, ok := budgetPeriodKeys[period]
+	if !ok {
+		return nil, utils.ErrInvalidBudgetPeriod
+	}
+	querySQL := `
+SELECT ` + periodKey + ` AS period_start, COALESCE(-SUM(amount), 0)
+FROM ` + transactionLinesSQL + ` l
+WHERE amount < 0
+AND type != 'transfer'
+AND occurred_at >= ? AND occurred_at < ?`
+	args := []interface{}{start, end}
+	if categoryID != 0 {
+		querySQL += " AND category_id IN " + categorySubtreeSQL
+		args = append(args, categoryID)
+	}
+	querySQL += " GROUP BY period_start"
+
+	rows, err := userDB.Query(querySQL, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	spending := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var spent int64
+		if err := rows.Scan(&key, &spent); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		spending[key] = spent
+	}
+	return spending, nil
+}
@@ -4,13 +4,54 @@ import (
 	"AccountingAssistant/models"
 	"AccountingAssistant/utils"
 	"database/sql"
+	"strings"
 )
 
-// CreateCategory 在用户数据库中新增一个类别，返回插入的 ID
-func CreateCategory(userDB *sql.DB, name string) (int64, error) {
-	insertSQL := "INSERT INTO categories (name) VALUES (?)"
-	result, err := userDB.Exec(insertSQL, name)
+// categoryColumns 类别查询的公共列，顺序需与 scanCategory 保持一致
+const categoryColumns = "id, name, COALESCE(parent_id, 0), kind, icon, color, created_at, COALESCE(deleted_at, '')"
+
+func scanCategory(row rowScanner) (models.Category, error) {
+	var c models.Category
+	err := row.Scan(&c.ID, &c.Name, &c.ParentID, &c.Kind, &c.Icon, &c.Color, &c.CreatedAt, &c.DeletedAt)
+	return c, err
+}
+
+// nameKey 类别、标签名称比较时使用的形式：忽略大小写，首尾空白去掉、中间连续空白视为一个空格
+func nameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// findCategoryByNameKey 按规范化后的名称查找类别（excludeID 的类别与回收站中的类别除外），不存在返回 0。
+// SQLite 的 LOWER 只处理 ASCII，因此 name_key 由程序计算后写入
+func findCategoryByNameKey(userDB DBTX, name string, excludeID int64) (int64, error) {
+	var id int64
+	err := userDB.QueryRow("SELECT id FROM categories WHERE name_key = ? AND id != ? AND deleted_at IS NULL",
+		nameKey(name), excludeID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// CreateCategory 在用户数据库中新增一个类别（ParentID 为 0 表示顶级类别），返回插入的 ID。
+// 名称（忽略大小写与多余空白）已存在时返回 ErrCategoryExists
+func CreateCategory(userDB DBTX, c models.Category) (int64, error) {
+	existing, err := findCategoryByNameKey(userDB, c.Name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if existing != 0 {
+		return 0, utils.ErrCategoryExists
+	}
+	insertSQL := "INSERT INTO categories (name, name_key, parent_id, kind, icon, color) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := userDB.Exec(insertSQL, c.Name, nameKey(c.Name), nullableID(c.ParentID), c.Kind, c.Icon, c.Color)
 	if err != nil {
+		if isUniqueViolation(err) { // 并发创建了同名类别
+			return 0, utils.ErrCategoryExists
+		}
 		return 0, utils.WrapError(utils.ErrInsertFailed, err)
 	}
 	id, err := result.LastInsertId()
@@ -20,9 +61,9 @@ func CreateCategory(userDB *sql.DB, name string) (int64, error) {
 	return id, nil
 }
 
-// GetCategories 返回用户数据库中所有类别
-func GetCategories(userDB *sql.DB) ([]models.Category, error) {
-	querySQL := "SELECT id, name, created_at FROM categories ORDER BY created_at DESC" // 修复SQL
+// GetCategories 返回用户数据库中所有类别（平铺，不含 Children，不含回收站中的类别）
+func GetCategories(userDB DBTX) ([]models.Category, error) {
+	querySQL := "SELECT " + categoryColumns + " FROM categories WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC" // 修复SQL
 	rows, err := userDB.Query(querySQL)
 	if err != nil {
 		return nil, utils.WrapError(utils.ErrQueryFailed, err)
@@ -31,8 +72,8 @@ func GetCategories(userDB *sql.DB) ([]models.Category, error) {
 
 	var categories []models.Category
 	for rows.Next() {
-		var c models.Category
-		if err := rows.Scan(&c.ID, &c.Name, &c.CreatedAt); err != nil {
+		c, err := scanCategory(rows)
+		if err != nil {
 			return nil, utils.WrapError(utils.ErrReadFailed, err)
 		}
 		categories = append(categories, c)
@@ -40,53 +81,160 @@ func GetCategories(userDB *sql.DB) ([]models.Category, error) {
 	return categories, nil
 }
 
-// DeleteCategory 删除类别（在事务中把相关交易的 category_id 置为 NULL）
-func DeleteCategory(userDB *sql.DB, categoryID int64) error {
-	// 开始事务
-	tx, err := userDB.Begin()
+// DeleteCategory 把类别移到回收站：只记录删除时间，账单、拆分行、预算、规则与商户对它的引用保留，
+// 在回收站期间按未分类处理，恢复后原样生效。子类别的 parent_id 也保留：类别在回收站期间其子类别按顶级类别显示，
+// 恢复后重新挂回原处；彻底删除时才移到上一级。调用方负责事务
+func DeleteCategory(tx DBTX, categoryID int64) error {
+	result, err := tx.Exec("UPDATE categories SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL", categoryID)
 	if err != nil {
-		return utils.WrapError(utils.ErrDBConnFailed, err)
+		return utils.WrapError(utils.ErrDeleteFailed, err)
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			// 回滚
-			tx.Rollback()
-			// 如果程序发生了panic（严重错误），就回滚事务，然后重新抛出这个panic让上层处理
-			panic(p)
-		}
-	}()
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrCategoryNotFound
+	}
+	return nil
+}
 
-	// 1. 更新相关交易的category_id
-	updateSQL := "UPDATE transactions SET category_id = 0 WHERE category_id = ?"
-	_, err = tx.Exec(updateSQL, categoryID)
+// purgeCategory 彻底删除类别：相关交易与拆分行的 category_id 置为 NULL，子类别移到被删除类别的上级
+func purgeCategory(tx DBTX, categoryID int64) error {
+	// 1. 相关交易改为未分类（与记账时一样以 NULL 表示）
+	updateSQL := "UPDATE transactions SET category_id = NULL WHERE category_id = ?"
+	_, err := tx.Exec(updateSQL, categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	_, err = tx.Exec("UPDATE transaction_splits SET category_id = NULL WHERE category_id = ?", categoryID)
 	if err != nil {
 		return utils.WrapError(utils.ErrUpdateFailed, err)
 	}
 
-	// 2. 删除类别
-	deleteSQL := "DELETE FROM categories WHERE id = ?"
-	_, err = tx.Exec(deleteSQL, categoryID)
+	// 2. 删除该类别的预算与自动分类规则，周期规则改为未分类，商户不再有默认类别
+	_, err = tx.Exec("DELETE FROM budgets WHERE category_id = ?", categoryID)
 	if err != nil {
 		return utils.WrapError(utils.ErrDeleteFailed, err)
 	}
-	//
-	return tx.Commit()
+	_, err = tx.Exec("DELETE FROM category_rule_tags WHERE rule_id IN (SELECT id FROM category_rules WHERE category_id = ?)", categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	_, err = tx.Exec("DELETE FROM category_rules WHERE category_id = ?", categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	_, err = tx.Exec("UPDATE recurring_rules SET category_id = NULL WHERE category_id = ?", categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	_, err = tx.Exec("UPDATE payees SET default_category_id = NULL WHERE default_category_id = ?", categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+
+	// 3. 子类别（回收站中的）移到上一级
+	_, err = tx.Exec("UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = ?) WHERE parent_id = ?",
+		categoryID, categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+
+	// 4. 删除类别
+	_, err = tx.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// MergeCategories 把 sourceIDs 类别合并到 targetID：账单（含拆分行）、周期规则、自动分类规则、商户的默认类别改为目标类别；
+// 预算移到目标类别（目标类别在同一周期已有预算时保留目标的预算，删除来源的预算）；
+// 来源类别的子类别移到目标类别下，最后删除来源类别。kind 为目标类别合并后的收支类型。
+// 调用方负责校验类别存在且目标不是来源的子孙类别，并负责事务。
+func MergeCategories(tx DBTX, sourceIDs []int64, targetID int64, kind string) (*models.CategoryMergeResult, error) {
+	result := &models.CategoryMergeResult{TargetID: targetID, SourceIDs: sourceIDs}
+	exec := func(query string, args ...interface{}) (int64, error) {
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+		affected, _ := res.RowsAffected()
+		return affected, nil
+	}
+	// 账单数按笔计：拆分账单可能有多行属于来源类别
+	transactionIDs, err := GetTransactionIDsInCategories(tx, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	result.Transactions = int64(len(transactionIDs))
+	for _, sourceID := range sourceIDs {
+		if _, err := exec("UPDATE transactions SET category_id = ? WHERE category_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		if _, err := exec("UPDATE transaction_splits SET category_id = ? WHERE category_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		n, err := exec("UPDATE recurring_rules SET category_id = ? WHERE category_id = ?", targetID, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		result.RecurringRules += n
+		if n, err = exec("UPDATE category_rules SET category_id = ?, updated_at = CURRENT_TIMESTAMP WHERE category_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		result.CategoryRules += n
+		if _, err = exec("UPDATE payees SET default_category_id = ? WHERE default_category_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		// 预算按 (类别, 周期) 唯一：目标已有同周期预算的先删除，其余移到目标类别
+		if n, err = exec(`DELETE FROM budgets WHERE category_id = ?
+AND period IN (SELECT period FROM budgets WHERE category_id = ?)`, sourceID, targetID); err != nil {
+			return nil, err
+		}
+		result.BudgetsDropped += n
+		if n, err = exec("UPDATE budgets SET category_id = ?, updated_at = CURRENT_TIMESTAMP WHERE category_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		result.BudgetsMoved += n
+		if _, err = exec("UPDATE categories SET parent_id = ? WHERE parent_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		if _, err = exec("DELETE FROM categories WHERE id = ?", sourceID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := exec("UPDATE categories SET kind = ? WHERE id = ?", kind, targetID); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// UpdateCategory 更新类别名称
-func UpdateCategory(userDB *sql.DB, categoryID int64, name string) error {
-	updateSQL := "UPDATE categories SET name = ? WHERE id = ?"
-	_, err := userDB.Exec(updateSQL, name, categoryID)
+// UpdateCategory 更新类别的名称、上级类别（0 表示顶级类别，由调用方检查是否成环）、收支类型、图标与颜色。
+// 名称与其他类别重复时返回 ErrCategoryExists
+func UpdateCategory(userDB DBTX, c models.Category) error {
+	existing, err := findCategoryByNameKey(userDB, c.Name, c.ID)
+	if err != nil {
+		return err
+	}
+	if existing != 0 {
+		return utils.ErrCategoryExists
+	}
+	updateSQL := "UPDATE categories SET name = ?, name_key = ?, parent_id = ?, kind = ?, icon = ?, color = ? WHERE id = ?"
+	_, err = userDB.Exec(updateSQL, c.Name, nameKey(c.Name), nullableID(c.ParentID), c.Kind, c.Icon, c.Color, c.ID)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return utils.ErrCategoryExists
+		}
 		return utils.WrapError(utils.ErrUpdateFailed, err)
 	}
 	return nil
 }
 
-// GetCategoryByID 返回 category 对象，若不存在返回 nil, nil
-func GetCategoryByID(userDB *sql.DB, categoryID int64) (*models.Category, error) {
-	var c models.Category
-	err := userDB.QueryRow("SELECT id, name, created_at FROM categories WHERE id = ?", categoryID).Scan(&c.ID, &c.Name, &c.CreatedAt)
+// GetCategoryByID 返回 category 对象，若不存在（或在回收站中）返回 nil, nil
+func GetCategoryByID(userDB DBTX, categoryID int64) (*models.Category, error) {
+	c, err := scanCategory(userDB.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = ? AND deleted_at IS NULL", categoryID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -96,15 +244,50 @@ func GetCategoryByID(userDB *sql.DB, categoryID int64) (*models.Category, error)
 	return &c, nil
 }
 
-func GetCategoryIdByName(userDB *sql.DB, name string) (int64, error) {
-	var categoryID int64
-	querySQL := "SELECT id FROM categories WHERE name = ?"
-	err := userDB.QueryRow(querySQL, name).Scan(&categoryID)
+// GetCategoryIdByName 按名称查找类别（忽略大小写与多余空白），不存在返回 0
+func GetCategoryIdByName(userDB DBTX, name string) (int64, error) {
+	return findCategoryByNameKey(userDB, name, 0)
+}
+
+// GetCategoryTransactionTypes 返回每个类别被用于哪些收支类型（"income"、"expense"），
+// 键 0 表示未分类的账单（拆分账单按拆分行的类别计）
+func GetCategoryTransactionTypes(userDB DBTX) (map[int64][]string, error) {
+	querySQL := `
+SELECT DISTINCT COALESCE(category_id, 0), type FROM ` + transactionLinesSQL + ` l
+WHERE type IN ('income', 'expense')
+ORDER BY 1, 2`
+	rows, err := userDB.Query(querySQL)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, nil // 不存在返回0
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	types := make(map[int64][]string)
+	for rows.Next() {
+		var categoryID int64
+		var transactionType string
+		if err := rows.Scan(&categoryID, &transactionType); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
 		}
-		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+		types[categoryID] = append(types[categoryID], transactionType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
 	}
-	return categoryID, nil
+	return types, nil
 }
+
+// categoryAncestorsSQL 查询某个类别（参数）全部上级类别 id 的子查询（含回收站中的），使用 UNION 保证存在环时也能结束
+const categoryAncestorsSQL = `(WITH RECURSIVE ancestors(id) AS (
+	SELECT parent_id FROM categories WHERE id = ?
+	UNION
+	SELECT c.parent_id FROM categories c JOIN ancestors a ON c.id = a.id
+) SELECT id FROM ancestors WHERE id IS NOT NULL)`
+
+// categorySubtreeSQL 查询某个类别（参数）及其全部子孙类别 id 的子查询，用于按类别筛选时包含子类别。
+// 使用 UNION 而不是 UNION ALL，即使数据中存在环也能结束；回收站中的子类别不包含在内。
+const categorySubtreeSQL = `(WITH RECURSIVE subtree(id) AS (
+	SELECT ?
+	UNION
+	SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id WHERE c.deleted_at IS NULL
+) SELECT id FROM subtree)`
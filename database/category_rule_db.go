@@ -0,0 +1,153 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+)
+
+// 自动分类规则的数据库操作
+
+const categoryRuleColumns = `r.id, r.name, r.priority, r.category_id, COALESCE(c.name, ''), COALESCE(c.kind, 'both'),
+	COALESCE(r.type, ''), COALESCE(r.note_contains, ''), COALESCE(r.note_regex, ''),
+	COALESCE(r.min_amount, 0), COALESCE(r.max_amount, 0), COALESCE(r.account_id, 0),
+	COALESCE(r.payee_id, 0), COALESCE(p.name, ''),
+	COALESCE((SELECT GROUP_CONCAT(g.name, ',') FROM category_rule_tags l JOIN tags g ON l.tag_id = g.id
+		WHERE l.rule_id = r.id), ''),
+	r.active, r.created_at, r.updated_at`
+
+const categoryRuleJoins = " FROM category_rules r LEFT JOIN categories c ON r.category_id = c.id AND c.deleted_at IS NULL LEFT JOIN payees p ON r.payee_id = p.id"
+
+func scanCategoryRule(row rowScanner) (*models.CategoryRule, error) {
+	var r models.CategoryRule
+	var tags string
+	var updatedAt sql.NullString
+	if err := row.Scan(&r.ID, &r.Name, &r.Priority, &r.CategoryID, &r.CategoryName, &r.CategoryKind,
+		&r.Type, &r.NoteContains, &r.NoteRegex,
+		&r.MinAmount, &r.MaxAmount, &r.AccountID, &r.PayeeID, &r.PayeeName, &tags, &r.Active,
+		&r.CreatedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	r.Tags = splitTagNames(tags)
+	r.UpdatedAt = updatedAt.String
+	return &r, nil
+}
+
+// CreateCategoryRule 新增自动分类规则，返回插入的 ID
+func CreateCategoryRule(userDB DBTX, r *models.CategoryRule) (int64, error) {
+	insertSQL := `
+INSERT INTO category_rules (name, priority, category_id, type, note_contains, note_regex, min_amount, max_amount, account_id, payee_id, active)
+VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?)`
+	result, err := userDB.Exec(insertSQL, r.Name, r.Priority, r.CategoryID, r.Type, r.NoteContains, r.NoteRegex,
+		r.MinAmount, r.MaxAmount, nullableID(r.AccountID), nullableID(r.PayeeID), r.Active)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetCategoryRules 按匹配顺序（priority、id）返回规则，activeOnly 为 true 时只返回启用的规则
+// （类别在回收站中的规则不生效，也不返回）
+func GetCategoryRules(userDB DBTX, activeOnly bool) ([]models.CategoryRule, error) {
+	querySQL := "SELECT " + categoryRuleColumns + categoryRuleJoins
+	if activeOnly {
+		querySQL += " WHERE r.active = 1 AND c.id IS NOT NULL"
+	}
+	querySQL += " ORDER BY r.priority, r.id"
+	rows, err := userDB.Query(querySQL)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	rules := []models.CategoryRule{}
+	for rows.Next() {
+		r, err := scanCategoryRule(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		rules = append(rules, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return rules, nil
+}
+
+// GetCategoryRuleByID 返回规则，不存在时返回 ErrCategoryRuleNotFound
+func GetCategoryRuleByID(userDB *sql.DB, ruleID int64) (*models.CategoryRule, error) {
+	r, err := scanCategoryRule(userDB.QueryRow("SELECT "+categoryRuleColumns+categoryRuleJoins+" WHERE r.id = ?", ruleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrCategoryRuleNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return r, nil
+}
+
+// UpdateCategoryRule 整体更新规则的可编辑字段
+func UpdateCategoryRule(userDB DBTX, r *models.CategoryRule) error {
+	updateSQL := `
+UPDATE category_rules SET name = ?, priority = ?, category_id = ?, type = NULLIF(?, ''),
+	note_contains = NULLIF(?, ''), note_regex = NULLIF(?, ''), min_amount = NULLIF(?, 0), max_amount = NULLIF(?, 0),
+	account_id = ?, payee_id = ?, active = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?`
+	result, err := userDB.Exec(updateSQL, r.Name, r.Priority, r.CategoryID, r.Type, r.NoteContains, r.NoteRegex,
+		r.MinAmount, r.MaxAmount, nullableID(r.AccountID), nullableID(r.PayeeID), r.Active, r.ID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrCategoryRuleNotFound
+	}
+	return nil
+}
+
+// DeleteCategoryRule 删除规则及其标签关联；已分类的账单不受影响
+func DeleteCategoryRule(userDB *sql.DB, ruleID int64) error {
+	if _, err := userDB.Exec("DELETE FROM category_rule_tags WHERE rule_id = ?", ruleID); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	result, err := userDB.Exec("DELETE FROM category_rules WHERE id = ?", ruleID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrCategoryRuleNotFound
+	}
+	return nil
+}
+
+// SetTransactionCategories 修改多笔账单的类别，并给账单加上 tags 中的标签（键均为账单 id）。调用方负责事务
+func SetTransactionCategories(tx *sql.Tx, categories map[int64]int64, tags map[int64][]int64) error {
+	stmt, err := tx.Prepare("UPDATE transactions SET category_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?")
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	defer stmt.Close()
+	for transactionID, categoryID := range categories {
+		if _, err := stmt.Exec(nullableID(categoryID), transactionID); err != nil {
+			return utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+	}
+	for transactionID, tagIDs := range tags {
+		if err := AddTransactionTags(tx, transactionID, tagIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DBTX 同时被 *sql.DB 和 *sql.Tx 实现。
+// 需要在事务中组合调用的数据层函数接收 DBTX，这样既能直接使用数据库连接，也能放进同一个事务里执行。
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// rowScanner 同时被 *sql.Row 和 *sql.Rows 实现
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// nullableID 把 0 转换为 NULL（约定 0 表示未设置的外键）
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// isUniqueViolation 判断错误是否为违反唯一约束（数据层先检查了重复，这里处理并发写入的情况）
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// WithSavepoint 在事务中设置保存点后执行 fn：fn 出错时只撤销保存点之后的修改并返回 fn 的错误（itemErr），
+// 事务可以继续使用；保存点本身的操作失败时返回 err，此时调用方应放弃整个事务
+func WithSavepoint(tx DBTX, fn func() error) (itemErr error, err error) {
+	if _, err := tx.Exec("SAVEPOINT item"); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	if itemErr = fn(); itemErr != nil {
+		if _, err := tx.Exec("ROLLBACK TO item"); err != nil {
+			return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+	}
+	if _, err := tx.Exec("RELEASE item"); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return itemErr, nil
+}
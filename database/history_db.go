@@ -0,0 +1,252 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 变更历史的数据库操作
+// 历史记录只追加：表上的触发器禁止 UPDATE 与 DELETE，彻底删除账单、类别后其历史仍然保留。
+// 快照读取包含回收站中的账单与类别，以便记录删除、恢复与彻底删除前后的状态。
+
+const historyColumns = `id, entity_type, entity_id, action, old_values, new_values, actor_user_id, session, created_at`
+
+func scanHistoryEntry(row rowScanner) (*models.HistoryEntry, error) {
+	var h models.HistoryEntry
+	var oldValues, newValues sql.NullString
+	if err := row.Scan(&h.ID, &h.EntityType, &h.EntityID, &h.Action, &oldValues, &newValues,
+		&h.ActorUserID, &h.Session, &h.CreatedAt); err != nil {
+		return nil, err
+	}
+	if oldValues.Valid {
+		h.OldValues = []byte(oldValues.String)
+	}
+	if newValues.Valid {
+		h.NewValues = []byte(newValues.String)
+	}
+	return &h, nil
+}
+
+// AddHistory 追加一条变更历史（OldValues、NewValues 为空时存为 NULL）
+func AddHistory(db DBTX, h *models.HistoryEntry) error {
+	var oldValues, newValues interface{}
+	if h.OldValues != nil {
+		oldValues = string(h.OldValues)
+	}
+	if h.NewValues != nil {
+		newValues = string(h.NewValues)
+	}
+	insertSQL := `
+INSERT INTO change_history (entity_type, entity_id, action, old_values, new_values, actor_user_id, session)
+VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := db.Exec(insertSQL, h.EntityType, h.EntityID, h.Action, oldValues, newValues, h.ActorUserID, h.Session); err != nil {
+		return utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return nil
+}
+
+// GetHistory 返回某个账单或类别的全部变更历史，按发生顺序排列
+func GetHistory(db DBTX, entityType string, entityID int64) ([]models.HistoryEntry, error) {
+	rows, err := db.Query("SELECT "+historyColumns+" FROM change_history WHERE entity_type = ? AND entity_id = ? ORDER BY id",
+		entityType, entityID)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	entries := []models.HistoryEntry{}
+	for rows.Next() {
+		h, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		entries = append(entries, *h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return entries, nil
+}
+
+// GetHistoryEntry 返回一条变更历史，不存在时返回 ErrHistoryNotFound
+func GetHistoryEntry(db DBTX, historyID int64) (*models.HistoryEntry, error) {
+	h, err := scanHistoryEntry(db.QueryRow("SELECT "+historyColumns+" FROM change_history WHERE id = ?", historyID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrHistoryNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return h, nil
+}
+
+// HistoryTransactionID 返回账单在变更历史中使用的 id：转账的两条腿都记在 transfer_id 下，
+// 其他账单（以及已彻底删除、查不到的账单）为自身的 id
+func HistoryTransactionID(db DBTX, transactionID int64) (int64, error) {
+	var transferID int64
+	err := db.QueryRow("SELECT COALESCE(transfer_id, 0) FROM transactions WHERE id = ?", transactionID).Scan(&transferID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if transferID != 0 {
+		return transferID, nil
+	}
+	return transactionID, nil
+}
+
+// GetTransactionSnapshot 读取账单（含回收站中的）当前状态的快照，同时返回其在变更历史中使用的 id。
+// 转账的两条腿合成一个快照。不存在时返回 ErrTransactionNotFound
+func GetTransactionSnapshot(db DBTX, transactionID int64) (*models.TransactionSnapshot, int64, error) {
+	var s models.TransactionSnapshot
+	var transferID int64
+	err := db.QueryRow(`
+SELECT type, amount, COALESCE(category_id, 0), COALESCE(account_id, 0), COALESCE(payee_id, 0), COALESCE(transfer_id, 0),
+	COALESCE(note, ''), occurred_at
+FROM transactions WHERE id = ?`, transactionID).Scan(&s.Type, &s.Amount, &s.CategoryID, &s.AccountID, &s.PayeeID, &transferID,
+		&s.Note, &s.OccurredAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, utils.ErrTransactionNotFound
+		}
+		return nil, 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+
+	entityID := transactionID
+	if transferID != 0 {
+		entityID = transferID
+		rows, err := db.Query("SELECT amount, COALESCE(account_id, 0) FROM transactions WHERE transfer_id = ?", transferID)
+		if err != nil {
+			return nil, 0, utils.WrapError(utils.ErrQueryFailed, err)
+		}
+		for rows.Next() {
+			var amount, accountID int64
+			if err := rows.Scan(&amount, &accountID); err != nil {
+				rows.Close()
+				return nil, 0, utils.WrapError(utils.ErrReadFailed, err)
+			}
+			if amount < 0 {
+				s.AccountID = accountID
+			} else {
+				s.Amount, s.ToAccountID = amount, accountID
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, 0, utils.WrapError(utils.ErrReadFailed, err)
+		}
+	}
+
+	// 标签与拆分行都记在转出腿（普通账单即自身）上
+	if s.Tags, err = snapshotTags(db, entityID); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Query("SELECT COALESCE(category_id, 0), amount, COALESCE(note, '') FROM transaction_splits WHERE transaction_id = ? ORDER BY id", entityID)
+	if err != nil {
+		return nil, 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var split models.SplitSnapshot
+		if err := rows.Scan(&split.CategoryID, &split.Amount, &split.Note); err != nil {
+			return nil, 0, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		s.Splits = append(s.Splits, split)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return &s, entityID, nil
+}
+
+// snapshotTags 返回账单的标签名称（按名称排序）
+func snapshotTags(db DBTX, transactionID int64) ([]string, error) {
+	rows, err := db.Query(`SELECT g.name FROM transaction_tags l JOIN tags g ON l.tag_id = g.id
+WHERE l.transaction_id = ? ORDER BY g.name`, transactionID)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		tags = append(tags, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return tags, nil
+}
+
+// GetCategorySnapshot 读取类别（含回收站中的）当前状态的快照，不存在时返回 ErrCategoryNotFound
+func GetCategorySnapshot(db DBTX, categoryID int64) (*models.CategorySnapshot, error) {
+	var s models.CategorySnapshot
+	err := db.QueryRow("SELECT name, COALESCE(parent_id, 0), kind, icon, color FROM categories WHERE id = ?", categoryID).
+		Scan(&s.Name, &s.ParentID, &s.Kind, &s.Icon, &s.Color)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrCategoryNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return &s, nil
+}
+
+// GetCategorySnapshots 读取全部类别（含回收站中的）的快照，按类别 id 索引
+func GetCategorySnapshots(db DBTX) (map[int64]*models.CategorySnapshot, error) {
+	rows, err := db.Query("SELECT id, name, COALESCE(parent_id, 0), kind, icon, color FROM categories")
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	snapshots := make(map[int64]*models.CategorySnapshot)
+	for rows.Next() {
+		var id int64
+		var s models.CategorySnapshot
+		if err := rows.Scan(&id, &s.Name, &s.ParentID, &s.Kind, &s.Icon, &s.Color); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		snapshots[id] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return snapshots, nil
+}
+
+// GetTransactionIDsInCategories 返回属于这些类别（含拆分行属于这些类别）的账单在变更历史中使用的 id，含回收站中的账单
+func GetTransactionIDsInCategories(db DBTX, categoryIDs []int64) ([]int64, error) {
+	if len(categoryIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(categoryIDs)), ",")
+	args := make([]interface{}, 0, 2*len(categoryIDs))
+	for _, id := range categoryIDs {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+	return queryIDs(db, `SELECT DISTINCT COALESCE(transfer_id, id) FROM transactions
+WHERE category_id IN (`+placeholders+`) OR id IN (SELECT transaction_id FROM transaction_splits WHERE category_id IN (`+placeholders+`))
+ORDER BY 1`, args...)
+}
+
+// MaxCategoryID 返回当前最大的类别 id（没有类别时为 0），用于找出一次操作中自动创建的类别
+func MaxCategoryID(db DBTX) (int64, error) {
+	var id int64
+	if err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM categories").Scan(&id); err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetCategoryIDsAfter 返回 id 大于 afterID 的类别 id（按 id 排序）
+func GetCategoryIDsAfter(db DBTX, afterID int64) ([]int64, error) {
+	return queryIDs(db, "SELECT id FROM categories WHERE id > ? ORDER BY id", afterID)
+}
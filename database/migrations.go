@@ -0,0 +1,368 @@
+package database
+
+import (
+	"AccountingAssistant/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// 用户数据库结构迁移
+// createUserDatabase 只创建最初版本的表结构，之后的所有结构变化都以迁移的形式追加在这里，
+// 当前版本号保存在 SQLite 的 PRAGMA user_version 中。
+// 新用户注册时和老用户数据库第一次被打开时，都会依次执行尚未执行过的迁移，保证表结构一致。
+// 注意：迁移只能追加，不能修改或删除已发布的迁移。
+var userDBMigrations = [][]string{
+	// 1: 账单发生时间（本地时间，用户可指定）与更新时间
+	{
+		"ALTER TABLE transactions ADD COLUMN occurred_at TEXT",
+		"ALTER TABLE transactions ADD COLUMN updated_at DATETIME",
+		"UPDATE transactions SET occurred_at = datetime(created_at, 'localtime'), updated_at = created_at WHERE occurred_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_transactions_occurred_at ON transactions (occurred_at)",
+	},
+	// 2: 账户（钱包）
+	{
+		`CREATE TABLE IF NOT EXISTS accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL DEFAULT 'cash',
+	opening_balance INTEGER NOT NULL DEFAULT 0, -- 期初余额（分）
+	currency TEXT NOT NULL DEFAULT 'CNY',
+	archived INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"ALTER TABLE transactions ADD COLUMN account_id INTEGER", // NULL 表示未指定账户
+		"CREATE INDEX IF NOT EXISTS idx_transactions_account_id ON transactions (account_id)",
+	},
+	// 3: 转账：两条腿（转出为负、转入为正）共用同一个 transfer_id（即转出腿的 id）
+	{
+		"ALTER TABLE transactions ADD COLUMN transfer_id INTEGER",
+		"CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions (transfer_id)",
+	},
+	// 4: 周期账单规则；由规则生成的账单记录规则 id 与第几次发生（唯一索引保证不会重复生成）
+	{
+		`CREATE TABLE IF NOT EXISTS recurring_rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	type TEXT NOT NULL,
+	amount INTEGER NOT NULL,       -- 带符号的金额（分），与 transactions.amount 一致
+	category_id INTEGER,
+	account_id INTEGER,
+	note TEXT,
+	frequency TEXT NOT NULL,       -- daily / weekly / monthly / yearly
+	interval INTEGER NOT NULL DEFAULT 1,
+	start_date TEXT NOT NULL,      -- 第一次发生的时间（本地时间）
+	end_date TEXT,                 -- 最后可发生的时间（包含），NULL 表示不限
+	count INTEGER NOT NULL DEFAULT 0, -- 最多发生次数，0 表示不限
+	occurrences INTEGER NOT NULL DEFAULT 0, -- 已生成的次数
+	next_run TEXT,                 -- 下一次发生的时间，NULL 表示已结束
+	active INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"ALTER TABLE transactions ADD COLUMN recurring_rule_id INTEGER",
+		"ALTER TABLE transactions ADD COLUMN recurring_seq INTEGER",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_recurring ON transactions (recurring_rule_id, recurring_seq)",
+	},
+	// 5: 预算（按类别或总体，每个范围每种周期只能有一个）
+	{
+		`CREATE TABLE IF NOT EXISTS budgets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	category_id INTEGER,           -- NULL 表示总体预算
+	period TEXT NOT NULL,          -- month / week / year
+	amount INTEGER NOT NULL,       -- 每个周期的额度（分，正数）
+	rollover INTEGER NOT NULL DEFAULT 0, -- 未花完的额度是否结转到下一周期
+	start_date TEXT NOT NULL,      -- 生效的第一个周期的开始时间，结转从这里开始累计
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_scope ON budgets (COALESCE(category_id, 0), period)",
+	},
+	// 6: 导入来源中的唯一编号（如 "alipay:交易订单号"），重复导入时据此去重
+	{
+		"ALTER TABLE transactions ADD COLUMN external_id TEXT",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external_id ON transactions (external_id)",
+	},
+	// 7: 类别层级（NULL 表示顶级类别）
+	{
+		"ALTER TABLE categories ADD COLUMN parent_id INTEGER",
+		"CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id)",
+	},
+	// 8: 类别的收支类型、图标与颜色；已有类别按账单与周期规则的使用情况推断收支类型，
+	// 只用于支出（或收入）的类别设为 expense（或 income），未使用或两者都用过的为 both
+	{
+		"ALTER TABLE categories ADD COLUMN kind TEXT NOT NULL DEFAULT 'both'",
+		"ALTER TABLE categories ADD COLUMN icon TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE categories ADD COLUMN color TEXT NOT NULL DEFAULT ''",
+		`UPDATE categories SET kind = 'expense'
+WHERE EXISTS (SELECT 1 FROM transactions WHERE category_id = categories.id AND type = 'expense'
+	UNION ALL SELECT 1 FROM recurring_rules WHERE category_id = categories.id AND type = 'expense')
+AND NOT EXISTS (SELECT 1 FROM transactions WHERE category_id = categories.id AND type = 'income'
+	UNION ALL SELECT 1 FROM recurring_rules WHERE category_id = categories.id AND type = 'income')`,
+		`UPDATE categories SET kind = 'income'
+WHERE EXISTS (SELECT 1 FROM transactions WHERE category_id = categories.id AND type = 'income'
+	UNION ALL SELECT 1 FROM recurring_rules WHERE category_id = categories.id AND type = 'income')
+AND NOT EXISTS (SELECT 1 FROM transactions WHERE category_id = categories.id AND type = 'expense'
+	UNION ALL SELECT 1 FROM recurring_rules WHERE category_id = categories.id AND type = 'expense')`,
+	},
+	// 9: 自动分类规则：所有条件（为空的不限）都满足时把账单记到 category_id，按 priority、id 顺序取第一条
+	{
+		`CREATE TABLE IF NOT EXISTS category_rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL DEFAULT '',
+	priority INTEGER NOT NULL DEFAULT 0, -- 数值小的先匹配
+	category_id INTEGER NOT NULL,        -- 匹配后设置的类别
+	type TEXT,                           -- 条件：income / expense
+	note_contains TEXT,                  -- 条件：备注包含（不区分大小写）
+	note_regex TEXT,                     -- 条件：备注匹配正则表达式
+	min_amount INTEGER,                  -- 条件：金额下限（分，绝对值，包含）
+	max_amount INTEGER,                  -- 条件：金额上限（分，绝对值，包含）
+	account_id INTEGER,                  -- 条件：账户
+	active INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_category_rules_priority ON category_rules (priority, id)",
+	},
+	// 10: 标签：账单与标签多对多；自动分类规则匹配时可同时给账单加上标签
+	{
+		`CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		`CREATE TABLE IF NOT EXISTS transaction_tags (
+	transaction_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (transaction_id, tag_id)
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_transaction_tags_tag_id ON transaction_tags (tag_id)",
+		`CREATE TABLE IF NOT EXISTS category_rule_tags (
+	rule_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+	PRIMARY KEY (rule_id, tag_id)
+)`,
+	},
+	// 11: 拆分账单：一笔账单分成多行，每行有自己的类别与金额（分，符号与账单一致），各行之和等于账单金额
+	{
+		`CREATE TABLE IF NOT EXISTS transaction_splits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	transaction_id INTEGER NOT NULL,
+	category_id INTEGER,
+	amount INTEGER NOT NULL,
+	note TEXT
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction_id ON transaction_splits (transaction_id)",
+		"CREATE INDEX IF NOT EXISTS idx_transaction_splits_category_id ON transaction_splits (category_id)",
+	},
+	// 12: 商户（交易对方）：名称与别名在同一用户内不重复，可设置默认类别；账单与自动分类规则可引用商户
+	{
+		`CREATE TABLE IF NOT EXISTS payees (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	default_category_id INTEGER,         -- 记账时没有给出类别则使用该类别
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		`CREATE TABLE IF NOT EXISTS payee_aliases (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	payee_id INTEGER NOT NULL,
+	alias TEXT NOT NULL
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_payee_aliases_payee_id ON payee_aliases (payee_id)",
+		"ALTER TABLE transactions ADD COLUMN payee_id INTEGER",
+		"CREATE INDEX IF NOT EXISTS idx_transactions_payee_id ON transactions (payee_id)",
+		"ALTER TABLE category_rules ADD COLUMN payee_id INTEGER", // 条件：商户
+	},
+	// 13: 账单附件（小票照片、发票 PDF）：文件保存在用户的附件目录中，这里只记录元数据
+	{
+		`CREATE TABLE IF NOT EXISTS attachments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	transaction_id INTEGER NOT NULL,
+	file_name TEXT NOT NULL,             -- 上传时的原始文件名
+	stored_name TEXT NOT NULL,           -- 附件目录中的文件名
+	thumbnail_name TEXT,                 -- 图片的缩略图文件名，无法生成时为 NULL
+	mime_type TEXT NOT NULL,
+	size INTEGER NOT NULL,               -- 字节数
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_attachments_transaction_id ON attachments (transaction_id)",
+	},
+	// 14: 回收站：删除账单、类别时只记录删除时间（NULL 表示未删除），超过保留天数后彻底删除；
+	// settings 保存用户级别的设置（如回收站保留天数）
+	{
+		"ALTER TABLE transactions ADD COLUMN deleted_at DATETIME",
+		"ALTER TABLE categories ADD COLUMN deleted_at DATETIME",
+		"CREATE INDEX IF NOT EXISTS idx_transactions_deleted_at ON transactions (deleted_at)",
+		`CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+)`,
+	},
+	// 15: 账单与类别的变更历史：只追加，触发器禁止修改和删除
+	{
+		`CREATE TABLE IF NOT EXISTS change_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	entity_type TEXT NOT NULL,           -- 'transaction' 或 'category'
+	entity_id INTEGER NOT NULL,          -- 转账为 transfer_id
+	action TEXT NOT NULL,                -- create / update / delete / restore / purge / revert / merge
+	old_values TEXT,                     -- 变更前的快照（JSON），新建时为 NULL
+	new_values TEXT,                     -- 变更后的快照（JSON），删除时为 NULL
+	actor_user_id INTEGER NOT NULL,
+	session TEXT NOT NULL DEFAULT '',    -- 会话 id 的摘要，后台任务为空
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+)`,
+		"CREATE INDEX IF NOT EXISTS idx_change_history_entity ON change_history (entity_type, entity_id)",
+		`CREATE TRIGGER IF NOT EXISTS change_history_no_update BEFORE UPDATE ON change_history
+BEGIN SELECT RAISE(ABORT, 'change_history is append-only'); END`,
+		`CREATE TRIGGER IF NOT EXISTS change_history_no_delete BEFORE DELETE ON change_history
+BEGIN SELECT RAISE(ABORT, 'change_history is append-only'); END`,
+	},
+	// 16: 未分类统一以 NULL 表示：早期删除类别时账单的 category_id 被置为 0
+	{
+		"UPDATE transactions SET category_id = NULL WHERE category_id = 0",
+	},
+	// 17: 类别名称的规范化形式（见 nameKey），由程序计算；已有的重名类别在 fillCategoryNameKeys 中改名
+	{
+		"ALTER TABLE categories ADD COLUMN name_key TEXT",
+	},
+	// 18: 未删除的类别名称不重复（回收站中的类别可以与其他类别同名）
+	{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name_key ON categories (name_key) WHERE deleted_at IS NULL",
+	},
+	// 19: 修改账单时清空类别曾把 category_id 写为 0，同样改为 NULL
+	{
+		"UPDATE transactions SET category_id = NULL WHERE category_id = 0",
+	},
+}
+
+// userDBMigrationFuncs 需要在程序中处理数据的迁移（键为版本号），在该版本的 SQL 语句之后、同一个事务中执行
+var userDBMigrationFuncs = map[int]func(tx *sql.Tx) error{
+	17: fillCategoryNameKeys,
+}
+
+// fillCategoryNameKeys 计算所有类别的 name_key。名称规范化后相同的未删除类别只保留 id 最小的一个的名称，
+// 其余依次改名为 "名称 (2)"、"名称 (3)"……，账单等引用不变，用户可以再合并或改名
+func fillCategoryNameKeys(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, name, deleted_at IS NULL FROM categories ORDER BY id")
+	if err != nil {
+		return err
+	}
+	type category struct {
+		id   int64
+		name string
+		live bool
+	}
+	var categories []category
+	for rows.Next() {
+		var c category
+		if err := rows.Scan(&c.id, &c.name, &c.live); err != nil {
+			rows.Close()
+			return err
+		}
+		categories = append(categories, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	used := make(map[string]bool) // 未删除类别原有名称的 key，改名时避开
+	for _, c := range categories {
+		if c.live {
+			used[nameKey(c.name)] = true
+		}
+	}
+	kept := make(map[string]bool) // 已经分配给未删除类别的 key
+	for _, c := range categories {
+		name, key := c.name, nameKey(c.name)
+		if c.live {
+			for n := 2; kept[key]; n++ {
+				// 新名称也不能与其他类别的原名相同
+				if candidate := fmt.Sprintf("%s (%d)", strings.TrimSpace(c.name), n); !used[nameKey(candidate)] {
+					name, key = candidate, nameKey(candidate)
+				}
+			}
+			kept[key] = true
+			if name != c.name {
+				log.Printf("用户数据库迁移：类别 %d 与其他类别重名，%q 改名为 %q", c.id, c.name, name)
+			}
+		}
+		if _, err := tx.Exec("UPDATE categories SET name = ?, name_key = ? WHERE id = ?", name, key, c.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserDBSchemaVersion 返回当前代码对应的用户数据库结构版本
+func UserDBSchemaVersion() int {
+	return len(userDBMigrations)
+}
+
+// 记录本进程中已经检查过结构版本的用户，避免每次请求都执行迁移检查
+var (
+	migratedUsers sync.Map
+	migrateMu     sync.Mutex
+)
+
+// ensureUserDBMigrated 每个用户在进程内只检查一次迁移
+func ensureUserDBMigrated(userID int64, db *sql.DB) error {
+	if _, ok := migratedUsers.Load(userID); ok {
+		return nil
+	}
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	if _, ok := migratedUsers.Load(userID); ok {
+		return nil
+	}
+	if err := migrateUserDB(db); err != nil {
+		return err
+	}
+	migratedUsers.Store(userID, true)
+	return nil
+}
+
+// migrateUserDB 在一个事务中执行所有尚未执行的迁移并更新版本号
+func migrateUserDB(db *sql.DB) error {
+	return migrateUserDBTo(db, len(userDBMigrations))
+}
+
+// migrateUserDBTo 执行迁移直到版本 target（恢复旧版本的备份时，先建到备份时的结构再写入数据）
+func migrateUserDBTo(db *sql.DB, target int) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if version >= target {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	for i := version; i < target; i++ {
+		for _, stmt := range userDBMigrations[i] {
+			if _, err := tx.Exec(stmt); err != nil {
+				return utils.WrapError(utils.ErrCreateTableFailed, fmt.Errorf("migration %d: %w", i+1, err))
+			}
+		}
+		if fn, ok := userDBMigrationFuncs[i+1]; ok {
+			if err := fn(tx); err != nil {
+				return utils.WrapError(utils.ErrCreateTableFailed, fmt.Errorf("migration %d: %w", i+1, err))
+			}
+		}
+	}
+	// PRAGMA 不支持参数占位符
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", target)); err != nil {
+		return utils.WrapError(utils.ErrCreateTableFailed, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrCreateTableFailed, err)
+	}
+	return nil
+}
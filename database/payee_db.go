@@ -0,0 +1,300 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// 商户的数据库操作
+// 商户的名称与别名共用一个命名空间：忽略大小写与多余空白后，不能与任何商户的名称或别名重复。
+// 用户数据库未开启外键约束，删除、合并商户时由这里负责处理别名、账单与自动分类规则。
+
+const payeeColumns = `p.id, p.name,
+	COALESCE((SELECT GROUP_CONCAT(a.alias, char(10)) FROM payee_aliases a WHERE a.payee_id = p.id), ''),
+	COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.kind, ''),
+	(SELECT COUNT(*) FROM transactions t WHERE t.payee_id = p.id AND t.deleted_at IS NULL), p.created_at`
+
+// 默认类别在回收站中时视为没有默认类别
+const payeeJoins = " FROM payees p LEFT JOIN categories c ON p.default_category_id = c.id AND c.deleted_at IS NULL"
+
+func scanPayee(row rowScanner) (*models.Payee, error) {
+	var p models.Payee
+	var aliases string
+	if err := row.Scan(&p.ID, &p.Name, &aliases, &p.DefaultCategoryID, &p.DefaultCategoryName, &p.DefaultCategoryKind,
+		&p.TransactionCount, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	// 名称与别名经过空白规范化，不含换行，可以用换行拼接
+	p.Aliases = []string{}
+	if aliases != "" {
+		p.Aliases = strings.Split(aliases, "\n")
+		sort.Strings(p.Aliases)
+	}
+	return &p, nil
+}
+
+// payeeNameKeys 返回全部商户（excludeID 的商户除外）的名称与别名规范化后的形式到商户 id 的映射
+func payeeNameKeys(db DBTX, excludeID int64) (map[string]int64, error) {
+	rows, err := db.Query(`
+SELECT id, name FROM payees WHERE id != ?
+UNION ALL
+SELECT payee_id, alias FROM payee_aliases WHERE payee_id != ?`, excludeID, excludeID)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	keys := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		keys[nameKey(name)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return keys, nil
+}
+
+// GetPayeeIDByName 按名称或别名查找商户（忽略大小写与多余空白），不存在返回 0
+func GetPayeeIDByName(db DBTX, name string) (int64, error) {
+	keys, err := payeeNameKeys(db, 0)
+	if err != nil {
+		return 0, err
+	}
+	return keys[nameKey(name)], nil
+}
+
+// checkPayeeNames 校验名称与别名没有被其他商户使用，重复时返回 ErrPayeeExists
+func checkPayeeNames(db DBTX, p *models.Payee) error {
+	keys, err := payeeNameKeys(db, p.ID)
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{p.Name}, p.Aliases...) {
+		if keys[nameKey(name)] != 0 {
+			return utils.ErrPayeeExists
+		}
+	}
+	return nil
+}
+
+// setPayeeAliases 把商户的别名替换为 aliases
+func setPayeeAliases(db DBTX, payeeID int64, aliases []string) error {
+	if _, err := db.Exec("DELETE FROM payee_aliases WHERE payee_id = ?", payeeID); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	for _, alias := range aliases {
+		if _, err := db.Exec("INSERT INTO payee_aliases (payee_id, alias) VALUES (?, ?)", payeeID, alias); err != nil {
+			return utils.WrapError(utils.ErrInsertFailed, err)
+		}
+	}
+	return nil
+}
+
+// CreatePayee 新增商户及其别名，返回插入的 ID；名称或别名已被使用时返回 ErrPayeeExists
+func CreatePayee(db DBTX, p *models.Payee) (int64, error) {
+	if err := checkPayeeNames(db, p); err != nil {
+		return 0, err
+	}
+	result, err := db.Exec("INSERT INTO payees (name, default_category_id) VALUES (?, ?)", p.Name, nullableID(p.DefaultCategoryID))
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if err := setPayeeAliases(db, id, p.Aliases); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetPayees 返回全部商户及其账单数，按名称排序
+func GetPayees(db DBTX) ([]models.Payee, error) {
+	rows, err := db.Query("SELECT " + payeeColumns + payeeJoins + " ORDER BY p.name, p.id")
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	payees := []models.Payee{}
+	for rows.Next() {
+		p, err := scanPayee(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		payees = append(payees, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return payees, nil
+}
+
+// GetPayeeByID 返回商户，不存在时返回 ErrPayeeNotFound
+func GetPayeeByID(db DBTX, payeeID int64) (*models.Payee, error) {
+	p, err := scanPayee(db.QueryRow("SELECT "+payeeColumns+payeeJoins+" WHERE p.id = ?", payeeID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrPayeeNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return p, nil
+}
+
+// UpdatePayee 在一个事务中更新商户的名称、默认类别与别名；名称或别名已被其他商户使用时返回 ErrPayeeExists
+func UpdatePayee(userDB *sql.DB, p *models.Payee) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	if err := checkPayeeNames(tx, p); err != nil {
+		return err
+	}
+	result, err := tx.Exec("UPDATE payees SET name = ?, default_category_id = ? WHERE id = ?",
+		p.Name, nullableID(p.DefaultCategoryID), p.ID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrPayeeNotFound
+	}
+	if err := setPayeeAliases(tx, p.ID, p.Aliases); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// DeletePayee 删除商户及其别名：账单不再关联商户，以该商户为条件的自动分类规则一并删除
+func DeletePayee(userDB *sql.DB, payeeID int64) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	result, err := tx.Exec("DELETE FROM payees WHERE id = ?", payeeID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrPayeeNotFound
+	}
+	for _, stmt := range []string{
+		"DELETE FROM payee_aliases WHERE payee_id = ?",
+		"UPDATE transactions SET payee_id = NULL WHERE payee_id = ?",
+		"DELETE FROM category_rule_tags WHERE rule_id IN (SELECT id FROM category_rules WHERE payee_id = ?)",
+		"DELETE FROM category_rules WHERE payee_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, payeeID); err != nil {
+			return utils.WrapError(utils.ErrDeleteFailed, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// MergePayees 在一个事务中把 sourceIDs 商户合并到 targetID：账单与自动分类规则改为引用目标商户，
+// 来源商户的名称与别名成为目标商户的别名（以后记账时仍能识别），然后删除来源商户。调用方负责校验商户存在
+func MergePayees(userDB *sql.DB, sourceIDs []int64, targetID int64) (*models.PayeeMergeResult, error) {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	result := &models.PayeeMergeResult{TargetID: targetID, SourceIDs: sourceIDs}
+	exec := func(query string, args ...interface{}) (int64, error) {
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+		affected, _ := res.RowsAffected()
+		return affected, nil
+	}
+	for _, sourceID := range sourceIDs {
+		n, err := exec("UPDATE transactions SET payee_id = ? WHERE payee_id = ?", targetID, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		result.Transactions += n
+		if n, err = exec("UPDATE category_rules SET payee_id = ?, updated_at = CURRENT_TIMESTAMP WHERE payee_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		result.CategoryRules += n
+		if n, err = exec("UPDATE payee_aliases SET payee_id = ? WHERE payee_id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		result.AliasesAdded += n
+		if n, err = exec("INSERT INTO payee_aliases (payee_id, alias) SELECT ?, name FROM payees WHERE id = ?", targetID, sourceID); err != nil {
+			return nil, err
+		}
+		result.AliasesAdded += n
+		if _, err = exec("DELETE FROM payees WHERE id = ?", sourceID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return result, nil
+}
+
+// GetPayeeSpending 按商户统计 [start, end) 内的支出（start、end 为空表示不限），
+// 按支出金额从大到小返回前 limit 个商户；没有商户的账单不计入
+func GetPayeeSpending(userDB *sql.DB, start string, end string, limit int) ([]models.PayeeStat, error) {
+	querySQL := `
+SELECT p.id, p.name, COALESCE(SUM(t.amount), 0), COUNT(*)
+FROM transactions t
+JOIN payees p ON t.payee_id = p.id
+WHERE t.type = 'expense' AND t.deleted_at IS NULL
+AND (? = '' OR t.occurred_at >= ?)
+AND (? = '' OR t.occurred_at < ?)
+GROUP BY p.id
+ORDER BY 3, 4 DESC, p.name
+LIMIT ?`
+	rows, err := userDB.Query(querySQL, start, start, end, end, limit)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	stats := []models.PayeeStat{}
+	for rows.Next() {
+		var s models.PayeeStat
+		if err := rows.Scan(&s.PayeeID, &s.Name, &s.Expense, &s.TransactionCount); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		s.ExpenseStr = utils.CentsToYuanString(s.Expense)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+)
+
+// 周期账单规则的数据库操作
+
+const recurringRuleColumns = `r.id, r.type, r.amount, COALESCE(r.category_id, 0), COALESCE(c.name, ''),
+	COALESCE(r.account_id, 0), COALESCE(r.note, ''), r.frequency, r.interval, r.start_date,
+	COALESCE(r.end_date, ''), r.count, r.occurrences, COALESCE(r.next_run, ''), r.active,
+	r.created_at, r.updated_at`
+
+const recurringRuleJoins = " FROM recurring_rules r LEFT JOIN categories c ON r.category_id = c.id AND c.deleted_at IS NULL"
+
+func scanRecurringRule(row rowScanner) (*models.RecurringRule, error) {
+	var r models.RecurringRule
+	var updatedAt sql.NullString
+	if err := row.Scan(&r.ID, &r.Type, &r.Amount, &r.CategoryID, &r.CategoryName,
+		&r.AccountID, &r.Note, &r.Frequency, &r.Interval, &r.StartDate,
+		&r.EndDate, &r.Count, &r.Occurrences, &r.NextRun, &r.Active,
+		&r.CreatedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	r.UpdatedAt = updatedAt.String
+	return &r, nil
+}
+
+// CreateRecurringRule 新增周期账单规则，返回插入的 ID（next_run 由调用方计算）
+func CreateRecurringRule(userDB DBTX, r *models.RecurringRule) (int64, error) {
+	insertSQL := `
+INSERT INTO recurring_rules (type, amount, category_id, account_id, note, frequency, interval, start_date, end_date, count, next_run, active)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), ?)`
+	result, err := userDB.Exec(insertSQL, r.Type, r.Amount, nullableID(r.CategoryID), nullableID(r.AccountID), r.Note,
+		r.Frequency, r.Interval, r.StartDate, r.EndDate, r.Count, r.NextRun, r.Active)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+// GetRecurringRules 返回所有周期账单规则
+func GetRecurringRules(userDB *sql.DB) ([]models.RecurringRule, error) {
+	return queryRecurringRules(userDB, "SELECT "+recurringRuleColumns+recurringRuleJoins+" ORDER BY r.id")
+}
+
+// GetDueRecurringRules 返回启用中且下一次发生时间不晚于 now 的规则
+func GetDueRecurringRules(userDB *sql.DB, now string) ([]models.RecurringRule, error) {
+	return queryRecurringRules(userDB, "SELECT "+recurringRuleColumns+recurringRuleJoins+
+		" WHERE r.active = 1 AND r.next_run IS NOT NULL AND r.next_run <= ? ORDER BY r.id", now)
+}
+
+func queryRecurringRules(userDB *sql.DB, query string, args ...interface{}) ([]models.RecurringRule, error) {
+	rows, err := userDB.Query(query, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	rules := []models.RecurringRule{}
+	for rows.Next() {
+		r, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		rules = append(rules, *r)
+	}
+	return rules, nil
+}
+
+// GetRecurringRuleByID 返回规则，不存在时返回 ErrRecurringRuleNotFound
+func GetRecurringRuleByID(userDB DBTX, ruleID int64) (*models.RecurringRule, error) {
+	r, err := scanRecurringRule(userDB.QueryRow("SELECT "+recurringRuleColumns+recurringRuleJoins+" WHERE r.id = ?", ruleID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrRecurringRuleNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return r, nil
+}
+
+// UpdateRecurringRule 整体更新规则的可编辑字段（已生成次数 occurrences 只由生成过程修改）
+func UpdateRecurringRule(userDB DBTX, r *models.RecurringRule) error {
+	updateSQL := `
+UPDATE recurring_rules SET type = ?, amount = ?, category_id = ?, account_id = ?, note = ?,
+	frequency = ?, interval = ?, start_date = ?, end_date = NULLIF(?, ''), count = ?,
+	next_run = NULLIF(?, ''), active = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?`
+	result, err := userDB.Exec(updateSQL, r.Type, r.Amount, nullableID(r.CategoryID), nullableID(r.AccountID), r.Note,
+		r.Frequency, r.Interval, r.StartDate, r.EndDate, r.Count, r.NextRun, r.Active, r.ID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrRecurringRuleNotFound
+	}
+	return nil
+}
+
+// DeleteRecurringRule 删除规则；已生成的账单保留
+func DeleteRecurringRule(userDB *sql.DB, ruleID int64) error {
+	result, err := userDB.Exec("DELETE FROM recurring_rules WHERE id = ?", ruleID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrRecurringRuleNotFound
+	}
+	return nil
+}
+
+// MaterializeRecurringRule 写入规则新生成的账单，并把已生成次数从 fromOccurrences
+// 推进到 fromOccurrences+len(txs)、更新下一次发生时间（nextRun 为空表示规则已结束），返回写入的账单 id。
+// 规则的已生成次数已不等于 fromOccurrences 时说明别处已处理过，直接返回 nil，保证不会重复生成。调用方负责事务
+func MaterializeRecurringRule(tx DBTX, ruleID int64, fromOccurrences int, txs []models.Transaction, nextRun string) ([]int64, error) {
+	result, err := tx.Exec(`
+UPDATE recurring_rules SET occurrences = ?, next_run = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND occurrences = ?`, fromOccurrences+len(txs), nextRun, ruleID, fromOccurrences)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(txs))
+	for i := range txs {
+		id, err := RecordTransaction(tx, &txs[i])
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+)
+
+// 用户级别设置的数据库操作（键值对，值统一存为文本）
+
+// GetSetting 返回设置的值，未设置过时 ok 为 false
+func GetSetting(userDB DBTX, key string) (value string, ok bool, err error) {
+	err = userDB.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return value, true, nil
+}
+
+// SetSetting 新增或覆盖设置的值
+func SetSetting(userDB DBTX, key string, value string) error {
+	if _, err := userDB.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
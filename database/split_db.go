@@ -0,0 +1,104 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"strings"
+)
+
+// 拆分账单的数据库操作
+
+// transactionLinesSQL 按类别统计时使用的账单行（子查询，需加别名）：有拆分的账单按各拆分行计，
+// 其他账单按自身计。列：id（账单 id）、type、category_id、amount、occurred_at。
+// 回收站中的账单不计入；类别在回收站中的行 category_id 为 NULL（按未分类计）
+const transactionLinesSQL = `(
+	SELECT t.id, t.type, c.id AS category_id, s.amount, t.occurred_at
+	FROM transactions t JOIN transaction_splits s ON s.transaction_id = t.id
+	LEFT JOIN categories c ON s.category_id = c.id AND c.deleted_at IS NULL
+	WHERE t.deleted_at IS NULL
+	UNION ALL
+	SELECT t.id, t.type, c.id, t.amount, t.occurred_at
+	FROM transactions t
+	LEFT JOIN categories c ON t.category_id = c.id AND c.deleted_at IS NULL
+	WHERE t.deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id)
+)`
+
+// hasSplitsSQL 账单（别名 t）是否有拆分行的条件
+const hasSplitsSQL = "EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id)"
+
+// GetTransactionSplits 返回账单的拆分行（按账单 id 分组，每组按 id 排序）；transactionIDs 为 nil 时返回全部账单的拆分行
+func GetTransactionSplits(db DBTX, transactionIDs []int64) (map[int64][]models.TransactionSplit, error) {
+	splits := make(map[int64][]models.TransactionSplit)
+	querySQL := `
+SELECT s.id, s.transaction_id, COALESCE(c.id, 0), COALESCE(c.name, '其他'), s.amount, COALESCE(s.note, '')
+FROM transaction_splits s LEFT JOIN categories c ON s.category_id = c.id AND c.deleted_at IS NULL`
+	var args []interface{}
+	if transactionIDs != nil {
+		if len(transactionIDs) == 0 {
+			return splits, nil
+		}
+		querySQL += " WHERE s.transaction_id IN (?" + strings.Repeat(", ?", len(transactionIDs)-1) + ")"
+		for _, id := range transactionIDs {
+			args = append(args, id)
+		}
+	}
+	querySQL += " ORDER BY s.transaction_id, s.id"
+
+	rows, err := db.Query(querySQL, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.TransactionSplit
+		if err := rows.Scan(&s.ID, &s.TransactionID, &s.CategoryID, &s.CategoryName, &s.Amount, &s.Note); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		s.AmountStr = utils.CentsToYuanString(s.Amount)
+		splits[s.TransactionID] = append(splits[s.TransactionID], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return splits, nil
+}
+
+// SetTransactionSplits 用 splits 替换账单的全部拆分行（空表示取消拆分），由调用方保证金额之和与账单一致
+func SetTransactionSplits(db DBTX, transactionID int64, splits []models.TransactionSplit) error {
+	if _, err := db.Exec("DELETE FROM transaction_splits WHERE transaction_id = ?", transactionID); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	for _, s := range splits {
+		if _, err := db.Exec("INSERT INTO transaction_splits (transaction_id, category_id, amount, note) VALUES (?, ?, ?, NULLIF(?, ''))",
+			transactionID, nullableID(s.CategoryID), s.Amount, s.Note); err != nil {
+			return utils.WrapError(utils.ErrInsertFailed, err)
+		}
+	}
+	return nil
+}
+
+// NegateTransactionSplits 把账单全部拆分行的金额改为相反数（账单在收入、支出之间互换时使用），
+// 拆分行的类别保持不变（包括回收站中的类别）
+func NegateTransactionSplits(db DBTX, transactionID int64) error {
+	if _, err := db.Exec("UPDATE transaction_splits SET amount = -amount WHERE transaction_id = ?", transactionID); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// attachTransactionSplits 为账单列表填充拆分行
+func attachTransactionSplits(db DBTX, transactions []models.DisplayTransaction) error {
+	ids := make([]int64, 0, len(transactions))
+	for _, t := range transactions {
+		ids = append(ids, t.ID)
+	}
+	splits, err := GetTransactionSplits(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range transactions {
+		transactions[i].Splits = splits[transactions[i].ID]
+	}
+	return nil
+}
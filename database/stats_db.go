@@ -7,10 +7,12 @@ import (
 )
 
 // 统计相关业务
+// 注意：转账（type = 'transfer'）只是资金在账户间移动，不计入任何收入/支出统计，只影响账户余额
+// 回收站中的账单（deleted_at 不为 NULL）不计入任何统计
 // 1. 获取总收入(coalesce意味合并)
 func GetTotalIncome(userDB *sql.DB) (int64, error) {
 	var result int64
-	selectSQL := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE amount > 0 "
+	selectSQL := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE amount > 0 AND type != 'transfer' AND deleted_at IS NULL"
 	err := userDB.QueryRow(selectSQL).Scan(&result)
 	if err != nil {
 		return 0, utils.WrapError(utils.ErrQueryFailed, err)
@@ -21,7 +23,7 @@ func GetTotalIncome(userDB *sql.DB) (int64, error) {
 // 2. 获取总支出
 func GetTotalExpenditure(userDB *sql.DB) (int64, error) {
 	var result int64
-	selectSQL := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE amount < 0 "
+	selectSQL := "SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE amount < 0 AND type != 'transfer' AND deleted_at IS NULL"
 	err := userDB.QueryRow(selectSQL).Scan(&result)
 	if err != nil {
 		return 0, utils.WrapError(utils.ErrQueryFailed, err)
@@ -49,7 +51,8 @@ SELECT
 	COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END),0) AS total_expense,
 	COALESCE(SUM(amount),0) AS net_income
 FROM transactions
-WHERE strftime('%Y-%m', created_at) = strftime('%Y-%m','now','localtime')
+WHERE strftime('%Y-%m', occurred_at) = strftime('%Y-%m','now','localtime')
+AND type != 'transfer' AND deleted_at IS NULL
 `
 	var total_income int64
 	var total_expense int64
@@ -70,7 +73,8 @@ SELECT
 	COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END),0) AS total_expense,
 	COALESCE(SUM(amount),0) AS net_income
 FROM transactions
-WHERE strftime('%Y-%W', created_at) = strftime('%Y-%W','now','localtime')
+WHERE strftime('%Y-%W', occurred_at) = strftime('%Y-%W','now','localtime')
+AND type != 'transfer' AND deleted_at IS NULL
 `
 	var total_income int64
 	var total_expense int64
@@ -90,7 +94,8 @@ SELECT
 	COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END),0) AS total_expense,
 	COALESCE(SUM(amount),0) AS net_income
 FROM transactions
-WHERE date(created_at) = date('now','localtime')
+WHERE date(occurred_at) = date('now','localtime')
+AND type != 'transfer' AND deleted_at IS NULL
 `
 	var total_income int64
 	var total_expense int64
@@ -102,11 +107,12 @@ WHERE date(created_at) = date('now','localtime')
 	return total_income, total_expense, net_income, nil
 }
 
-// 金额范围统计（按区间分组，返回每组的计数与总额）
+// 金额范围统计（按区间分组，返回每组的计数与总额）；拆分账单的金额按各拆分行分别计入，
+// 账单数按笔计（同一区间内的多行只计一次）
 func GetRangeAmountStats(userDB *sql.DB) ([]models.RangeAmountStat, error) {
 	// 注意：amount 单位为“分”，阈值 10000 表示 100.00 元
 	querySQL := `
-SELECT amount_range, COUNT(*) AS transaction_count, COALESCE(SUM(amount),0) AS total_amount
+SELECT amount_range, COUNT(DISTINCT id) AS transaction_count, COALESCE(SUM(amount),0) AS total_amount
 FROM (
   SELECT
 	CASE
@@ -115,8 +121,9 @@ FROM (
 	  WHEN amount > -10000 THEN '小额支出'
 	  ELSE '大额支出'
 	END AS amount_range,
-	amount
-  FROM transactions
+	id, amount
+  FROM ` + transactionLinesSQL + ` l
+  WHERE type != 'transfer'
 ) t
 GROUP BY amount_range
 ORDER BY total_amount DESC
@@ -145,3 +152,87 @@ ORDER BY total_amount DESC
 	}
 	return rangeStats, nil
 }
+
+// 账户余额统计：期初余额 + 截至 asOf（不包含，空表示全部）的流入与流出
+func GetAccountBalances(userDB *sql.DB, asOf string) ([]models.AccountBalance, error) {
+	querySQL := `
+SELECT
+	a.id, a.name, a.kind, a.currency, a.archived, a.opening_balance,
+	COALESCE(SUM(CASE WHEN t.amount > 0 THEN t.amount ELSE 0 END),0) AS inflow,
+	COALESCE(SUM(CASE WHEN t.amount < 0 THEN t.amount ELSE 0 END),0) AS outflow,
+	COUNT(t.id) AS transaction_count
+FROM accounts a
+LEFT JOIN transactions t ON t.account_id = a.id AND t.deleted_at IS NULL AND (? = '' OR t.occurred_at < ?)
+GROUP BY a.id
+ORDER BY a.archived, a.id
+`
+	rows, err := userDB.Query(querySQL, asOf, asOf)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	balances := []models.AccountBalance{}
+	for rows.Next() {
+		var b models.AccountBalance
+		if err := rows.Scan(&b.AccountID, &b.Name, &b.Kind, &b.Currency, &b.Archived, &b.OpeningBalance,
+			&b.Inflow, &b.Outflow, &b.TransactionCount); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		b.Balance = b.OpeningBalance + b.Inflow + b.Outflow
+		b.BalanceStr = utils.CentsToYuanString(b.Balance)
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
+
+// GetCategoryAmounts 按类别统计 [start, end) 内某一收支类型的账单（start、end 为空表示不限），
+// 返回每个类别直接记在其下的账单数与金额（分，带符号），键 0 表示未分类。
+// 拆分账单的金额按各拆分行计入其类别，账单数按笔计（同一类别下的多行只计一次），
+// 并在 SplitIDs 中给出这些拆分账单的 id，供按类别树汇总时去重
+func GetCategoryAmounts(userDB *sql.DB, transactionType string, start string, end string) (map[int64]models.CategoryStat, error) {
+	const linesSQL = `
+FROM ` + transactionLinesSQL + ` l
+WHERE type = ?
+AND (? = '' OR occurred_at >= ?)
+AND (? = '' OR occurred_at < ?)`
+	args := []interface{}{transactionType, start, start, end, end}
+	querySQL := "SELECT COALESCE(category_id, 0) AS cid, COUNT(DISTINCT id), COALESCE(SUM(amount), 0)" + linesSQL + " GROUP BY cid"
+	rows, err := userDB.Query(querySQL, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	amounts := make(map[int64]models.CategoryStat)
+	for rows.Next() {
+		var s models.CategoryStat
+		if err := rows.Scan(&s.CategoryID, &s.OwnCount, &s.OwnAmount); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		amounts[s.CategoryID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+
+	splitRows, err := userDB.Query("SELECT DISTINCT COALESCE(category_id, 0), id"+linesSQL+
+		" AND EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = l.id)", args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer splitRows.Close()
+	for splitRows.Next() {
+		var categoryID, transactionID int64
+		if err := splitRows.Scan(&categoryID, &transactionID); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		s := amounts[categoryID]
+		s.SplitIDs = append(s.SplitIDs, transactionID)
+		amounts[categoryID] = s
+	}
+	if err := splitRows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return amounts, nil
+}
@@ -0,0 +1,288 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// 标签的数据库操作
+// 标签与账单、自动分类规则通过 transaction_tags、category_rule_tags 多对多关联；
+// 用户数据库未开启外键约束，删除账单、规则或标签时由这里负责清理关联。
+
+// splitTagNames 把查询中以 GROUP_CONCAT(name, ',') 拼接的标签名称（标签名称不含逗号）
+// 还原为按名称排序的列表，没有标签时为空列表
+func splitTagNames(joined string) []string {
+	if joined == "" {
+		return []string{}
+	}
+	names := strings.Split(joined, ",")
+	sort.Strings(names)
+	return names
+}
+
+// findTagByNameKey 按规范化后的名称查找标签（excludeID 的标签除外），不存在返回 0
+func findTagByNameKey(db DBTX, name string, excludeID int64) (int64, error) {
+	rows, err := db.Query("SELECT id, name FROM tags WHERE id != ? ORDER BY id", excludeID)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	key := nameKey(name)
+	for rows.Next() {
+		var id int64
+		var existing string
+		if err := rows.Scan(&id, &existing); err != nil {
+			return 0, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		if nameKey(existing) == key {
+			return id, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return 0, nil
+}
+
+// GetTagIDByName 按名称查找标签（忽略大小写与多余空白），不存在返回 0
+func GetTagIDByName(db DBTX, name string) (int64, error) {
+	return findTagByNameKey(db, name, 0)
+}
+
+// CreateTag 新增标签，返回插入的 ID；名称已存在时返回 ErrTagExists
+func CreateTag(db DBTX, name string) (int64, error) {
+	existing, err := findTagByNameKey(db, name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if existing != 0 {
+		return 0, utils.ErrTagExists
+	}
+	result, err := db.Exec("INSERT INTO tags (name) VALUES (?)", name)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return id, nil
+}
+
+const tagColumns = `g.id, g.name,
+	(SELECT COUNT(*) FROM transaction_tags l JOIN transactions t ON l.transaction_id = t.id
+		WHERE l.tag_id = g.id AND t.deleted_at IS NULL), g.created_at`
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	var t models.Tag
+	if err := row.Scan(&t.ID, &t.Name, &t.TransactionCount, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTags 返回全部标签及其账单数，按名称排序
+func GetTags(db DBTX) ([]models.Tag, error) {
+	rows, err := db.Query("SELECT " + tagColumns + " FROM tags g ORDER BY g.name, g.id")
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		tags = append(tags, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return tags, nil
+}
+
+// GetTagByID 返回标签，不存在时返回 ErrTagNotFound
+func GetTagByID(db DBTX, tagID int64) (*models.Tag, error) {
+	t, err := scanTag(db.QueryRow("SELECT "+tagColumns+" FROM tags g WHERE g.id = ?", tagID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrTagNotFound
+		}
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return t, nil
+}
+
+// RenameTag 修改标签名称；名称与其他标签重复时返回 ErrTagExists
+func RenameTag(userDB *sql.DB, tagID int64, name string) error {
+	existing, err := findTagByNameKey(userDB, name, tagID)
+	if err != nil {
+		return err
+	}
+	if existing != 0 {
+		return utils.ErrTagExists
+	}
+	result, err := userDB.Exec("UPDATE tags SET name = ? WHERE id = ?", name, tagID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrTagNotFound
+	}
+	return nil
+}
+
+// DeleteTag 删除标签及其与账单、规则的关联（账单本身不受影响）
+func DeleteTag(userDB *sql.DB, tagID int64) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	result, err := tx.Exec("DELETE FROM tags WHERE id = ?", tagID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrTagNotFound
+	}
+	for _, stmt := range []string{
+		"DELETE FROM transaction_tags WHERE tag_id = ?",
+		"DELETE FROM category_rule_tags WHERE tag_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, tagID); err != nil {
+			return utils.WrapError(utils.ErrDeleteFailed, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// MergeTags 在一个事务中把 sourceIDs 标签合并到 targetID：带来源标签的账单与规则改为带目标标签
+// （已带目标标签的不重复添加），然后删除来源标签。调用方负责校验标签存在
+func MergeTags(userDB *sql.DB, sourceIDs []int64, targetID int64) (*models.TagMergeResult, error) {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	result := &models.TagMergeResult{TargetID: targetID, SourceIDs: sourceIDs}
+	for _, sourceID := range sourceIDs {
+		res, err := tx.Exec(`INSERT OR IGNORE INTO transaction_tags (transaction_id, tag_id)
+SELECT transaction_id, ? FROM transaction_tags WHERE tag_id = ?`, targetID, sourceID)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+		n, _ := res.RowsAffected()
+		result.Transactions += n
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO category_rule_tags (rule_id, tag_id)
+SELECT rule_id, ? FROM category_rule_tags WHERE tag_id = ?`, targetID, sourceID); err != nil {
+			return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+		for _, stmt := range []string{
+			"DELETE FROM transaction_tags WHERE tag_id = ?",
+			"DELETE FROM category_rule_tags WHERE tag_id = ?",
+			"DELETE FROM tags WHERE id = ?",
+		} {
+			if _, err := tx.Exec(stmt, sourceID); err != nil {
+				return nil, utils.WrapError(utils.ErrDeleteFailed, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return result, nil
+}
+
+// SetTransactionTags 把账单的标签替换为 tagIDs（为空表示清空）
+func SetTransactionTags(db DBTX, transactionID int64, tagIDs []int64) error {
+	if _, err := db.Exec("DELETE FROM transaction_tags WHERE transaction_id = ?", transactionID); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return AddTransactionTags(db, transactionID, tagIDs)
+}
+
+// AddTransactionTags 给账单加上标签（已有的标签忽略）
+func AddTransactionTags(db DBTX, transactionID int64, tagIDs []int64) error {
+	for _, tagID := range tagIDs {
+		if _, err := db.Exec("INSERT OR IGNORE INTO transaction_tags (transaction_id, tag_id) VALUES (?, ?)",
+			transactionID, tagID); err != nil {
+			return utils.WrapError(utils.ErrInsertFailed, err)
+		}
+	}
+	return nil
+}
+
+// SetCategoryRuleTags 把自动分类规则匹配后添加的标签替换为 tagIDs
+func SetCategoryRuleTags(db DBTX, ruleID int64, tagIDs []int64) error {
+	if _, err := db.Exec("DELETE FROM category_rule_tags WHERE rule_id = ?", ruleID); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	for _, tagID := range tagIDs {
+		if _, err := db.Exec("INSERT OR IGNORE INTO category_rule_tags (rule_id, tag_id) VALUES (?, ?)",
+			ruleID, tagID); err != nil {
+			return utils.WrapError(utils.ErrInsertFailed, err)
+		}
+	}
+	return nil
+}
+
+// GetTagAmounts 按标签统计 [start, end) 内的收入与支出（start、end 为空表示不限，转账不计入），
+// 只返回有账单的标签，按支出金额从大到小排列
+func GetTagAmounts(userDB *sql.DB, start string, end string) ([]models.TagStat, error) {
+	querySQL := `
+SELECT g.id, g.name,
+	COALESCE(SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN t.type = 'expense' THEN t.amount ELSE 0 END), 0),
+	COUNT(*)
+FROM transaction_tags l
+JOIN tags g ON l.tag_id = g.id
+JOIN transactions t ON l.transaction_id = t.id
+WHERE t.type IN ('income', 'expense') AND t.deleted_at IS NULL
+AND (? = '' OR t.occurred_at >= ?)
+AND (? = '' OR t.occurred_at < ?)
+GROUP BY g.id
+ORDER BY 4, 3 DESC, g.name`
+	rows, err := userDB.Query(querySQL, start, start, end, end)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	stats := []models.TagStat{}
+	for rows.Next() {
+		var s models.TagStat
+		if err := rows.Scan(&s.TagID, &s.Name, &s.Income, &s.Expense, &s.TransactionCount); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		s.Net = s.Income + s.Expense
+		s.IncomeStr = utils.CentsToYuanString(s.Income)
+		s.ExpenseStr = utils.CentsToYuanString(s.Expense)
+		s.NetStr = utils.CentsToYuanString(s.Net)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return stats, nil
+}
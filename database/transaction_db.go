@@ -4,21 +4,23 @@ import (
 	"AccountingAssistant/models"
 	"AccountingAssistant/utils"
 	"database/sql"
+	"errors"
 	"strings"
 )
 
 // CRUD数据库操作
-// 1. 记录账单（CategoryID 可以为 nil）
-func RecordTransaction(userDB *sql.DB, Type string, Amount int64, CategoryID *int64, Note string) (int64, error) {
+// 1. 记录账单（CategoryID、AccountID、PayeeID 为 0 时存为 NULL，OccurredAt 为本地时间 "2006-01-02 15:04:05"，为空时取当前时间）
+func RecordTransaction(db DBTX, t *models.Transaction) (int64, error) {
 
-	insertSQL := "INSERT INTO transactions (type, amount, category_id, note) VALUES (?, ?, ?, ?)"
-	var cid interface{}
-	if CategoryID == nil {
-		cid = nil
-	} else {
-		cid = *CategoryID
+	insertSQL := `
+INSERT INTO transactions (type, amount, category_id, account_id, payee_id, transfer_id, recurring_rule_id, recurring_seq, external_id, note, occurred_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, COALESCE(NULLIF(?, ''), datetime('now', 'localtime')), CURRENT_TIMESTAMP)`
+	var recurringSeq interface{}
+	if t.RecurringRuleID != 0 {
+		recurringSeq = t.RecurringSeq
 	}
-	result, err := userDB.Exec(insertSQL, Type, Amount, cid, Note)
+	result, err := db.Exec(insertSQL, t.Type, t.Amount, nullableID(t.CategoryID), nullableID(t.AccountID), nullableID(t.PayeeID), nullableID(t.TransferID),
+		nullableID(t.RecurringRuleID), recurringSeq, t.ExternalID, t.Note, t.OccurredAt)
 	if err != nil {
 		return 0, utils.WrapError(utils.ErrInsertFailed, err)
 	}
@@ -29,49 +31,167 @@ func RecordTransaction(userDB *sql.DB, Type string, Amount int64, CategoryID *in
 	return transactionId, nil
 }
 
-// 2. 获取账单（含类别名，未分类显示为 "其他"）
-func GetTransaction(userDB *sql.DB) ([]models.DisplayTransaction, error) {
+// ExternalIDExists 判断导入来源编号是否已经导入过
+func ExternalIDExists(db DBTX, externalID string) (bool, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transactions WHERE external_id = ?", externalID).Scan(&count); err != nil {
+		return false, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return count > 0, nil
+}
+
+// 2. 获取账单（含类别名，未分类显示为 "其他"），支持筛选、排序与分页
+func GetTransaction(userDB *sql.DB, filter models.TransactionFilter) (*models.TransactionPage, error) {
+	sortBy, sortColumn, order, err := normalizeSort(filter)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	where, args := buildTransactionWhere(filter)
+	fromSQL := displayTransactionJoins + `
+WHERE ` + where
+
+	// 总数（不受分页影响）
+	var total int64
+	if err := userDB.QueryRow("SELECT COUNT(*) "+fromSQL, args...).Scan(&total); err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+
+	// 游标分页：从上一页最后一行之后开始
+	pageSQL := fromSQL
+	pageArgs := append([]interface{}{}, args...)
+	offset := filter.Offset
+	if filter.Cursor != "" {
+		cur, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// 游标必须与本次排序方式一致，否则结果会错乱
+		if cur.SortBy != sortBy || cur.Order != order {
+			return nil, utils.ErrInvalidParameter
+		}
+		value, err := cursorValueArg(sortBy, cur.Value)
+		if err != nil {
+			return nil, err
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		pageSQL += " AND (" + sortColumn + " " + cmp + " ? OR (" + sortColumn + " = ? AND t.id " + cmp + " ?))"
+		pageArgs = append(pageArgs, value, value, cur.ID)
+		offset = 0
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 多取一行用于判断是否还有下一页
 	querySQL := `
-SELECT
-	t.id, t.type, t.amount,
-	COALESCE(c.name, '其他') as category_name,
-	t.note, t.created_at
-FROM transactions t
-LEFT JOIN categories c ON t.category_id = c.id
-ORDER BY t.created_at DESC
-`
-	rows, err := userDB.Query(querySQL)
+SELECT ` + displayTransactionColumns + `,
+	CAST(` + sortColumn + ` AS TEXT) AS sort_key ` + pageSQL + `
+ORDER BY ` + sortColumn + " " + order + ", t.id " + order + `
+LIMIT ? OFFSET ?`
+	pageArgs = append(pageArgs, limit+1, offset)
+
+	rows, err := userDB.Query(querySQL, pageArgs...)
 	if err != nil {
 		return nil, utils.WrapError(utils.ErrQueryFailed, err)
 	}
 	defer rows.Close()
 
-	var Transactions []models.DisplayTransaction
-	var cents int64
+	page := &models.TransactionPage{Total: total, Transactions: []models.DisplayTransaction{}}
+	var sortKey, lastSortKey string
 	for rows.Next() {
-		var t models.DisplayTransaction
-		if err := rows.Scan(&t.ID, &t.Type, &cents, &t.CategoryName, &t.Note, &t.CreatedAt); err != nil {
-			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		t, _, err := scanDisplayTransaction(rows, &sortKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Transactions) == limit {
+			// 存在第 limit+1 行，说明还有下一页，用本页最后一行生成游标
+			last := page.Transactions[limit-1]
+			page.NextPageToken = encodeCursor(pageCursor{SortBy: sortBy, Order: order, Value: lastSortKey, ID: last.ID})
+			break
 		}
-		t.Amount = utils.CentsToYuanString(cents)
-		Transactions = append(Transactions, t)
+		page.Transactions = append(page.Transactions, t)
+		lastSortKey = sortKey
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	rows.Close()
+	if err := attachTransactionSplits(userDB, page.Transactions); err != nil {
+		return nil, err
 	}
 
-	return Transactions, nil
+	return page, nil
 }
 
-// 3. 删除账单
-func DeleteTransaction(userDB *sql.DB, transactionID int64) error {
-	deleteSQL := "DELETE FROM transactions WHERE id = ?"
-	_, err := userDB.Exec(deleteSQL, transactionID)
+// EachTransaction 按筛选与排序条件逐行读取全部账单（不分页，供导出使用），每行调用一次 fn。
+// fn 返回错误时停止读取并返回该错误。
+func EachTransaction(userDB *sql.DB, filter models.TransactionFilter, fn func(t models.DisplayTransaction, cents int64) error) error {
+	_, sortColumn, order, err := normalizeSort(filter)
 	if err != nil {
-		return utils.WrapError(utils.ErrDeleteFailed, err)
+		return err
+	}
+	where, args := buildTransactionWhere(filter)
+	querySQL := `
+SELECT ` + displayTransactionColumns + displayTransactionJoins + `
+WHERE ` + where + `
+ORDER BY ` + sortColumn + " " + order + ", t.id " + order
+
+	rows, err := userDB.Query(querySQL, args...)
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, cents, err := scanDisplayTransaction(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(t, cents); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return utils.WrapError(utils.ErrReadFailed, err)
 	}
 	return nil
 }
 
+// GetTransactionIDs 返回符合筛选条件的账单 id（按 id 排序，忽略排序与分页参数）。
+// 超过 limit 条时只返回前 limit+1 条，调用方据此判断是否超出上限
+func GetTransactionIDs(db DBTX, filter models.TransactionFilter, limit int) ([]int64, error) {
+	where, args := buildTransactionWhere(filter)
+	args = append(args, limit+1)
+	return queryIDs(db, "SELECT t.id"+displayTransactionJoins+"\nWHERE "+where+" ORDER BY t.id LIMIT ?", args...)
+}
+
+// transferGroupSQL 匹配账单本身及同一笔转账的另一条腿（参数为账单 id，出现两次）
+const transferGroupSQL = `(id = ?
+   OR transfer_id = (SELECT transfer_id FROM transactions WHERE id = ? AND transfer_id IS NOT NULL))`
+
+// 3. 删除账单：移到回收站（转账的两条腿作为整体一起移动），标签、拆分行与附件保留，恢复后原样可用
+func DeleteTransaction(userDB DBTX, transactionID int64) error {
+	result, err := userDB.Exec("UPDATE transactions SET deleted_at = CURRENT_TIMESTAMP WHERE deleted_at IS NULL AND "+transferGroupSQL,
+		transactionID, transactionID)
+	if err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return checkTransactionAffected(result)
+}
+
 // 4. 更新账单
-func UpdateTransaction(userDB *sql.DB, transactionID int64, updateType *string, updateAmount *int64, updateCategoryID *int64, updateNote *string) error {
+func UpdateTransaction(userDB DBTX, transactionID int64, updateType *string, updateAmount *int64, updateCategoryID *int64, updateAccountID *int64, updatePayeeID *int64, updateNote *string, updateOccurredAt *string) error {
 
 	// 构建动态SQL
 	var queryParts []string
@@ -87,39 +207,69 @@ func UpdateTransaction(userDB *sql.DB, transactionID int64, updateType *string,
 	}
 	if updateCategoryID != nil {
 		queryParts = append(queryParts, "category_id = ?")
-		args = append(args, *updateCategoryID)
+		args = append(args, nullableID(*updateCategoryID))
+	}
+	if updateAccountID != nil {
+		queryParts = append(queryParts, "account_id = ?")
+		args = append(args, nullableID(*updateAccountID))
+	}
+	if updatePayeeID != nil {
+		queryParts = append(queryParts, "payee_id = ?")
+		args = append(args, nullableID(*updatePayeeID))
 	}
 	if updateNote != nil {
 		queryParts = append(queryParts, "note = ?")
 		args = append(args, *updateNote)
 	}
+	if updateOccurredAt != nil {
+		queryParts = append(queryParts, "occurred_at = ?")
+		args = append(args, *updateOccurredAt)
+	}
 
 	// 如果没有要更新的字段
 	if len(queryParts) == 0 {
 		return nil // 或者返回一个错误，表示没有字段需要更新，但是我懒
 	}
 
-	// 添加WHERE条件
-	queryParts = append(queryParts, "id = ?")
+	// 有字段更新时同时刷新更新时间
+	queryParts = append(queryParts, "updated_at = CURRENT_TIMESTAMP")
+
+	// 添加WHERE条件（回收站中的账单不能修改）
+	queryParts = append(queryParts, "id = ? AND deleted_at IS NULL")
 	args = append(args, transactionID)
 
 	// 构建完整SQL ∑( 口 ||
 	query := "UPDATE transactions SET " + strings.Join(queryParts[:len(queryParts)-1], ", ") + " WHERE " + queryParts[len(queryParts)-1]
 
-	_, err := userDB.Exec(query, args...)
+	result, err := userDB.Exec(query, args...)
 	if err != nil {
 		return utils.WrapError(utils.ErrUpdateFailed, err)
 	}
+	return checkTransactionAffected(result)
+}
+
+// checkTransactionAffected 根据受影响行数判断账单是否存在
+func checkTransactionAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if affected == 0 {
+		return utils.ErrTransactionNotFound
+	}
 	return nil
 }
 
-// 添加: 获取单个交易的函数
-func GetTransactionByID(userDB *sql.DB, transactionID int64) (*models.Transaction, error) {
+// 添加: 获取单个交易的函数（回收站中的账单视为不存在；类别在回收站中时 CategoryID 为 0）
+func GetTransactionByID(userDB DBTX, transactionID int64) (*models.Transaction, error) {
 	var transaction models.Transaction
+	var updatedAt sql.NullString
 	err := userDB.QueryRow(
-		"SELECT id, type, amount, category_id, note, created_at FROM transactions WHERE id = ?",
+		`SELECT t.id, t.type, t.amount, COALESCE(c.id, 0), COALESCE(t.account_id, 0), COALESCE(t.payee_id, 0), COALESCE(t.transfer_id, 0), COALESCE(t.note, ''), t.occurred_at, t.created_at, t.updated_at
+FROM transactions t LEFT JOIN categories c ON t.category_id = c.id AND c.deleted_at IS NULL
+WHERE t.id = ? AND t.deleted_at IS NULL`,
 		transactionID,
-	).Scan(&transaction.ID, &transaction.Type, &transaction.Amount, &transaction.CategoryID, &transaction.Note, &transaction.CreatedAt)
+	).Scan(&transaction.ID, &transaction.Type, &transaction.Amount, &transaction.CategoryID, &transaction.AccountID, &transaction.PayeeID, &transaction.TransferID, &transaction.Note, &transaction.OccurredAt, &transaction.CreatedAt, &updatedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -127,5 +277,59 @@ func GetTransactionByID(userDB *sql.DB, transactionID int64) (*models.Transactio
 		}
 		return nil, utils.WrapError(utils.ErrQueryFailed, err)
 	}
+	transaction.UpdatedAt = updatedAt.String
 	return &transaction, nil
 }
+
+// 获取单个账单的展示信息（含类别名、账户名），回收站中的账单视为不存在
+func GetDisplayTransactionByID(userDB *sql.DB, transactionID int64) (*models.DisplayTransaction, error) {
+	querySQL := "SELECT " + displayTransactionColumns + displayTransactionJoins + " WHERE t.id = ? AND t.deleted_at IS NULL"
+	t, _, err := scanDisplayTransaction(userDB.QueryRow(querySQL, transactionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	splits, err := GetTransactionSplits(userDB, []int64{t.ID})
+	if err != nil {
+		return nil, err
+	}
+	t.Splits = splits[t.ID]
+	return &t, nil
+}
+
+// GetCategorizedHistory 返回最近 limit 笔已分类的收支账单（按发生时间倒序），用于类别建议；
+// transactionType 为空时返回收入和支出
+func GetCategorizedHistory(userDB *sql.DB, transactionType string, limit int) ([]models.Transaction, error) {
+	querySQL := `
+SELECT id, type, amount, category_id, COALESCE(note, ''), occurred_at FROM transactions
+WHERE category_id IN (SELECT id FROM categories WHERE deleted_at IS NULL)
+AND type IN ('income', 'expense') AND deleted_at IS NULL`
+	var args []interface{}
+	if transactionType != "" {
+		querySQL += " AND type = ?"
+		args = append(args, transactionType)
+	}
+	querySQL += " ORDER BY occurred_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := userDB.Query(querySQL, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	var history []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.Type, &t.Amount, &t.CategoryID, &t.Note, &t.OccurredAt); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		history = append(history, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return history, nil
+}
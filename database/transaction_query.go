@@ -0,0 +1,202 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// 账单列表的筛选、排序与分页
+// 排序字段只能从白名单中选择，避免把用户输入直接拼接进 SQL
+var transactionSortColumns = map[string]string{
+	"occurred_at": "t.occurred_at",
+	"created_at":  "t.created_at",
+	"amount":      "t.amount",
+	"id":          "t.id",
+	"deleted_at":  "t.deleted_at", // 只对回收站中的账单有意义
+}
+
+// 账单展示查询的公共列与连接，列顺序需与 scanDisplayTransaction 保持一致
+const displayTransactionColumns = `
+	t.id, t.type, t.amount, COALESCE(c.id, 0),
+	COALESCE(c.name, CASE WHEN t.type = 'transfer' THEN '转账' WHEN ` + hasSplitsSQL + ` THEN '拆分' ELSE '其他' END) AS category_name,
+	COALESCE(t.account_id, 0), COALESCE(a.name, '') AS account_name,
+	COALESCE(t.payee_id, 0), COALESCE(p.name, '') AS payee_name,
+	COALESCE(t.transfer_id, 0), COALESCE(t.recurring_rule_id, 0),
+	COALESCE((SELECT GROUP_CONCAT(g.name, ',') FROM transaction_tags l JOIN tags g ON l.tag_id = g.id
+		WHERE l.transaction_id = t.id), ''),
+	COALESCE(t.note, ''), t.occurred_at, t.created_at, t.updated_at, COALESCE(t.deleted_at, '')`
+
+// 回收站中的类别不参与连接，引用它的账单显示为未分类
+const displayTransactionJoins = `
+FROM transactions t
+LEFT JOIN categories c ON t.category_id = c.id AND c.deleted_at IS NULL
+LEFT JOIN accounts a ON t.account_id = a.id
+LEFT JOIN payees p ON t.payee_id = p.id`
+
+// scanDisplayTransaction 读取 displayTransactionColumns 对应的一行，extra 为追加在这些列之后的列。
+// 同时返回原始金额（分）。查询无结果时返回 sql.ErrNoRows（未包装），其他错误已包装。
+func scanDisplayTransaction(row rowScanner, extra ...interface{}) (models.DisplayTransaction, int64, error) {
+	var t models.DisplayTransaction
+	var cents int64
+	var tags string
+	var updatedAt sql.NullString
+	dest := []interface{}{&t.ID, &t.Type, &cents, &t.CategoryID, &t.CategoryName, &t.AccountID, &t.AccountName,
+		&t.PayeeID, &t.PayeeName, &t.TransferID, &t.RecurringRuleID, &tags, &t.Note, &t.OccurredAt, &t.CreatedAt, &updatedAt, &t.DeletedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return t, 0, err
+		}
+		return t, 0, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	t.Amount = utils.CentsToYuanString(cents)
+	t.Tags = splitTagNames(tags)
+	t.UpdatedAt = updatedAt.String
+	return t, cents, nil
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// 分页游标：记录上一页最后一行的排序值和 id（id 用于排序值相同时的二次排序）
+type pageCursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	Value  string `json:"v"`
+	ID     int64  `json:"id"`
+}
+
+func encodeCursor(cur pageCursor) string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, utils.ErrInvalidParameter
+	}
+	var cur pageCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, utils.ErrInvalidParameter
+	}
+	return &cur, nil
+}
+
+// buildTransactionWhere 根据筛选条件构建 WHERE 子句（不含 "WHERE" 关键字）
+// 查询需使用别名 t（transactions）、c（categories），即 displayTransactionJoins
+func buildTransactionWhere(f models.TransactionFilter) (string, []interface{}) {
+	conditions := []string{"t.deleted_at IS NULL"}
+	if f.Trashed {
+		conditions[0] = "t.deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+
+	if f.StartTime != "" {
+		conditions = append(conditions, "t.occurred_at >= ?")
+		args = append(args, f.StartTime)
+	}
+	if f.EndTime != "" {
+		conditions = append(conditions, "t.occurred_at < ?")
+		args = append(args, f.EndTime)
+	}
+	if f.Type != "" {
+		conditions = append(conditions, "t.type = ?")
+		args = append(args, f.Type)
+	}
+	if f.CategoryID != nil {
+		if *f.CategoryID == 0 {
+			// 未分类：没有类别（或类别在回收站中），且没有拆分
+			conditions = append(conditions, "c.id IS NULL AND NOT "+hasSplitsSQL)
+		} else {
+			// 包含子类别下的账单，以及有拆分行属于这些类别的账单
+			conditions = append(conditions, "(t.category_id IN "+categorySubtreeSQL+
+				" OR t.id IN (SELECT transaction_id FROM transaction_splits WHERE category_id IN "+categorySubtreeSQL+"))")
+			args = append(args, *f.CategoryID, *f.CategoryID)
+		}
+	}
+	if f.CategoryName != "" {
+		conditions = append(conditions, `(c.name = ? OR t.id IN (SELECT s.transaction_id FROM transaction_splits s
+	JOIN categories sc ON s.category_id = sc.id WHERE sc.name = ? AND sc.deleted_at IS NULL))`)
+		args = append(args, f.CategoryName, f.CategoryName)
+	}
+	if f.AccountID != nil {
+		if *f.AccountID == 0 {
+			conditions = append(conditions, "COALESCE(t.account_id, 0) = 0")
+		} else {
+			conditions = append(conditions, "t.account_id = ?")
+			args = append(args, *f.AccountID)
+		}
+	}
+	if f.PayeeID != nil {
+		if *f.PayeeID == 0 {
+			conditions = append(conditions, "COALESCE(t.payee_id, 0) = 0")
+		} else {
+			conditions = append(conditions, "t.payee_id = ?")
+			args = append(args, *f.PayeeID)
+		}
+	}
+	if f.MinAmount != nil {
+		conditions = append(conditions, "ABS(t.amount) >= ?")
+		args = append(args, *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		conditions = append(conditions, "ABS(t.amount) <= ?")
+		args = append(args, *f.MaxAmount)
+	}
+	if f.Note != "" {
+		// instr 区分大小写但不受 LIKE 通配符影响
+		conditions = append(conditions, "instr(COALESCE(t.note, ''), ?) > 0")
+		args = append(args, f.Note)
+	}
+	for _, tagID := range f.TagIDs {
+		conditions = append(conditions, "t.id IN (SELECT transaction_id FROM transaction_tags WHERE tag_id = ?)")
+		args = append(args, tagID)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// ValidateSort 校验筛选条件中的排序字段与方向，供需要在查询之前发现参数错误的调用方使用（如导出）
+func ValidateSort(f models.TransactionFilter) error {
+	_, _, _, err := normalizeSort(f)
+	return err
+}
+
+// normalizeSort 校验排序字段与方向，返回 SQL 列名与方向
+func normalizeSort(f models.TransactionFilter) (sortBy string, column string, order string, err error) {
+	sortBy = f.SortBy
+	if sortBy == "" {
+		sortBy = "occurred_at"
+	}
+	column, ok := transactionSortColumns[sortBy]
+	if !ok || (sortBy == "deleted_at" && !f.Trashed) {
+		return "", "", "", utils.ErrInvalidParameter
+	}
+	order = strings.ToLower(f.SortOrder)
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		return "", "", "", utils.ErrInvalidParameter
+	}
+	return sortBy, column, order, nil
+}
+
+// cursorValueArg 把游标中的排序值还原为查询参数（数值字段需按整数比较）
+func cursorValueArg(sortBy string, value string) (interface{}, error) {
+	if sortBy == "amount" || sortBy == "id" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, utils.ErrInvalidParameter
+		}
+		return n, nil
+	}
+	return value, nil
+}
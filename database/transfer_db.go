@@ -0,0 +1,123 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"strings"
+)
+
+// 转账的数据库操作
+// 一笔转账由两条 type = 'transfer' 的账单组成：转出腿（金额为负，account_id 为转出账户）
+// 与转入腿（金额为正，account_id 为转入账户），两条腿的 transfer_id 都等于转出腿的 id。
+
+// InsertTransferLegs 写入转账的两条腿（调用方负责事务），返回 transfer_id。
+// externalID 只记录在转出腿上，用于导入去重。
+func InsertTransferLegs(db DBTX, amount int64, fromAccountID int64, toAccountID int64, note string, occurredAt string, externalID string) (int64, error) {
+	outID, err := RecordTransaction(db, &models.Transaction{
+		Type: "transfer", Amount: -amount, AccountID: fromAccountID, Note: note, OccurredAt: occurredAt, ExternalID: externalID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := RecordTransaction(db, &models.Transaction{
+		Type: "transfer", Amount: amount, AccountID: toAccountID, TransferID: outID, Note: note, OccurredAt: occurredAt,
+	}); err != nil {
+		return 0, err
+	}
+	// 转出腿插入时还不知道自己的 id，插入后再补上
+	if _, err := db.Exec("UPDATE transactions SET transfer_id = ? WHERE id = ?", outID, outID); err != nil {
+		return 0, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return outID, nil
+}
+
+// GetTransferLegs 返回转账的转出腿与转入腿，不存在时返回 ErrTransactionNotFound
+func GetTransferLegs(userDB DBTX, transferID int64) (out *models.Transaction, in *models.Transaction, err error) {
+	rows, err := userDB.Query("SELECT id FROM transactions WHERE transfer_id = ?", transferID)
+	if err != nil {
+		return nil, nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		t, err := GetTransactionByID(userDB, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.Amount < 0 {
+			out = t
+		} else {
+			in = t
+		}
+	}
+	if out == nil || in == nil {
+		return nil, nil, utils.ErrTransactionNotFound
+	}
+	return out, in, nil
+}
+
+// UpdateTransfer 同时更新转账的两条腿（nil 表示不更新；amount 为正数），调用方负责事务
+func UpdateTransfer(db DBTX, transferID int64, amount *int64, fromAccountID *int64, toAccountID *int64, note *string, occurredAt *string) error {
+	// 两条腿共同的字段
+	var commonParts []string
+	var commonArgs []interface{}
+	if note != nil {
+		commonParts = append(commonParts, "note = ?")
+		commonArgs = append(commonArgs, *note)
+	}
+	if occurredAt != nil {
+		commonParts = append(commonParts, "occurred_at = ?")
+		commonArgs = append(commonArgs, *occurredAt)
+	}
+
+	// 构建一条腿的动态SQL（sign 为 -1 表示转出腿，1 表示转入腿）
+	buildLeg := func(sign int64, accountID *int64) (string, []interface{}) {
+		parts := append([]string{}, commonParts...)
+		args := append([]interface{}{}, commonArgs...)
+		if amount != nil {
+			parts = append(parts, "amount = ?")
+			args = append(args, sign*(*amount))
+		}
+		if accountID != nil {
+			parts = append(parts, "account_id = ?")
+			args = append(args, *accountID)
+		}
+		if len(parts) == 0 {
+			return "", nil
+		}
+		parts = append(parts, "updated_at = CURRENT_TIMESTAMP")
+		cmp := "<"
+		if sign > 0 {
+			cmp = ">"
+		}
+		args = append(args, transferID)
+		return "UPDATE transactions SET " + strings.Join(parts, ", ") + " WHERE transfer_id = ? AND deleted_at IS NULL AND amount " + cmp + " 0", args
+	}
+
+	for _, leg := range []struct {
+		sign      int64
+		accountID *int64
+	}{{-1, fromAccountID}, {1, toAccountID}} {
+		query, args := buildLeg(leg.sign, leg.accountID)
+		if query == "" {
+			continue
+		}
+		result, err := db.Exec(query, args...)
+		if err != nil {
+			return utils.WrapError(utils.ErrUpdateFailed, err)
+		}
+		if err := checkTransactionAffected(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
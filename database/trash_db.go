@@ -0,0 +1,178 @@
+package database
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+)
+
+// 回收站的数据库操作
+// 删除账单、类别时只记录 deleted_at，这里负责恢复与彻底删除。
+// 彻底删除账单时一并删除其标签关联、拆分行与附件记录（附件文件由 service 层清理）。
+
+// GetTrashedCategories 返回回收站中的类别，按删除时间倒序
+func GetTrashedCategories(userDB DBTX) ([]models.Category, error) {
+	querySQL := "SELECT " + categoryColumns + " FROM categories WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC"
+	rows, err := userDB.Query(querySQL)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return categories, nil
+}
+
+// RestoreTransaction 恢复回收站中的账单（转账的两条腿一起恢复），不在回收站中时返回 ErrTransactionNotFound
+func RestoreTransaction(userDB DBTX, transactionID int64) error {
+	result, err := userDB.Exec("UPDATE transactions SET deleted_at = NULL WHERE deleted_at IS NOT NULL AND "+transferGroupSQL,
+		transactionID, transactionID)
+	if err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return checkTransactionAffected(result)
+}
+
+// RestoreCategory 恢复回收站中的类别，不在回收站中时返回 ErrCategoryNotFound；
+// 名称已被其他类别使用时返回 ErrCategoryExists。上级类别保持不变（上级仍在回收站中时暂时按顶级类别显示），
+// 只有上级已不存在、或期间上级链中挂到了本类别之下（恢复会成环）时才恢复为顶级类别。调用方负责事务
+func RestoreCategory(tx DBTX, categoryID int64) error {
+	var name string
+	err := tx.QueryRow("SELECT name FROM categories WHERE id = ? AND deleted_at IS NOT NULL", categoryID).Scan(&name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.ErrCategoryNotFound
+		}
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	existing, err := findCategoryByNameKey(tx, name, categoryID)
+	if err != nil {
+		return err
+	}
+	if existing != 0 {
+		return utils.ErrCategoryExists
+	}
+	updateSQL := `
+UPDATE categories SET deleted_at = NULL,
+	parent_id = CASE WHEN parent_id IN (SELECT id FROM categories) AND ? NOT IN ` + categoryAncestorsSQL + ` THEN parent_id END
+WHERE id = ?`
+	if _, err := tx.Exec(updateSQL, categoryID, categoryID, categoryID); err != nil {
+		if isUniqueViolation(err) {
+			return utils.ErrCategoryExists
+		}
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// PurgeTransaction 彻底删除回收站中的账单（转账的两条腿一起），不在回收站中时返回 ErrTransactionNotFound。调用方负责事务
+func PurgeTransaction(tx DBTX, transactionID int64) error {
+	n, err := purgeTransactions(tx, transferGroupSQL, transactionID, transactionID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return utils.ErrTransactionNotFound
+	}
+	return nil
+}
+
+// PurgeCategory 彻底删除回收站中的类别，不在回收站中时返回 ErrCategoryNotFound。调用方负责事务
+func PurgeCategory(tx DBTX, categoryID int64) error {
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM categories WHERE id = ? AND deleted_at IS NOT NULL", categoryID).Scan(&count); err != nil {
+		return utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	if count == 0 {
+		return utils.ErrCategoryNotFound
+	}
+	return purgeCategory(tx, categoryID)
+}
+
+// GetTrashedIDs 返回 before（UTC "2006-01-02 15:04:05"，不包含，为空表示不限）之前移入回收站的账单与类别 id，
+// 转账只返回转出腿（即 transfer_id）
+func GetTrashedIDs(db DBTX, before string) (transactionIDs []int64, categoryIDs []int64, err error) {
+	if transactionIDs, err = queryIDs(db, `SELECT DISTINCT COALESCE(transfer_id, id) FROM transactions
+WHERE deleted_at IS NOT NULL AND (? = '' OR deleted_at < ?) ORDER BY 1`, before, before); err != nil {
+		return nil, nil, err
+	}
+	if categoryIDs, err = queryIDs(db, `SELECT id FROM categories
+WHERE deleted_at IS NOT NULL AND (? = '' OR deleted_at < ?) ORDER BY id`, before, before); err != nil {
+		return nil, nil, err
+	}
+	return transactionIDs, categoryIDs, nil
+}
+
+// PurgeTrash 彻底删除 before（含义同 GetTrashedIDs）之前移入回收站的账单与类别，before 为空时清空整个回收站。
+// 调用方负责事务
+func PurgeTrash(tx DBTX, before string) (*models.TrashPurgeResult, error) {
+	result := &models.TrashPurgeResult{}
+	var err error
+	if result.Transactions, err = purgeTransactions(tx, "(? = '' OR deleted_at < ?)", before, before); err != nil {
+		return nil, err
+	}
+	_, categoryIDs, err := GetTrashedIDs(tx, before)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range categoryIDs {
+		if err := purgeCategory(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	result.Categories = int64(len(categoryIDs))
+	return result, nil
+}
+
+// queryIDs 执行只返回一列 id 的查询
+func queryIDs(db DBTX, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	return ids, nil
+}
+
+// purgeTransactions 彻底删除回收站中满足 condition（作用于 transactions 表）的账单及其标签关联、拆分行与附件记录，
+// 返回删除的账单数
+func purgeTransactions(tx DBTX, condition string, args ...interface{}) (int64, error) {
+	selectSQL := "SELECT id FROM transactions WHERE deleted_at IS NOT NULL AND " + condition
+	for _, table := range []string{"transaction_tags", "transaction_splits", "attachments"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE transaction_id IN ("+selectSQL+")", args...); err != nil {
+			return 0, utils.WrapError(utils.ErrDeleteFailed, err)
+		}
+	}
+	result, err := tx.Exec("DELETE FROM transactions WHERE deleted_at IS NOT NULL AND "+condition, args...)
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return n, nil
+}
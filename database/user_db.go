@@ -1,6 +1,7 @@
 package database
 
 import (
+	"AccountingAssistant/models"
 	"AccountingAssistant/utils"
 	"database/sql"
 	"errors"
@@ -90,15 +91,62 @@ func createUserDatabase(userID int64) error {
 		return utils.WrapError(utils.ErrCreateDirFailed, err)
 	}
 
-	path := filepath.Join(UsersDataDir, fmt.Sprintf("user_%d.db", userID))
-
 	// 打开用户数据库
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open("sqlite3", UserDBPath(userID))
 	if err != nil {
 		return utils.WrapError(utils.ErrDBConnFailed, err)
 	}
 	defer db.Close()
 
+	if err := createBaseTables(db); err != nil {
+		return err
+	}
+	// 执行后续版本的结构迁移（见 migrations.go）
+	if err := migrateUserDB(db); err != nil {
+		return err
+	}
+	return seedDefaultCategories(db)
+}
+
+// 新用户的默认类别（恢复备份时新建的数据库不写入，见 CreateRestoreDatabase）
+var defaultCategories = []models.Category{
+	{Name: "餐饮", Kind: "expense", Icon: "🍜", Color: "#FF7043"},
+	{Name: "交通", Kind: "expense", Icon: "🚌", Color: "#42A5F5"},
+	{Name: "购物", Kind: "expense", Icon: "🛍️", Color: "#AB47BC"},
+	{Name: "居住", Kind: "expense", Icon: "🏠", Color: "#8D6E63"},
+	{Name: "通讯", Kind: "expense", Icon: "📱", Color: "#26A69A"},
+	{Name: "娱乐", Kind: "expense", Icon: "🎮", Color: "#EC407A"},
+	{Name: "医疗", Kind: "expense", Icon: "💊", Color: "#EF5350"},
+	{Name: "教育", Kind: "expense", Icon: "📚", Color: "#5C6BC0"},
+	{Name: "人情", Kind: "expense", Icon: "🧧", Color: "#D32F2F"},
+	{Name: "工资", Kind: "income", Icon: "💰", Color: "#66BB6A"},
+	{Name: "奖金", Kind: "income", Icon: "🎁", Color: "#FFCA28"},
+	{Name: "兼职", Kind: "income", Icon: "💼", Color: "#78909C"},
+	{Name: "理财", Kind: "income", Icon: "📈", Color: "#29B6F6"},
+	{Name: "退款", Kind: "both", Icon: "↩️", Color: "#9E9E9E"},
+}
+
+// seedDefaultCategories 在一个事务中写入默认类别
+func seedDefaultCategories(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	for _, c := range defaultCategories {
+		if _, err := CreateCategory(tx, c); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return nil
+}
+
+// createBaseTables 创建最初版本的表结构
+func createBaseTables(db *sql.DB) error {
 	// 创建记账表
 	createTableSQL := `
 CREATE TABLE IF NOT EXISTS transactions (
@@ -110,7 +158,7 @@ CREATE TABLE IF NOT EXISTS transactions (
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );` // 已修改表单金额类型
 
-	_, err = db.Exec(createTableSQL)
+	_, err := db.Exec(createTableSQL)
 	if err != nil {
 		return utils.WrapError(utils.ErrCreateTableFailed, err)
 	}
@@ -129,12 +177,26 @@ CREATE TABLE IF NOT EXISTS categories (
 	return nil
 }
 
+// UserDBPath 返回用户数据库文件的路径
+func UserDBPath(userID int64) string {
+	return filepath.Join(UsersDataDir, fmt.Sprintf("user_%d.db", userID))
+}
+
 func GetUserDB(userId int64) (*sql.DB, error) {
-	userDBPath := filepath.Join("database_files", "usersdata", fmt.Sprintf("user_%d.db", userId))
+	// 数据库文件不存在时不再隐式创建空文件
+	userDBPath, err := EnsureUserDatabase(userId)
+	if err != nil {
+		return nil, err
+	}
 	db, err := sql.Open("sqlite3", userDBPath)
 	if err != nil {
 		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
 	}
+	// 老用户的数据库可能还是旧结构，第一次打开时升级
+	if err := ensureUserDBMigrated(userId, db); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -153,9 +215,41 @@ func EnsureUserDatabase(userID int64) (string, error) {
 	if err := os.MkdirAll(UsersDataDir, 0755); err != nil {
 		return "", utils.WrapError(utils.ErrCreateDirFailed, err)
 	}
-	userDBPath := filepath.Join(UsersDataDir, fmt.Sprintf("user_%d.db", userID))
+	userDBPath := UserDBPath(userID)
 	if _, err := os.Stat(userDBPath); os.IsNotExist(err) {
 		return "", utils.ErrUserDBNotFound // 文件不存在是业务逻辑
 	}
 	return userDBPath, nil
 }
+
+// GetAllUserIDs 返回所有用户的 id（供后台任务逐个处理用户数据库）
+func GetAllUserIDs(masterDB *sql.DB) ([]int64, error) {
+	rows, err := masterDB.Query("SELECT id FROM users ORDER BY id")
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, utils.WrapError(utils.ErrReadFailed, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetUsernameByID 返回用户名，用户不存在时返回 ErrUserNotFound
+func GetUsernameByID(masterDB *sql.DB, userID int64) (string, error) {
+	var username string
+	err := masterDB.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", utils.ErrUserNotFound
+		}
+		return "", utils.WrapError(utils.ErrQueryFailed, err)
+	}
+	return username, nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// 新建账户要求结构体
+type CreateAccountRequest struct {
+	Name           string `json:"name" form:"name" binding:"required"`
+	Kind           string `json:"kind" form:"kind"`                       // cash / bank / credit / alipay / wechat / other，默认 cash
+	OpeningBalance string `json:"opening_balance" form:"opening_balance"` // 期初余额（元），可带负号
+	Currency       string `json:"currency" form:"currency"`               // 默认 CNY
+}
+
+// 更新账户要求结构体（nil 表示不更新）
+type UpdateAccountRequest struct {
+	Name           *string `json:"name" form:"name"`
+	Kind           *string `json:"kind" form:"kind"`
+	OpeningBalance *string `json:"opening_balance" form:"opening_balance"`
+	Currency       *string `json:"currency" form:"currency"`
+	Archived       *bool   `json:"archived" form:"archived"`
+}
+
+func (h *AccountHandler) CreateAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req CreateAccountRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	accountID, err := h.accountService.CreateAccount(userID.(int64), req.Name, req.Kind, req.OpeningBalance, req.Currency)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "添加成功",
+		"account_id": accountID,
+	})
+}
+
+func (h *AccountHandler) GetAccounts(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	// include_archived=true 时同时返回已归档账户
+	includeArchived := c.Query("include_archived") == "true"
+	accounts, err := h.accountService.GetAccounts(userID.(int64), includeArchived)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "获取成功",
+		"accounts": accounts,
+	})
+}
+
+func (h *AccountHandler) GetAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	account, err := h.accountService.GetAccount(userID.(int64), int64(accountID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"account": account,
+	})
+}
+
+func (h *AccountHandler) UpdateAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	var req UpdateAccountRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.accountService.UpdateAccount(userID.(int64), int64(accountID), req.Name, req.Kind, req.OpeningBalance, req.Currency, req.Archived)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "更新成功",
+	})
+}
+
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	accountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.accountService.DeleteAccount(userID.(int64), int64(accountID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AttachmentHandler struct {
+	attachmentService *services.AttachmentService
+}
+
+func NewAttachmentHandler(attachmentService *services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: attachmentService}
+}
+
+// UploadAttachment 上传账单附件（文件通过 multipart 表单的 file 字段上传）
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.HandleError(c, utils.ErrEmptyContent)
+		return
+	}
+	if fileHeader.Size > services.MaxAttachmentSize {
+		response.HandleError(c, utils.ErrAttachmentTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.HandleError(c, utils.WrapError(utils.ErrReadFailed, err))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.UploadAttachment(userID.(int64), int64(transactionID), fileHeader.Filename, file)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "上传成功",
+		"attachment": attachment,
+	})
+}
+
+func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	attachments, err := h.attachmentService.GetAttachments(userID.(int64), int64(transactionID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "获取成功",
+		"attachments": attachments,
+	})
+}
+
+// DownloadAttachment 以原始文件名下载附件
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	h.serveAttachment(c, false)
+}
+
+// GetThumbnail 返回图片附件的 JPEG 缩略图（在页面中直接显示）
+func (h *AttachmentHandler) GetThumbnail(c *gin.Context) {
+	h.serveAttachment(c, true)
+}
+
+func (h *AttachmentHandler) serveAttachment(c *gin.Context, thumbnail bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	attachmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	attachment, path, err := h.attachmentService.GetAttachmentFile(userID.(int64), int64(attachmentID), thumbnail)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	if thumbnail {
+		c.Header("Content-Type", "image/jpeg")
+		c.File(path)
+		return
+	}
+	c.Header("Content-Type", attachment.MimeType)
+	c.FileAttachment(path, attachment.FileName)
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	attachmentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	if err := h.attachmentService.DeleteAttachment(userID.(int64), int64(attachmentID)); err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BackupHandler struct {
+	backupService *services.BackupService
+}
+
+func NewBackupHandler(backupService *services.BackupService) *BackupHandler {
+	return &BackupHandler{backupService: backupService}
+}
+
+// 下载当前用户全部数据的备份文件（gzip 压缩的 JSON）
+func (h *BackupHandler) Backup(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+
+	filename := fmt.Sprintf("backup_%s.json.gz", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := h.backupService.Backup(userID.(int64), c.Writer); err != nil {
+		if !c.Writer.Written() {
+			// 还没有输出内容时可以正常返回错误
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			response.HandleError(c, err)
+			return
+		}
+		// 已经开始输出文件，只能中断并记录错误
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// 用备份文件（multipart 表单的 file 字段）替换当前用户的全部数据
+func (h *BackupHandler) Restore(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.HandleError(c, utils.ErrEmptyContent)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.HandleError(c, utils.WrapError(utils.ErrBackupInvalid, err))
+		return
+	}
+	defer file.Close()
+
+	result, err := h.backupService.Restore(userID.(int64), file)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "恢复成功",
+		"result":  result,
+	})
+}
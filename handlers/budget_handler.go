@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetHandler struct {
+	budgetService *services.BudgetService
+}
+
+func NewBudgetHandler(budgetService *services.BudgetService) *BudgetHandler {
+	return &BudgetHandler{budgetService: budgetService}
+}
+
+// 新建预算要求结构体
+type CreateBudgetRequest struct {
+	CategoryID int64  `json:"category_id" form:"category_id"`          // 0 或不填表示总体预算
+	Period     string `json:"period" form:"period" binding:"required"` // week / month / year
+	Amount     string `json:"amount" form:"amount" binding:"required"` // 每个周期的额度（元）
+	Rollover   bool   `json:"rollover" form:"rollover"`                // 未花完的额度是否结转到下一周期
+	StartDate  string `json:"start_date" form:"start_date"`            // 从哪个周期开始生效，默认当前周期
+}
+
+// 更新预算要求结构体（nil 表示不更新）
+type UpdateBudgetRequest struct {
+	Amount    *string `json:"amount" form:"amount"`
+	Rollover  *bool   `json:"rollover" form:"rollover"`
+	StartDate *string `json:"start_date" form:"start_date"`
+}
+
+func (h *BudgetHandler) CreateBudget(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req CreateBudgetRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	budgetID, err := h.budgetService.CreateBudget(userID.(int64), req.CategoryID, req.Period, req.Amount, req.Rollover, req.StartDate)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   "添加成功",
+		"budget_id": budgetID,
+	})
+}
+
+func (h *BudgetHandler) GetBudgets(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	budgets, err := h.budgetService.GetBudgets(userID.(int64), c.Query("period"))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"budgets": budgets,
+	})
+}
+
+func (h *BudgetHandler) UpdateBudget(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	budgetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	var req UpdateBudgetRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.budgetService.UpdateBudget(userID.(int64), int64(budgetID), req.Amount, req.Rollover, req.StartDate)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "更新成功",
+	})
+}
+
+func (h *BudgetHandler) DeleteBudget(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	budgetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.budgetService.DeleteBudget(userID.(int64), int64(budgetID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
+
+// 预算执行情况：date 为所在周期内的任意日期（默认今天），period 可只看某种周期的预算
+func (h *BudgetHandler) GetBudgetStatus(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	statuses, err := h.budgetService.GetBudgetStatus(userID.(int64), c.Query("date"), c.Query("period"))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"data": gin.H{
+			"budgets": statuses,
+		},
+	})
+}
@@ -20,15 +20,38 @@ func NewCategoryHandler(categoryService *services.CategoryService) *CategoryHand
 
 // 类别要求结构体
 type CategoryRequest struct {
-	Name string `json:"name" form:"name" binding:"required"`
+	Name     string `json:"name" form:"name" binding:"required"`
+	ParentID int64  `json:"parent_id" form:"parent_id"` // 上级类别，不传或 0 表示顶级类别
+	Kind     string `json:"kind" form:"kind"`           // income / expense / both（默认）
+	Icon     string `json:"icon" form:"icon"`
+	Color    string `json:"color" form:"color"` // "#RRGGBB"
+}
+
+// 合并类别要求结构体
+type MergeCategoriesRequest struct {
+	SourceIDs []int64 `json:"source_ids" form:"source_ids" binding:"required"` // 被合并（删除）的类别
+	TargetID  int64   `json:"target_id" form:"target_id" binding:"required"`
+}
+
+// 类别建议查询参数结构体（均为可选）
+type SuggestCategoriesRequest struct {
+	Note       string `form:"note"`
+	Amount     string `form:"amount"`      // 元
+	Type       string `form:"type"`        // income / expense
+	OccurredAt string `form:"occurred_at"` // 默认当前时间
+	Limit      int    `form:"limit"`       // 默认 5，最多 20
 }
 
 type UpdateCategoryRequest struct {
-	Name string `json:"name" form:"name" binding:"required"`
+	Name     string  `json:"name" form:"name" binding:"required"`
+	ParentID *int64  `json:"parent_id" form:"parent_id"` // 不传表示不修改上级，0 表示移为顶级类别
+	Kind     *string `json:"kind" form:"kind"`           // 以下字段不传表示不修改
+	Icon     *string `json:"icon" form:"icon"`
+	Color    *string `json:"color" form:"color"`
 }
 
 func (h *CategoryHandler) CreateCategory(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
@@ -38,7 +61,13 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 		response.HandleError(c, utils.ErrInvalidParameter)
 		return
 	}
-	categoryID, err := h.categoryService.CreateCategory(userID.(int64), r.Name)
+	categoryID, err := h.categoryService.CreateCategory(actor, services.CategoryInput{
+		Name:     r.Name,
+		ParentID: r.ParentID,
+		Kind:     r.Kind,
+		Icon:     r.Icon,
+		Color:    r.Color,
+	})
 	if err != nil {
 		response.HandleError(c, err)
 		return
@@ -58,7 +87,8 @@ func (h *CategoryHandler) GetCategory(c *gin.Context) {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
 	}
-	categories, err := h.categoryService.GetCategory(userID.(int64))
+	// 默认返回嵌套的类别树，flat=true 时返回平铺的列表；kind=income/expense 只返回可用于该类型账单的类别
+	categories, err := h.categoryService.GetCategory(userID.(int64), c.Query("flat") == "true", c.Query("kind"))
 	if err != nil {
 		response.HandleError(c, err) // 使用统一的错误处理
 		return
@@ -71,7 +101,7 @@ func (h *CategoryHandler) GetCategory(c *gin.Context) {
 }
 
 func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
@@ -83,7 +113,15 @@ func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
 		response.HandleError(c, utils.ErrInvalidParameter)
 		return
 	}
-	err = h.categoryService.DeleteCategory(userID.(int64), int64(categoryID))
+	// reassign_to 可选：把该类别的账单等转到另一个类别，不传则变为未分类
+	var reassignTo int64
+	if str := c.Query("reassign_to"); str != "" {
+		if reassignTo, err = strconv.ParseInt(str, 10, 64); err != nil {
+			response.HandleError(c, utils.ErrInvalidParameter)
+			return
+		}
+	}
+	err = h.categoryService.DeleteCategory(actor, int64(categoryID), reassignTo)
 	if err != nil {
 		response.HandleError(c, err)
 		return
@@ -95,7 +133,7 @@ func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
 }
 
 func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
@@ -114,7 +152,13 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	err = h.categoryService.UpdateCategory(userID.(int64), int64(categoryID), req.Name)
+	err = h.categoryService.UpdateCategory(actor, int64(categoryID), services.UpdateCategoryInput{
+		Name:     req.Name,
+		ParentID: req.ParentID,
+		Kind:     req.Kind,
+		Icon:     req.Icon,
+		Color:    req.Color,
+	})
 	if err != nil {
 		response.HandleError(c, err)
 		return
@@ -125,3 +169,57 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 		"message": "更新成功",
 	})
 }
+
+// 合并类别：把 source_ids 类别的账单、周期规则、预算与子类别转到 target_id 类别并删除来源类别
+func (h *CategoryHandler) MergeCategories(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req MergeCategoriesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	result, err := h.categoryService.MergeCategories(actor, req.SourceIDs, req.TargetID)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "合并成功",
+		"data":    result,
+	})
+}
+
+// SuggestCategories 根据历史账单为正在记录的账单推荐类别，按得分从高到低排列
+func (h *CategoryHandler) SuggestCategories(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req SuggestCategoriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	suggestions, err := h.categoryService.SuggestCategories(userID.(int64), services.SuggestCategoryInput{
+		Note:       req.Note,
+		Amount:     req.Amount,
+		Type:       req.Type,
+		OccurredAt: req.OccurredAt,
+		Limit:      req.Limit,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "获取成功",
+		"suggestions": suggestions,
+	})
+}
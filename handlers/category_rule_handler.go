@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryRuleHandler struct {
+	categoryRuleService *services.CategoryRuleService
+}
+
+func NewCategoryRuleHandler(categoryRuleService *services.CategoryRuleService) *CategoryRuleHandler {
+	return &CategoryRuleHandler{categoryRuleService: categoryRuleService}
+}
+
+// 新建自动分类规则要求结构体（条件至少给出一个，金额为元）
+type CreateCategoryRuleRequest struct {
+	Name         string   `json:"name" form:"name"`
+	Priority     int      `json:"priority" form:"priority"` // 越小越先匹配
+	CategoryID   int64    `json:"category_id" form:"category_id" binding:"required"`
+	Type         string   `json:"type" form:"type"` // income / expense，空表示不限
+	NoteContains string   `json:"note_contains" form:"note_contains"`
+	NoteRegex    string   `json:"note_regex" form:"note_regex"`
+	MinAmount    string   `json:"min_amount" form:"min_amount"`
+	MaxAmount    string   `json:"max_amount" form:"max_amount"`
+	AccountID    int64    `json:"account_id" form:"account_id"`
+	PayeeID      int64    `json:"payee_id" form:"payee_id"`
+	Tags         []string `json:"tags" form:"tags"`     // 匹配后给账单加上的标签
+	Active       *bool    `json:"active" form:"active"` // 默认启用
+}
+
+// 更新自动分类规则要求结构体（nil 表示不更新，字符串条件传空表示取消）
+type UpdateCategoryRuleRequest struct {
+	Name         *string   `json:"name" form:"name"`
+	Priority     *int      `json:"priority" form:"priority"`
+	CategoryID   *int64    `json:"category_id" form:"category_id"`
+	Type         *string   `json:"type" form:"type"`
+	NoteContains *string   `json:"note_contains" form:"note_contains"`
+	NoteRegex    *string   `json:"note_regex" form:"note_regex"`
+	MinAmount    *string   `json:"min_amount" form:"min_amount"`
+	MaxAmount    *string   `json:"max_amount" form:"max_amount"`
+	AccountID    *int64    `json:"account_id" form:"account_id"` // 0 表示不限
+	PayeeID      *int64    `json:"payee_id" form:"payee_id"`     // 0 表示不限
+	Tags         *[]string `json:"tags" form:"tags"`             // 传空值表示不加标签
+	Active       *bool     `json:"active" form:"active"`
+}
+
+// 对已有账单运行规则要求结构体
+type ApplyCategoryRulesRequest struct {
+	StartDate string `json:"start_date" form:"start_date"`
+	EndDate   string `json:"end_date" form:"end_date"`
+	Overwrite bool   `json:"overwrite" form:"overwrite"` // true 时已分类的账单也重新分类
+	DryRun    bool   `json:"dry_run" form:"dry_run"`     // true 时只预览，不修改
+}
+
+func (h *CategoryRuleHandler) CreateRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req CreateCategoryRuleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	ruleID, err := h.categoryRuleService.CreateRule(userID.(int64), services.CategoryRuleInput{
+		Name:         req.Name,
+		Priority:     req.Priority,
+		CategoryID:   req.CategoryID,
+		Type:         req.Type,
+		NoteContains: req.NoteContains,
+		NoteRegex:    req.NoteRegex,
+		MinAmount:    req.MinAmount,
+		MaxAmount:    req.MaxAmount,
+		AccountID:    req.AccountID,
+		PayeeID:      req.PayeeID,
+		Tags:         req.Tags,
+		Active:       req.Active,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "添加成功",
+		"rule_id": ruleID,
+	})
+}
+
+func (h *CategoryRuleHandler) GetRules(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	rules, err := h.categoryRuleService.GetRules(userID.(int64))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"rules":   rules,
+	})
+}
+
+func (h *CategoryRuleHandler) GetRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	rule, err := h.categoryRuleService.GetRule(userID.(int64), int64(ruleID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"rule":    rule,
+	})
+}
+
+func (h *CategoryRuleHandler) UpdateRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	var req UpdateCategoryRuleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.categoryRuleService.UpdateRule(userID.(int64), int64(ruleID), services.UpdateCategoryRuleInput{
+		Name:         req.Name,
+		Priority:     req.Priority,
+		CategoryID:   req.CategoryID,
+		Type:         req.Type,
+		NoteContains: req.NoteContains,
+		NoteRegex:    req.NoteRegex,
+		MinAmount:    req.MinAmount,
+		MaxAmount:    req.MaxAmount,
+		AccountID:    req.AccountID,
+		PayeeID:      req.PayeeID,
+		Tags:         req.Tags,
+		Active:       req.Active,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "更新成功",
+	})
+}
+
+func (h *CategoryRuleHandler) DeleteRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.categoryRuleService.DeleteRule(userID.(int64), int64(ruleID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
+
+// ApplyRules 对已有账单重新运行自动分类规则，dry_run=true 时只返回会改变的账单
+func (h *CategoryRuleHandler) ApplyRules(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ApplyCategoryRulesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	result, err := h.categoryRuleService.ApplyRules(actor, services.ApplyCategoryRulesInput{
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Overwrite: req.Overwrite,
+		DryRun:    req.DryRun,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	message := "分类完成"
+	if result.DryRun {
+		message = "预览成功"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"result":  result,
+	})
+}
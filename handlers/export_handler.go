@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// 导出账单：format 为 csv（默认）/ json / xlsx / beancount / ledger / hledger，其余查询参数与 GET /transactions 相同（不分页）
+func (h *ExportHandler) ExportTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ListTransactionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := services.ExportContentType(format)
+	if !ok {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+
+	filename := fmt.Sprintf("transactions_%s.%s", time.Now().Format("20060102"), services.ExportFileExtension(format))
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	err := h.exportService.ExportTransactions(userID.(int64), req.toQuery(), format, c.Writer)
+	if err != nil {
+		if !c.Writer.Written() {
+			// 还没有输出内容时可以正常返回错误
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			response.HandleError(c, err)
+			return
+		}
+		// 已经开始输出文件，只能中断并记录错误
+		c.Error(err)
+		c.Abort()
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 参数错误必须在写出文件内容（CSV 的 BOM、XLSX 的 zip 头）之前返回
+func TestExportTransactionsInvalidSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/export", func(c *gin.Context) {
+		c.Set("userID", int64(1))
+	}, NewExportHandler(services.NewExportService(nil)).ExportTransactions)
+
+	for _, query := range []string{
+		"format=csv&sort_by=foo",
+		"format=xlsx&sort_order=sideways",
+		"format=json&sort_by=deleted_at",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s: Content-Disposition should not be set on error", query)
+		}
+		var body struct {
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Success || body.Error == "" {
+			t.Errorf("%s: body = %q, want only an error response", query, w.Body.String())
+		}
+	}
+}
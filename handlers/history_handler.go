@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	historyService *services.HistoryService
+}
+
+func NewHistoryHandler(historyService *services.HistoryService) *HistoryHandler {
+	return &HistoryHandler{historyService: historyService}
+}
+
+// currentActor 从上下文中取出当前用户与会话（由 SessionMiddleware 写入），用于记录变更历史
+func currentActor(c *gin.Context) (services.Actor, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return services.Actor{}, false
+	}
+	return services.Actor{UserID: userID.(int64), SessionID: c.GetString("sessionID")}, true
+}
+
+func (h *HistoryHandler) GetTransactionHistory(c *gin.Context) {
+	h.getHistory(c, h.historyService.GetTransactionHistory)
+}
+
+func (h *HistoryHandler) GetCategoryHistory(c *gin.Context) {
+	h.getHistory(c, h.historyService.GetCategoryHistory)
+}
+
+// getHistory 处理账单或类别（路径参数 id）的变更历史查询
+func (h *HistoryHandler) getHistory(c *gin.Context, get func(userID int64, id int64) ([]models.HistoryEntry, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	history, err := get(userID.(int64), int64(id))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"history": history,
+	})
+}
+
+func (h *HistoryHandler) RevertTransaction(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	historyID, err := strconv.Atoi(c.Param("history_id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	if err := h.historyService.RevertTransaction(actor, int64(transactionID), int64(historyID)); err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "还原成功",
+	})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+	importService *services.ImportService
+}
+
+func NewImportHandler(importService *services.ImportService) *ImportHandler {
+	return &ImportHandler{importService: importService}
+}
+
+// CSV 导入要求结构体（文件通过 multipart 表单的 file 字段上传）
+type ImportCSVRequest struct {
+	DateColumn     string `form:"date_column"` // 表头名或从 1 开始的列序号，留空自动识别
+	AmountColumn   string `form:"amount_column"`
+	TypeColumn     string `form:"type_column"` // 不填且无法识别时，金额按带符号处理（负数为支出）
+	CategoryColumn string `form:"category_column"`
+	NoteColumn     string `form:"note_column"`
+	TagColumn      string `form:"tag_column"` // 多个标签以逗号分隔
+	PayeeColumn    string `form:"payee_column"`
+	HasHeader      *bool  `form:"has_header"` // 默认 true
+	Delimiter      string `form:"delimiter"`  // 默认逗号
+	AccountID      int64  `form:"account_id"` // 可选：导入到哪个账户
+	DryRun         bool   `form:"dry_run"`    // true 时只校验并预览，不写入
+}
+
+// 支付宝、微信账单导入要求结构体（文件通过 multipart 表单的 file 字段上传）
+type ImportStatementRequest struct {
+	AccountID int64 `form:"account_id"` // 可选：导入到哪个账户（如支付宝、微信钱包账户）
+	DryRun    bool  `form:"dry_run"`
+}
+
+// 银行账单（OFX/QFX、QIF）导入要求结构体（文件通过 multipart 表单的 file 字段上传）
+type ImportBankRequest struct {
+	AccountID  int64  `form:"account_id"`  // 可选：默认导入到哪个账户
+	AccountMap string `form:"account_map"` // 可选：账单账号到账户的映射，如 "6222001234=2,4033=3"
+	DateOrder  string `form:"date_order"`  // 仅 QIF：mdy（默认）/dmy/ymd
+	DryRun     bool   `form:"dry_run"`
+}
+
+func (h *ImportHandler) ImportCSV(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ImportCSVRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.HandleError(c, utils.ErrEmptyContent)
+		return
+	}
+	if fileHeader.Size > services.MaxImportFileSize {
+		response.HandleError(c, utils.ErrImportFileTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.HandleError(c, utils.WrapError(utils.ErrImportInvalidFile, err))
+		return
+	}
+	defer file.Close()
+
+	mapping := services.CSVMapping{
+		DateColumn:     req.DateColumn,
+		AmountColumn:   req.AmountColumn,
+		TypeColumn:     req.TypeColumn,
+		CategoryColumn: req.CategoryColumn,
+		NoteColumn:     req.NoteColumn,
+		TagColumn:      req.TagColumn,
+		PayeeColumn:    req.PayeeColumn,
+		HasHeader:      req.HasHeader == nil || *req.HasHeader,
+		Delimiter:      req.Delimiter,
+		AccountID:      req.AccountID,
+	}
+	result, err := h.importService.ImportCSV(actor, file, mapping, req.DryRun)
+	respondImportResult(c, result, err)
+}
+
+func (h *ImportHandler) ImportAlipay(c *gin.Context) {
+	h.importStatement(c, h.importService.ImportAlipay)
+}
+
+func (h *ImportHandler) ImportWechat(c *gin.Context) {
+	h.importStatement(c, h.importService.ImportWechat)
+}
+
+// importStatement 支付宝、微信账单导入的公共流程
+func (h *ImportHandler) importStatement(c *gin.Context, importFunc func(actor services.Actor, r io.Reader, accountID int64, dryRun bool) (*models.ImportResult, error)) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ImportStatementRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.HandleError(c, utils.ErrEmptyContent)
+		return
+	}
+	if fileHeader.Size > services.MaxImportFileSize {
+		response.HandleError(c, utils.ErrImportFileTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.HandleError(c, utils.WrapError(utils.ErrImportInvalidFile, err))
+		return
+	}
+	defer file.Close()
+
+	result, err := importFunc(actor, file, req.AccountID, req.DryRun)
+	respondImportResult(c, result, err)
+}
+
+func (h *ImportHandler) ImportOFX(c *gin.Context) {
+	h.importBank(c, h.importService.ImportOFX)
+}
+
+func (h *ImportHandler) ImportQIF(c *gin.Context) {
+	h.importBank(c, h.importService.ImportQIF)
+}
+
+// Beancount / Ledger / hledger 账本导入，参数与银行账单导入相同（account_map 的键为账本中的账户名）
+func (h *ImportHandler) ImportLedger(c *gin.Context) {
+	h.importBank(c, h.importService.ImportLedger)
+}
+
+// importBank 银行账单导入的公共流程
+func (h *ImportHandler) importBank(c *gin.Context, importFunc func(actor services.Actor, r io.Reader, options services.BankImportOptions, dryRun bool) (*models.ImportResult, error)) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ImportBankRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	accountMap, err := services.ParseAccountMap(req.AccountMap)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.HandleError(c, utils.ErrEmptyContent)
+		return
+	}
+	if fileHeader.Size > services.MaxImportFileSize {
+		response.HandleError(c, utils.ErrImportFileTooLarge)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.HandleError(c, utils.WrapError(utils.ErrImportInvalidFile, err))
+		return
+	}
+	defer file.Close()
+
+	options := services.BankImportOptions{
+		AccountID:  req.AccountID,
+		AccountMap: accountMap,
+		DateOrder:  req.DateOrder,
+	}
+	result, err := importFunc(actor, file, options, req.DryRun)
+	respondImportResult(c, result, err)
+}
+
+// respondImportResult 输出导入结果；有错误行时同时返回每一行的错误
+func respondImportResult(c *gin.Context, result *models.ImportResult, err error) {
+	if errors.Is(err, utils.ErrImportInvalidRows) && result != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   utils.ErrImportInvalidRows.Message,
+			"result":  result,
+		})
+		return
+	}
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	message := "导入成功"
+	if result.DryRun {
+		message = "校验完成，未写入数据"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"result":  result,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PayeeHandler struct {
+	payeeService *services.PayeeService
+}
+
+func NewPayeeHandler(payeeService *services.PayeeService) *PayeeHandler {
+	return &PayeeHandler{payeeService: payeeService}
+}
+
+// 新建商户要求结构体
+type PayeeRequest struct {
+	Name              string   `json:"name" form:"name" binding:"required"`
+	Aliases           []string `json:"aliases" form:"aliases"`                         // 可选：别名，可重复传
+	DefaultCategoryID int64    `json:"default_category_id" form:"default_category_id"` // 可选：记账时没有给出类别则使用
+}
+
+// 修改商户要求结构体（nil 表示不更新）
+type UpdatePayeeRequest struct {
+	Name              *string   `json:"name" form:"name"`
+	Aliases           *[]string `json:"aliases" form:"aliases"`                         // 替换全部别名，传空值表示清空
+	DefaultCategoryID *int64    `json:"default_category_id" form:"default_category_id"` // 0 表示清空
+}
+
+// 商户列表查询参数
+type ListPayeesRequest struct {
+	Prefix string `form:"prefix"` // 名称或别名的前缀，用于记账时自动补全
+	Limit  int    `form:"limit"`  // 默认 20
+}
+
+// 合并商户要求结构体
+type MergePayeesRequest struct {
+	SourceIDs []int64 `json:"source_ids" form:"source_ids" binding:"required"` // 被合并（删除）的商户
+	TargetID  int64   `json:"target_id" form:"target_id" binding:"required"`
+}
+
+func (h *PayeeHandler) CreatePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req PayeeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	payeeID, err := h.payeeService.CreatePayee(userID.(int64), services.PayeeInput{
+		Name:              req.Name,
+		Aliases:           req.Aliases,
+		DefaultCategoryID: req.DefaultCategoryID,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "添加成功",
+		"payee_id": payeeID,
+	})
+}
+
+func (h *PayeeHandler) GetPayees(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req ListPayeesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	payees, err := h.payeeService.GetPayees(userID.(int64), req.Prefix, req.Limit)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"payees":  payees,
+	})
+}
+
+func (h *PayeeHandler) GetPayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	payeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	payee, err := h.payeeService.GetPayee(userID.(int64), int64(payeeID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"payee":   payee,
+	})
+}
+
+func (h *PayeeHandler) UpdatePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	payeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	var req UpdatePayeeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.payeeService.UpdatePayee(userID.(int64), int64(payeeID), services.UpdatePayeeInput{
+		Name:              req.Name,
+		Aliases:           req.Aliases,
+		DefaultCategoryID: req.DefaultCategoryID,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "更新成功",
+	})
+}
+
+func (h *PayeeHandler) DeletePayee(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	payeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	if err := h.payeeService.DeletePayee(userID.(int64), int64(payeeID)); err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
+
+func (h *PayeeHandler) MergePayees(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req MergePayeesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	result, err := h.payeeService.MergePayees(userID.(int64), req.SourceIDs, req.TargetID)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "合并成功",
+		"data":    result,
+	})
+}
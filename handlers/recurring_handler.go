@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecurringHandler struct {
+	recurringService *services.RecurringService
+}
+
+func NewRecurringHandler(recurringService *services.RecurringService) *RecurringHandler {
+	return &RecurringHandler{recurringService: recurringService}
+}
+
+// 新建周期规则要求结构体
+type CreateRecurringRuleRequest struct {
+	Type      string `json:"type" form:"type" binding:"required"` // income / expense
+	Amount    string `json:"amount" form:"amount" binding:"required"`
+	Category  string `json:"category" form:"category" binding:"required"`
+	Note      string `json:"note" form:"note"`
+	AccountID int64  `json:"account_id" form:"account_id"`
+	Frequency string `json:"frequency" form:"frequency" binding:"required"` // daily / weekly / monthly / yearly
+	Interval  int    `json:"interval" form:"interval"`                      // 每隔几个周期，默认 1
+	StartDate string `json:"start_date" form:"start_date"`                  // 第一次发生的时间，默认当前时间
+	EndDate   string `json:"end_date" form:"end_date"`                      // 可选：结束日期（包含）
+	Count     int    `json:"count" form:"count"`                            // 可选：最多发生次数
+}
+
+// 更新周期规则要求结构体（nil 表示不更新）
+type UpdateRecurringRuleRequest struct {
+	Type      *string `json:"type" form:"type"`
+	Amount    *string `json:"amount" form:"amount"`
+	Category  *string `json:"category" form:"category"`
+	Note      *string `json:"note" form:"note"`
+	AccountID *int64  `json:"account_id" form:"account_id"` // 0 表示清空
+	Frequency *string `json:"frequency" form:"frequency"`
+	Interval  *int    `json:"interval" form:"interval"`
+	StartDate *string `json:"start_date" form:"start_date"`
+	EndDate   *string `json:"end_date" form:"end_date"` // 空字符串表示不限
+	Count     *int    `json:"count" form:"count"`       // 0 表示不限
+	Active    *bool   `json:"active" form:"active"`     // false 表示暂停
+}
+
+func (h *RecurringHandler) CreateRule(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req CreateRecurringRuleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	ruleID, err := h.recurringService.CreateRule(actor, services.CreateRecurringRuleInput{
+		Type:      req.Type,
+		Amount:    req.Amount,
+		Category:  req.Category,
+		Note:      req.Note,
+		AccountID: req.AccountID,
+		Frequency: req.Frequency,
+		Interval:  req.Interval,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Count:     req.Count,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "添加成功",
+		"rule_id": ruleID,
+	})
+}
+
+func (h *RecurringHandler) GetRules(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	rules, err := h.recurringService.GetRules(userID.(int64))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"rules":   rules,
+	})
+}
+
+func (h *RecurringHandler) GetRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	rule, err := h.recurringService.GetRule(userID.(int64), int64(ruleID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"rule":    rule,
+	})
+}
+
+func (h *RecurringHandler) UpdateRule(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	var req UpdateRecurringRuleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.recurringService.UpdateRule(actor, int64(ruleID), services.UpdateRecurringRuleInput{
+		Type:      req.Type,
+		Amount:    req.Amount,
+		Category:  req.Category,
+		Note:      req.Note,
+		AccountID: req.AccountID,
+		Frequency: req.Frequency,
+		Interval:  req.Interval,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Count:     req.Count,
+		Active:    req.Active,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "更新成功",
+	})
+}
+
+func (h *RecurringHandler) DeleteRule(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	err = h.recurringService.DeleteRule(userID.(int64), int64(ruleID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除成功",
+	})
+}
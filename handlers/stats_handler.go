@@ -145,3 +145,123 @@ func (h *StatHandler) GetRangeAmountStats(c *gin.Context) {
 		},
 	})
 }
+
+func (h *StatHandler) GetAccountBalances(c *gin.Context) {
+	// 从会话中获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	// as_of 可选：截止日期（包含），用于查看历史某天的余额
+	balances, err := h.statService.GetAccountBalances(userID.(int64), c.Query("as_of"))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"data": gin.H{
+			"account_balances": balances,
+		},
+	})
+}
+
+// 类别统计查询参数
+type CategoryStatsRequest struct {
+	Type      string `form:"type"`       // income / expense（默认）
+	StartDate string `form:"start_date"` // 起始日期（包含），空表示不限
+	EndDate   string `form:"end_date"`   // 结束日期（包含），空表示不限
+	Level     int    `form:"level"`      // 展开的层数，0 表示全部展开
+}
+
+// 标签统计查询参数
+type TagStatsRequest struct {
+	StartDate string `form:"start_date"` // 起始日期（包含），空表示不限
+	EndDate   string `form:"end_date"`   // 结束日期（包含），空表示不限
+}
+
+// 商户排行查询参数
+type TopPayeesRequest struct {
+	StartDate string `form:"start_date"` // 起始日期（包含），空表示不限
+	EndDate   string `form:"end_date"`   // 结束日期（包含），空表示不限
+	Limit     int    `form:"limit"`      // 返回的商户数，默认 10
+}
+
+func (h *StatHandler) GetCategoryStats(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req CategoryStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	stats, err := h.statService.GetCategoryStats(userID.(int64), req.Type, req.StartDate, req.EndDate, req.Level)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"data": gin.H{
+			"category_stats": stats,
+		},
+	})
+}
+
+// GetTagStats 按标签汇总时间范围内的收入、支出与净额
+func (h *StatHandler) GetTagStats(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req TagStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	stats, err := h.statService.GetTagStats(userID.(int64), req.StartDate, req.EndDate)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"data": gin.H{
+			"tag_stats": stats,
+		},
+	})
+}
+
+// GetTopPayees 时间范围内支出最多的商户
+func (h *StatHandler) GetTopPayees(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req TopPayeesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	stats, err := h.statService.GetTopPayees(userID.(int64), req.StartDate, req.EndDate, req.Limit)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取成功",
+		"data": gin.H{
+			"payee_stats": stats,
+		},
+	})
+}
@@ -12,18 +12,70 @@ import (
 
 // "记录账单"要求结构体
 type RecordRequest struct {
-	Type     string `form:"type" binding:"required"`
-	Amount   string `form:"amount" binding:"required"`
-	Category string `form:"category" binding:"required"`
-	Note     string `form:"note" binding:"required"`
+	Type        string   `form:"type" binding:"required"`
+	Amount      string   `form:"amount" binding:"required"`
+	Category    string   `form:"category"` // 收入、支出不填时按自动分类规则归类，没有匹配的规则则为未分类；转账不填
+	Note        string   `form:"note" binding:"required"`
+	OccurredAt  string   `form:"occurred_at"`   // 可选：发生日期或日期时间，默认当前时间
+	AccountID   int64    `form:"account_id"`    // 可选：所属账户；转账时必填，为转出账户
+	ToAccountID int64    `form:"to_account_id"` // 仅转账：转入账户
+	Tags        []string `form:"tags"`          // 可选：标签，可重复传或以逗号分隔，不存在时自动创建
+	Payee       string   `form:"payee"`         // 可选：商户名称或别名，不存在时自动创建；不填 category 时使用商户的默认类别
+	// 可选：拆分行，三个参数按顺序一一对应（备注可省略），各行金额之和须等于 amount；拆分时不填 category
+	SplitCategory []string `form:"split_category"`
+	SplitAmount   []string `form:"split_amount"`
+	SplitNote     []string `form:"split_note"`
 }
 
 // "更新账单"要求结构体
 type UpdateTransactionRequest struct {
-	Type     *string `form:"type"`     // 使用指针，nil表示不更新
-	Amount   *string `form:"amount"`   // 使用指针，nil表示不更新
-	Category *string `form:"category"` // 使用指针，nil表示不更新
-	Note     *string `form:"note"`     // 使用指针，nil表示不更新
+	Type        *string   `form:"type"`          // 使用指针，nil表示不更新
+	Amount      *string   `form:"amount"`        // 使用指针，nil表示不更新
+	Category    *string   `form:"category"`      // 使用指针，nil表示不更新
+	Note        *string   `form:"note"`          // 使用指针，nil表示不更新
+	OccurredAt  *string   `form:"occurred_at"`   // 使用指针，nil表示不更新
+	AccountID   *int64    `form:"account_id"`    // 使用指针，nil表示不更新，0表示清空
+	ToAccountID *int64    `form:"to_account_id"` // 仅转账：转入账户
+	Tags        *[]string `form:"tags"`          // 使用指针，nil表示不更新；传空值表示清空标签
+	Payee       *string   `form:"payee"`         // 使用指针，nil表示不更新；传空值表示清空商户
+	// 拆分行：传 split_amount 或 split_category 时替换全部拆分行，传空值表示取消拆分
+	SplitCategory *[]string `form:"split_category"`
+	SplitAmount   *[]string `form:"split_amount"`
+	SplitNote     *[]string `form:"split_note"`
+}
+
+// "获取账单"查询参数结构体（均为可选）
+type ListTransactionsRequest struct {
+	StartDate  string   `form:"start_date"`  // 日期或日期时间
+	EndDate    string   `form:"end_date"`    // 日期或日期时间（包含）
+	Type       string   `form:"type"`        // income / expense / transfer
+	CategoryID *int64   `form:"category_id"` // 0 表示未分类
+	Category   string   `form:"category"`    // 类别名
+	AccountID  *int64   `form:"account_id"`  // 0 表示未指定账户
+	PayeeID    *int64   `form:"payee_id"`    // 0 表示没有商户
+	MinAmount  string   `form:"min_amount"`  // 金额下限（元）
+	MaxAmount  string   `form:"max_amount"`  // 金额上限（元）
+	Note       string   `form:"note"`        // 备注包含
+	Tag        []string `form:"tag"`         // 标签名，可重复，同时带有这些标签的账单
+	TagID      []int64  `form:"tag_id"`      // 标签 id，可重复
+	SortBy     string   `form:"sort_by"`     // occurred_at / created_at / amount / id
+	SortOrder  string   `form:"sort_order"`  // asc / desc
+	Limit      int      `form:"limit"`
+	Offset     int      `form:"offset"`
+	PageToken  string   `form:"page_token"` // 上一页返回的 next_page_token
+}
+
+// "批量操作账单"要求结构体：all=true 且不传 ids 时，目标为符合筛选参数（同"获取账单"，排序与分页参数无效）的全部账单
+type BulkTransactionsRequest struct {
+	ListTransactionsRequest
+	Operation    string   `form:"operation" binding:"required"` // recategorize / retag / change_account / change_type / delete / restore
+	IDs          []int64  `form:"ids"`                          // 目标账单 id，可重复
+	All          bool     `form:"all"`
+	NewCategory  *string  `form:"new_category"`   // recategorize：类别名，不存在时自动创建，传空值表示未分类
+	NewType      string   `form:"new_type"`       // change_type：income / expense
+	NewAccountID *int64   `form:"new_account_id"` // change_account：0 表示清空账户
+	Tags         []string `form:"tags"`           // retag：标签，可重复传或以逗号分隔
+	TagMode      string   `form:"tag_mode"`       // retag：set（默认）/ add / remove
 }
 
 // 处理账单服务的对象
@@ -49,19 +101,31 @@ func (h *TransactionHandler) RecordTransaction(c *gin.Context) {
 	// 把原始金额字符串交给 service 处理（包括解析、符号、校验）
 
 	// 从会话中获取用户ID，而不是从请求参数
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
 	}
 
 	// 添加类型验证
-	if req.Type != "income" && req.Type != "expense" {
+	if req.Type != "income" && req.Type != "expense" && req.Type != "transfer" {
 		response.HandleError(c, utils.ErrInvalidTransactionType)
 		return
 	}
+	splits := splitInputs(req.SplitCategory, req.SplitAmount, req.SplitNote)
 
-	transactionId, err := h.transactionService.RecordTransaction(userID.(int64), req.Type, req.Amount, req.Category, req.Note)
+	transactionId, err := h.transactionService.RecordTransaction(actor, services.RecordTransactionInput{
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Category:    req.Category,
+		Note:        req.Note,
+		OccurredAt:  req.OccurredAt,
+		AccountID:   req.AccountID,
+		ToAccountID: req.ToAccountID,
+		Tags:        req.Tags,
+		Splits:      splits,
+		Payee:       req.Payee,
+	})
 	if err != nil {
 		response.HandleError(c, err) // 使用统一的错误处理
 		return
@@ -81,21 +145,76 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
 	}
-	transactions, err := h.transactionService.GetTransactions(userID.(int64))
+	var req ListTransactionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	page, err := h.transactionService.GetTransactions(userID.(int64), req.toQuery())
 	if err != nil {
 		response.HandleError(c, err) // 使用统一的错误处理
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"success":      true,
-		"message":      "获取成功",
-		"transactions": transactions,
+		"success":         true,
+		"message":         "获取成功",
+		"transactions":    page.Transactions,
+		"total":           page.Total,
+		"next_page_token": page.NextPageToken,
+	})
+}
+
+// 查询参数转换为 service 层的查询结构
+func (r ListTransactionsRequest) toQuery() services.TransactionQuery {
+	return services.TransactionQuery{
+		StartDate:    r.StartDate,
+		EndDate:      r.EndDate,
+		Type:         r.Type,
+		CategoryID:   r.CategoryID,
+		CategoryName: r.Category,
+		AccountID:    r.AccountID,
+		PayeeID:      r.PayeeID,
+		MinAmount:    r.MinAmount,
+		MaxAmount:    r.MaxAmount,
+		Note:         r.Note,
+		Tags:         r.Tag,
+		TagIDs:       r.TagID,
+		SortBy:       r.SortBy,
+		SortOrder:    r.SortOrder,
+		Limit:        r.Limit,
+		Offset:       r.Offset,
+		PageToken:    r.PageToken,
+	}
+}
+
+// "获取单个账单"HTTP响应
+func (h *TransactionHandler) GetTransaction(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	transactionIDStr := c.Param("id")
+	transactionID, err := strconv.Atoi(transactionIDStr)
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	transaction, err := h.transactionService.GetTransaction(userID.(int64), int64(transactionID))
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "获取成功",
+		"transaction": transaction,
 	})
 }
 
 // "删除账单"HTTP响应
 func (h *TransactionHandler) DeleteTransaction(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
@@ -107,7 +226,7 @@ func (h *TransactionHandler) DeleteTransaction(c *gin.Context) {
 		response.HandleError(c, utils.ErrInvalidParameter)
 		return
 	}
-	err = h.transactionService.DeleteTransaction(userID.(int64), int64(transactionID))
+	err = h.transactionService.DeleteTransaction(actor, int64(transactionID))
 	if err != nil {
 		response.HandleError(c, err)
 		return
@@ -120,7 +239,7 @@ func (h *TransactionHandler) DeleteTransaction(c *gin.Context) {
 
 // "更新账单"HTTP响应
 func (h *TransactionHandler) UpdateTransaction(c *gin.Context) {
-	userID, exists := c.Get("userID")
+	actor, exists := currentActor(c)
 	if !exists {
 		response.HandleError(c, utils.ErrNotLoggedIn)
 		return
@@ -141,20 +260,39 @@ func (h *TransactionHandler) UpdateTransaction(c *gin.Context) {
 
 	// 添加类型验证
 	if req.Type != nil {
-		if *(req.Type) != "income" && *(req.Type) != "expense" {
+		if *(req.Type) != "income" && *(req.Type) != "expense" && *(req.Type) != "transfer" {
 			response.HandleError(c, utils.ErrInvalidTransactionType)
 			return
 		}
 	}
+	var splits *[]services.SplitInput
+	if req.SplitCategory != nil || req.SplitAmount != nil {
+		var categories, amounts, notes []string
+		if req.SplitCategory != nil {
+			categories = *req.SplitCategory
+		}
+		if req.SplitAmount != nil {
+			amounts = *req.SplitAmount
+		}
+		if req.SplitNote != nil {
+			notes = *req.SplitNote
+		}
+		inputs := splitInputs(categories, amounts, notes)
+		splits = &inputs
+	}
 
-	err = h.transactionService.UpdateTransaction(
-		userID.(int64),
-		int64(transactionID),
-		req.Type,     // 可能是nil
-		req.Amount,   // 可能是nil
-		req.Category, // 可能是nil
-		req.Note,     // 可能是nil
-	)
+	err = h.transactionService.UpdateTransaction(actor, int64(transactionID), services.UpdateTransactionInput{
+		Type:        req.Type,        // 可能是nil
+		Amount:      req.Amount,      // 可能是nil
+		Category:    req.Category,    // 可能是nil
+		Note:        req.Note,        // 可能是nil
+		OccurredAt:  req.OccurredAt,  // 可能是nil
+		AccountID:   req.AccountID,   // 可能是nil
+		ToAccountID: req.ToAccountID, // 可能是nil
+		Tags:        req.Tags,        // 可能是nil
+		Splits:      splits,          // 可能是nil
+		Payee:       req.Payee,       // 可能是nil
+	})
 	if err != nil {
 		response.HandleError(c, err)
 		return
@@ -165,3 +303,58 @@ func (h *TransactionHandler) UpdateTransaction(c *gin.Context) {
 		"message": "更新成功",
 	})
 }
+
+// splitInputs 把按顺序一一对应的拆分参数组合为拆分行，缺少的参数按空值处理（由 service 校验）。
+// 类别、金额都为空的行被忽略，因此只传一个空的 split_amount 表示没有拆分
+func splitInputs(categories, amounts, notes []string) []services.SplitInput {
+	n := max(len(categories), len(amounts), len(notes))
+	at := func(values []string, i int) string {
+		if i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+	inputs := []services.SplitInput{}
+	for i := 0; i < n; i++ {
+		input := services.SplitInput{Category: at(categories, i), Amount: at(amounts, i), Note: at(notes, i)}
+		if input.Category == "" && input.Amount == "" {
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// "批量操作账单"HTTP响应：整批在一个事务中执行，单笔失败不影响其他账单，结果中给出每笔账单的成败
+func (h *TransactionHandler) BulkUpdate(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req BulkTransactionsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	result, err := h.transactionService.BulkUpdate(actor, services.BulkTransactionInput{
+		Operation: req.Operation,
+		IDs:       req.IDs,
+		All:       req.All,
+		Query:     req.toQuery(),
+		Category:  req.NewCategory,
+		Type:      req.NewType,
+		AccountID: req.NewAccountID,
+		Tags:      req.Tags,
+		TagMode:   req.TagMode,
+	})
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "批量操作完成",
+		"result":  result,
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"AccountingAssistant/services"
+	"AccountingAssistant/utils"
+	"AccountingAssistant/web/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	trashService *services.TrashService
+}
+
+func NewTrashHandler(trashService *services.TrashService) *TrashHandler {
+	return &TrashHandler{trashService: trashService}
+}
+
+// 回收站查询参数（分页方式与账单列表相同）
+type GetTrashRequest struct {
+	Limit     int    `form:"limit"`
+	PageToken string `form:"page_token"` // 上一页返回的 next_page_token
+}
+
+// 回收站设置要求结构体
+type TrashSettingsRequest struct {
+	RetentionDays *int `json:"retention_days" form:"retention_days" binding:"required"` // 0 表示不自动彻底删除
+}
+
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req GetTrashRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	trash, err := h.trashService.GetTrash(userID.(int64), req.Limit, req.PageToken)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"message":         "获取成功",
+		"transactions":    trash.Transactions.Transactions,
+		"total":           trash.Transactions.Total,
+		"next_page_token": trash.Transactions.NextPageToken,
+		"categories":      trash.Categories,
+		"retention_days":  trash.RetentionDays,
+	})
+}
+
+func (h *TrashHandler) RestoreTransaction(c *gin.Context) {
+	h.handleItem(c, "恢复成功", h.trashService.RestoreTransaction)
+}
+
+func (h *TrashHandler) RestoreCategory(c *gin.Context) {
+	h.handleItem(c, "恢复成功", h.trashService.RestoreCategory)
+}
+
+func (h *TrashHandler) PurgeTransaction(c *gin.Context) {
+	h.handleItem(c, "已彻底删除", h.trashService.PurgeTransaction)
+}
+
+func (h *TrashHandler) PurgeCategory(c *gin.Context) {
+	h.handleItem(c, "已彻底删除", h.trashService.PurgeCategory)
+}
+
+// handleItem 处理针对回收站中单个账单或类别（路径参数 id）的操作
+func (h *TrashHandler) handleItem(c *gin.Context, message string, action func(actor services.Actor, id int64) error) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	if err := action(actor, int64(id)); err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+	})
+}
+
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	actor, exists := currentActor(c)
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	result, err := h.trashService.EmptyTrash(actor)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "回收站已清空",
+		"result":  result,
+	})
+}
+
+func (h *TrashHandler) UpdateSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.HandleError(c, utils.ErrNotLoggedIn)
+		return
+	}
+	var req TrashSettingsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		response.HandleError(c, utils.ErrInvalidParameter)
+		return
+	}
+	if err := h.trashService.SetRetentionDays(userID.(int64), *req.RetentionDays); err != nil {
+		response.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "设置成功",
+		"retention_days": *req.RetentionDays,
+	})
+}
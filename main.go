@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"AccountingAssistant/database"
 	"AccountingAssistant/handlers"
@@ -27,6 +28,18 @@ func main() {
 	transactionService := services.NewTransactionService(db)
 	statService := services.NewStatService(db)
 	categoryService := services.NewCategoryService(db)
+	categoryRuleService := services.NewCategoryRuleService(db)
+	tagService := services.NewTagService(db)
+	payeeService := services.NewPayeeService(db)
+	attachmentService := services.NewAttachmentService(db)
+	accountService := services.NewAccountService(db)
+	recurringService := services.NewRecurringService(db)
+	budgetService := services.NewBudgetService(db)
+	importService := services.NewImportService(db)
+	exportService := services.NewExportService(db)
+	backupService := services.NewBackupService(db)
+	trashService := services.NewTrashService(db)
+	historyService := services.NewHistoryService(db)
 	// 添加: 基于数据库的会话管理器
 	sessionManager := services.NewDBSessionManager(db)
 
@@ -34,6 +47,26 @@ func main() {
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
 	statHandler := handlers.NewStatHandler(statService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
+	categoryRuleHandler := handlers.NewCategoryRuleHandler(categoryRuleService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	payeeHandler := handlers.NewPayeeHandler(payeeService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	recurringHandler := handlers.NewRecurringHandler(recurringService)
+	budgetHandler := handlers.NewBudgetHandler(budgetService)
+	importHandler := handlers.NewImportHandler(importService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	backupHandler := handlers.NewBackupHandler(backupService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	historyHandler := handlers.NewHistoryHandler(historyService)
+
+	// 后台生成周期账单：启动时先补上停机期间错过的发生，之后每小时检查一次
+	stopMaterializer := recurringService.StartMaterializer(time.Hour)
+	defer stopMaterializer()
+	// 后台清理回收站：超过用户设置的保留天数的账单与类别彻底删除
+	stopPurger := trashService.StartPurger(time.Hour)
+	defer stopPurger()
+
 	r := gin.Default()
 
 	r.POST("/register", authHandler.RegisterUser)
@@ -44,18 +77,94 @@ func main() {
 	{
 		authGroup.POST("/transaction", transactionHandler.RecordTransaction)
 		authGroup.GET("/transactions", transactionHandler.GetTransactions)
+		authGroup.GET("/transaction/:id", transactionHandler.GetTransaction)       // 获取特定账单
+		authGroup.PUT("/transaction/:id", transactionHandler.UpdateTransaction)    // 更新特定账单
+		authGroup.DELETE("/transaction/:id", transactionHandler.DeleteTransaction) // 删除特定账单（移到回收站）
+		authGroup.POST("/transactions/bulk", transactionHandler.BulkUpdate)        // 批量操作账单
+		authGroup.GET("/transaction/:id/history", historyHandler.GetTransactionHistory)
+		authGroup.POST("/transaction/:id/history/:history_id/revert", historyHandler.RevertTransaction) // 还原到某条历史之后的版本
+
+		authGroup.POST("/transaction/:id/attachments", attachmentHandler.UploadAttachment) // 上传附件（multipart 的 file 字段）
+		authGroup.GET("/transaction/:id/attachments", attachmentHandler.GetAttachments)
+		authGroup.GET("/attachment/:id", attachmentHandler.DownloadAttachment)
+		authGroup.GET("/attachment/:id/thumbnail", attachmentHandler.GetThumbnail)
+		authGroup.DELETE("/attachment/:id", attachmentHandler.DeleteAttachment)
+
 		authGroup.POST("/logout", authHandler.LogoutUser) // 添加退出登录
 
 		authGroup.POST("/category", categoryHandler.CreateCategory)
 		authGroup.GET("/categories", categoryHandler.GetCategory)
-		authGroup.PUT("/category/:id", categoryHandler.UpdateCategory)    // 更新特定类别
-		authGroup.DELETE("/category/:id", categoryHandler.DeleteCategory) // 删除特定类别
+		authGroup.POST("/categories/merge", categoryHandler.MergeCategories)
+		authGroup.GET("/categories/suggest", categoryHandler.SuggestCategories) // 按历史账单推荐类别
+		authGroup.PUT("/category/:id", categoryHandler.UpdateCategory)          // 更新特定类别
+		authGroup.DELETE("/category/:id", categoryHandler.DeleteCategory)       // 删除特定类别（移到回收站）
+		authGroup.GET("/category/:id/history", historyHandler.GetCategoryHistory)
+
+		authGroup.GET("/trash", trashHandler.GetTrash)
+		authGroup.DELETE("/trash", trashHandler.EmptyTrash)
+		authGroup.PUT("/trash/settings", trashHandler.UpdateSettings) // 保留天数
+		authGroup.POST("/trash/transaction/:id/restore", trashHandler.RestoreTransaction)
+		authGroup.DELETE("/trash/transaction/:id", trashHandler.PurgeTransaction) // 彻底删除
+		authGroup.POST("/trash/category/:id/restore", trashHandler.RestoreCategory)
+		authGroup.DELETE("/trash/category/:id", trashHandler.PurgeCategory)
+
+		authGroup.POST("/category_rule", categoryRuleHandler.CreateRule)
+		authGroup.GET("/category_rules", categoryRuleHandler.GetRules)
+		authGroup.POST("/category_rules/apply", categoryRuleHandler.ApplyRules) // 对已有账单运行规则
+		authGroup.GET("/category_rule/:id", categoryRuleHandler.GetRule)
+		authGroup.PUT("/category_rule/:id", categoryRuleHandler.UpdateRule)
+		authGroup.DELETE("/category_rule/:id", categoryRuleHandler.DeleteRule)
+
+		authGroup.POST("/tag", tagHandler.CreateTag)
+		authGroup.GET("/tags", tagHandler.GetTags)
+		authGroup.POST("/tags/merge", tagHandler.MergeTags)
+		authGroup.PUT("/tag/:id", tagHandler.RenameTag)
+		authGroup.DELETE("/tag/:id", tagHandler.DeleteTag)
+
+		authGroup.POST("/payee", payeeHandler.CreatePayee)
+		authGroup.GET("/payees", payeeHandler.GetPayees) // prefix= 按名称或别名前缀查找（自动补全）
+		authGroup.POST("/payees/merge", payeeHandler.MergePayees)
+		authGroup.GET("/payee/:id", payeeHandler.GetPayee)
+		authGroup.PUT("/payee/:id", payeeHandler.UpdatePayee)
+		authGroup.DELETE("/payee/:id", payeeHandler.DeletePayee)
+
+		authGroup.POST("/account", accountHandler.CreateAccount)
+		authGroup.GET("/accounts", accountHandler.GetAccounts)
+		authGroup.GET("/account/:id", accountHandler.GetAccount)
+		authGroup.PUT("/account/:id", accountHandler.UpdateAccount)
+		authGroup.DELETE("/account/:id", accountHandler.DeleteAccount)
+		authGroup.GET("/account/backup", backupHandler.Backup)    // 下载全部数据的备份
+		authGroup.POST("/account/restore", backupHandler.Restore) // 用备份替换全部数据
+
+		authGroup.POST("/recurring_rule", recurringHandler.CreateRule)
+		authGroup.GET("/recurring_rules", recurringHandler.GetRules)
+		authGroup.GET("/recurring_rule/:id", recurringHandler.GetRule)
+		authGroup.PUT("/recurring_rule/:id", recurringHandler.UpdateRule)
+		authGroup.DELETE("/recurring_rule/:id", recurringHandler.DeleteRule)
+
+		authGroup.POST("/budget", budgetHandler.CreateBudget)
+		authGroup.GET("/budgets", budgetHandler.GetBudgets)
+		authGroup.GET("/budgets/status", budgetHandler.GetBudgetStatus) // 预算执行情况
+		authGroup.PUT("/budget/:id", budgetHandler.UpdateBudget)
+		authGroup.DELETE("/budget/:id", budgetHandler.DeleteBudget)
+
+		authGroup.POST("/import/csv", importHandler.ImportCSV)
+		authGroup.POST("/import/alipay", importHandler.ImportAlipay)
+		authGroup.POST("/import/wechat", importHandler.ImportWechat)
+		authGroup.POST("/import/ofx", importHandler.ImportOFX)
+		authGroup.POST("/import/qif", importHandler.ImportQIF)
+		authGroup.POST("/import/ledger", importHandler.ImportLedger)
+		authGroup.GET("/export", exportHandler.ExportTransactions) // format=csv|json|xlsx
 
 		authGroup.GET("/stats/summary", statHandler.GetSummary)
 		authGroup.GET("/stats/monthly", statHandler.GetMonthlyStats)
 		authGroup.GET("/stats/weekly", statHandler.GetWeeklyStats)
 		authGroup.GET("/stats/daily", statHandler.GetDailyStats)
 		authGroup.GET("/stats/range_amount", statHandler.GetRangeAmountStats)
+		authGroup.GET("/stats/accounts", statHandler.GetAccountBalances)
+		authGroup.GET("/stats/categories", statHandler.GetCategoryStats) // 按类别树汇总
+		authGroup.GET("/stats/tags", statHandler.GetTagStats)            // 按标签汇总
+		authGroup.GET("/stats/payees", statHandler.GetTopPayees)         // 支出最多的商户
 	}
 	r.Run(":8080")
 }
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type User struct {
 	ID        int64  `json:"id"`
 	Username  string `json:"username"`
@@ -9,26 +11,246 @@ type User struct {
 
 type Transaction struct {
 	ID         int64  `json:"id"`
-	Type       string `json:"type"`   // "income" 或 "expense"
+	Type       string `json:"type"`   // "income"、"expense" 或 "transfer"
 	Amount     int64  `json:"amount"` // 已修改金额存储类型
 	CategoryID int64  `json:"category"`
-	Note       string `json:"note"`
-	CreatedAt  string `json:"created_at"`
+	AccountID  int64  `json:"account_id"`  // 0 表示未指定账户
+	PayeeID    int64  `json:"payee_id"`    // 0 表示未指定商户
+	TransferID int64  `json:"transfer_id"` // 转账两条腿共用的 id，0 表示不是转账
+	// 由周期规则生成时的规则 id 与第几次发生（从 0 开始），0 表示手工记录
+	RecurringRuleID int64  `json:"recurring_rule_id"`
+	RecurringSeq    int    `json:"-"`
+	ExternalID      string `json:"-"` // 导入来源中的唯一编号，用于去重
+	Note            string `json:"note"`
+	OccurredAt      string `json:"occurred_at"` // 账单实际发生时间（本地时间，用户可指定）
+	CreatedAt       string `json:"created_at"`  // 记录创建时间（审计用）
+	UpdatedAt       string `json:"updated_at"`  // 最后修改时间（审计用）
 }
 
 type DisplayTransaction struct {
 	ID           int64  `json:"id"`
-	Type         string `json:"type"` // "income" 或 "expense"
+	Type         string `json:"type"` // "income"、"expense" 或 "transfer"
 	Amount       string `json:"amount"`
+	CategoryID   int64  `json:"category_id"` // 0 表示未分类
 	CategoryName string `json:"category_name"`
-	Note         string `json:"note"`
-	CreatedAt    string `json:"created_at"`
+	AccountID    int64  `json:"account_id"`
+	AccountName  string `json:"account_name"`
+	PayeeID      int64  `json:"payee_id"` // 0 表示未指定商户
+	PayeeName    string `json:"payee_name"`
+	TransferID   int64  `json:"transfer_id"`
+	// 由周期规则自动生成时为规则 id
+	RecurringRuleID int64    `json:"recurring_rule_id"`
+	Tags            []string `json:"tags"` // 按名称排序
+	// 拆分行；有拆分时账单本身不属于任何类别，统计按各行的类别计
+	Splits     []TransactionSplit `json:"splits,omitempty"`
+	Note       string             `json:"note"`
+	OccurredAt string             `json:"occurred_at"`
+	CreatedAt  string             `json:"created_at"`
+	UpdatedAt  string             `json:"updated_at"`
+	DeletedAt  string             `json:"deleted_at,omitempty"` // 在回收站中时为删除时间
+}
+
+// 拆分行：一笔账单（如超市小票）按类别分成的多行，各行金额之和等于账单金额
+type TransactionSplit struct {
+	ID            int64  `json:"id"`
+	TransactionID int64  `json:"-"`
+	CategoryID    int64  `json:"category_id"` // 0 表示类别已被删除
+	CategoryName  string `json:"category_name"`
+	Amount        int64  `json:"amount"` // 分，符号与账单一致
+	AmountStr     string `json:"amount_str"`
+	Note          string `json:"note"`
+}
+
+// 标签：账单的自由标记（如 "出差"、"报销"），一笔账单可以有多个标签
+type Tag struct {
+	ID               int64  `json:"id"`
+	Name             string `json:"name"`
+	TransactionCount int64  `json:"transaction_count"`
+	CreatedAt        string `json:"created_at"`
+}
+
+// 合并标签的结果
+type TagMergeResult struct {
+	TargetID     int64   `json:"target_id"`
+	SourceIDs    []int64 `json:"source_ids"`
+	Transactions int64   `json:"transactions"` // 新加上目标标签的账单数（已有目标标签的不计）
+}
+
+// 账单附件（小票照片、发票 PDF 等）
+type Attachment struct {
+	ID            int64  `json:"id"`
+	TransactionID int64  `json:"transaction_id"`
+	FileName      string `json:"file_name"`
+	MimeType      string `json:"mime_type"`
+	Size          int64  `json:"size"` // 字节数
+	HasThumbnail  bool   `json:"has_thumbnail"`
+	StoredName    string `json:"-"` // 附件目录中的文件名
+	ThumbnailName string `json:"-"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// 商户（交易对方，如 "星巴克"）：别名用于把不同写法（"Starbucks"、"星巴克咖啡"）识别为同一商户
+type Payee struct {
+	ID                  int64    `json:"id"`
+	Name                string   `json:"name"`
+	Aliases             []string `json:"aliases"`
+	DefaultCategoryID   int64    `json:"default_category_id"` // 0 表示没有默认类别
+	DefaultCategoryName string   `json:"default_category_name"`
+	DefaultCategoryKind string   `json:"-"`
+	TransactionCount    int64    `json:"transaction_count"`
+	CreatedAt           string   `json:"created_at"`
+}
+
+// 合并商户的结果
+type PayeeMergeResult struct {
+	TargetID      int64   `json:"target_id"`
+	SourceIDs     []int64 `json:"source_ids"`
+	Transactions  int64   `json:"transactions"`   // 改记到目标商户的账单数
+	CategoryRules int64   `json:"category_rules"` // 改指向目标商户的自动分类规则数
+	AliasesAdded  int64   `json:"aliases_added"`  // 来源商户的名称与别名成为目标商户的别名
+}
+
+// 按商户汇总的支出（金额单位：分，负数）
+type PayeeStat struct {
+	PayeeID          int64  `json:"payee_id"`
+	Name             string `json:"name"`
+	Expense          int64  `json:"expense"`
+	ExpenseStr       string `json:"expense_str"`
+	TransactionCount int64  `json:"transaction_count"`
+}
+
+// 按标签汇总的统计（金额单位：分）；一笔账单有多个标签时计入每个标签
+type TagStat struct {
+	TagID            int64  `json:"tag_id"`
+	Name             string `json:"name"`
+	Income           int64  `json:"income"`
+	Expense          int64  `json:"expense"` // 负数
+	Net              int64  `json:"net"`
+	IncomeStr        string `json:"income_str"`
+	ExpenseStr       string `json:"expense_str"`
+	NetStr           string `json:"net_str"`
+	TransactionCount int64  `json:"transaction_count"`
 }
 
 type Category struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_at"`
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  int64      `json:"parent_id"` // 0 表示顶级类别
+	Kind      string     `json:"kind"`      // "income"、"expense" 或 "both"（收支均可）
+	Icon      string     `json:"icon"`
+	Color     string     `json:"color"` // "#RRGGBB"，空表示未设置
+	CreatedAt string     `json:"created_at"`
+	DeletedAt string     `json:"deleted_at,omitempty"` // 在回收站中时为删除时间
+	Children  []Category `json:"children,omitempty"`
+}
+
+// 类别合并的结果（移动的记录数）
+type CategoryMergeResult struct {
+	TargetID       int64   `json:"target_id"`
+	SourceIDs      []int64 `json:"source_ids"`
+	Transactions   int64   `json:"transactions"` // 改记到目标类别的账单数（拆分账单有多行属于来源类别时只计一次）
+	RecurringRules int64   `json:"recurring_rules"`
+	BudgetsMoved   int64   `json:"budgets_moved"`
+	BudgetsDropped int64   `json:"budgets_dropped"` // 目标类别在同一周期已有预算，来源的预算被删除
+	CategoryRules  int64   `json:"category_rules"`
+}
+
+// 变更历史中的一条记录（只追加，不修改）
+type HistoryEntry struct {
+	ID          int64           `json:"id"`
+	EntityType  string          `json:"entity_type"` // "transaction" 或 "category"
+	EntityID    int64           `json:"entity_id"`   // 转账为 transfer_id
+	Action      string          `json:"action"`      // create / update / delete / restore / purge / revert / merge
+	OldValues   json.RawMessage `json:"old_values"`  // 变更前的快照，新建时为 null
+	NewValues   json.RawMessage `json:"new_values"`  // 变更后的快照，删除时为 null
+	ActorUserID int64           `json:"actor_user_id"`
+	Session     string          `json:"session"` // 会话 id 的摘要，后台任务为空
+	CreatedAt   string          `json:"created_at"`
+}
+
+// 账单在某一时刻的状态（变更历史中的快照），金额单位为分
+type TransactionSnapshot struct {
+	Type        string          `json:"type"`
+	Amount      int64           `json:"amount"` // 带符号；转账为转账金额（正数）
+	CategoryID  int64           `json:"category_id"`
+	AccountID   int64           `json:"account_id"`              // 转账为转出账户
+	ToAccountID int64           `json:"to_account_id,omitempty"` // 仅转账：转入账户
+	PayeeID     int64           `json:"payee_id"`
+	Note        string          `json:"note"`
+	OccurredAt  string          `json:"occurred_at"`
+	Tags        []string        `json:"tags"`
+	Splits      []SplitSnapshot `json:"splits,omitempty"`
+}
+
+// 快照中的拆分行
+type SplitSnapshot struct {
+	CategoryID int64  `json:"category_id"`
+	Amount     int64  `json:"amount"`
+	Note       string `json:"note"`
+}
+
+// 类别在某一时刻的状态（变更历史中的快照）
+type CategorySnapshot struct {
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+	Kind     string `json:"kind"`
+	Icon     string `json:"icon"`
+	Color    string `json:"color"`
+}
+
+// 回收站的内容：账单按删除时间倒序分页，类别全部返回
+type Trash struct {
+	Transactions  *TransactionPage
+	Categories    []Category
+	RetentionDays int // 超过该天数自动彻底删除，0 表示不自动删除
+}
+
+// 彻底删除回收站内容的结果（删除的记录数，转账的两条腿各算一笔）
+type TrashPurgeResult struct {
+	Transactions int64 `json:"transactions"`
+	Categories   int64 `json:"categories"`
+}
+
+// 批量操作账单的结果：每个目标账单一条结果，顺序与请求中的 id（或筛选结果）一致
+type BulkTransactionResult struct {
+	Operation string                   `json:"operation"`
+	Total     int                      `json:"total"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Results   []BulkTransactionOutcome `json:"results"`
+}
+
+// 批量操作中单笔账单的结果
+type BulkTransactionOutcome struct {
+	ID      int64  `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 类别建议：按历史账单计算的候选类别
+type CategorySuggestion struct {
+	CategoryID int64   `json:"category_id"`
+	Name       string  `json:"name"`
+	Icon       string  `json:"icon"`
+	Color      string  `json:"color"`
+	Score      float64 `json:"score"`   // 0~1，该类别在全部类别得分中的占比
+	Matches    int     `json:"matches"` // 备注与输入有相同词语的历史账单数
+}
+
+// 类别统计（按类别树汇总）：Amount、TransactionCount 包含全部子类别，
+// OwnAmount、OwnCount 只统计直接记在该类别下的账单
+type CategoryStat struct {
+	CategoryID       int64          `json:"category_id"` // 0 表示未分类
+	Name             string         `json:"name"`
+	ParentID         int64          `json:"parent_id"`
+	Amount           int64          `json:"amount"`
+	AmountStr        string         `json:"amount_str"`
+	TransactionCount int            `json:"transaction_count"`
+	OwnAmount        int64          `json:"own_amount"`
+	OwnCount         int            `json:"own_count"`
+	Children         []CategoryStat `json:"children,omitempty"`
+	SplitIDs         []int64        `json:"-"` // 直接记在该类别下的拆分账单 id，汇总时据此去重
+
 }
 
 type RangeAmountStat struct {
@@ -37,3 +259,191 @@ type RangeAmountStat struct {
 	Amount           int64  `json:"amount"`
 	AmountStr        string `json:"amount_str"`
 }
+
+// 账单查询条件（由 service 层校验并转换后传给数据层）
+// 金额单位为"分"（按绝对值比较），时间格式为 "2006-01-02 15:04:05"
+type TransactionFilter struct {
+	StartTime    string // 发生时间起点（包含）
+	EndTime      string // 发生时间终点（不包含）
+	Type         string // "income" / "expense" / "transfer"，空表示不限
+	CategoryID   *int64 // 0 表示未分类，其他值包含其子类别
+	CategoryName string
+	AccountID    *int64 // 0 表示未指定账户
+	PayeeID      *int64 // 0 表示未指定商户
+	MinAmount    *int64
+	MaxAmount    *int64
+	Note         string  // 备注包含的子串
+	TagIDs       []int64 // 同时带有这些标签
+	Trashed      bool    // 为 true 时只查回收站中的账单，否则只查未删除的账单
+	SortBy       string  // occurred_at / created_at / amount / id / deleted_at
+	SortOrder    string  // asc / desc
+	Limit        int
+	Offset       int
+	Cursor       string // 上一页返回的 next_page_token，存在时忽略 Offset
+}
+
+// 分页后的账单列表
+type TransactionPage struct {
+	Transactions  []DisplayTransaction `json:"transactions"`
+	Total         int64                `json:"total"`
+	NextPageToken string               `json:"next_page_token"`
+}
+
+// 账户（钱包）：现金、银行卡、支付宝等
+type Account struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	Kind           string `json:"kind"`            // cash / bank / credit / alipay / wechat / other
+	OpeningBalance int64  `json:"opening_balance"` // 期初余额（分），可为负（如信用卡欠款）
+	Currency       string `json:"currency"`
+	Archived       bool   `json:"archived"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// 账户余额统计（金额单位：分）
+type AccountBalance struct {
+	AccountID        int64  `json:"account_id"`
+	Name             string `json:"name"`
+	Kind             string `json:"kind"`
+	Currency         string `json:"currency"`
+	Archived         bool   `json:"archived"`
+	OpeningBalance   int64  `json:"opening_balance"`
+	Inflow           int64  `json:"inflow"`  // 流入合计
+	Outflow          int64  `json:"outflow"` // 流出合计（负数）
+	Balance          int64  `json:"balance"` // 期初余额 + 流入 + 流出
+	BalanceStr       string `json:"balance_str"`
+	TransactionCount int    `json:"transaction_count"`
+}
+
+// 周期账单规则（房租、工资、订阅等），由后台任务按计划生成账单
+type RecurringRule struct {
+	ID           int64  `json:"id"`
+	Type         string `json:"type"`   // "income" 或 "expense"
+	Amount       int64  `json:"amount"` // 带符号的金额（分）
+	CategoryID   int64  `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	AccountID    int64  `json:"account_id"`
+	Note         string `json:"note"`
+	Frequency    string `json:"frequency"`  // daily / weekly / monthly / yearly
+	Interval     int    `json:"interval"`   // 每隔几个周期发生一次
+	StartDate    string `json:"start_date"` // 第一次发生的时间
+	EndDate      string `json:"end_date"`   // 最后可发生的时间（包含），空表示不限
+	Count        int    `json:"count"`      // 最多发生次数，0 表示不限
+	Occurrences  int    `json:"occurrences"`
+	NextRun      string `json:"next_run"` // 空表示规则已结束
+	Active       bool   `json:"active"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+// 自动分类规则：所有设置了的条件都满足时，把账单记到 CategoryID 类别
+type CategoryRule struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	Priority     int      `json:"priority"` // 数值小的先匹配，相同时按 id
+	CategoryID   int64    `json:"category_id"`
+	CategoryName string   `json:"category_name"`
+	CategoryKind string   `json:"-"`
+	Type         string   `json:"type"`          // 空表示不限
+	NoteContains string   `json:"note_contains"` // 不区分大小写
+	NoteRegex    string   `json:"note_regex"`
+	MinAmount    int64    `json:"min_amount"` // 分（绝对值），0 表示不限
+	MaxAmount    int64    `json:"max_amount"` // 分（绝对值），0 表示不限
+	AccountID    int64    `json:"account_id"` // 0 表示不限
+	PayeeID      int64    `json:"payee_id"`   // 0 表示不限
+	PayeeName    string   `json:"payee_name"`
+	Tags         []string `json:"tags"` // 匹配后给账单加上的标签
+	Active       bool     `json:"active"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+// 对已有账单重新运行自动分类规则的结果
+type CategoryRuleApplyResult struct {
+	DryRun  bool                 `json:"dry_run"`
+	Checked int                  `json:"checked"` // 检查的账单数
+	Changed int                  `json:"changed"` // 类别发生变化的账单数
+	Changes []CategoryRuleChange `json:"changes"`
+}
+
+// 一笔账单的类别变化
+type CategoryRuleChange struct {
+	TransactionID   int64    `json:"transaction_id"`
+	OccurredAt      string   `json:"occurred_at"`
+	Type            string   `json:"type"`
+	Amount          string   `json:"amount"`
+	Note            string   `json:"note"`
+	OldCategoryID   int64    `json:"old_category_id"`
+	OldCategoryName string   `json:"old_category_name"`
+	NewCategoryID   int64    `json:"new_category_id"`
+	NewCategoryName string   `json:"new_category_name"`
+	AddedTags       []string `json:"added_tags"` // 规则给账单新加上的标签
+	RuleID          int64    `json:"rule_id"`
+}
+
+// 预算：某个类别（或总体）在每个周期内的支出额度
+type Budget struct {
+	ID           int64  `json:"id"`
+	CategoryID   int64  `json:"category_id"` // 0 表示总体预算
+	CategoryName string `json:"category_name"`
+	Period       string `json:"period"`   // month / week / year
+	Amount       int64  `json:"amount"`   // 每个周期的额度（分）
+	Rollover     bool   `json:"rollover"` // 未花完的额度结转到下一周期
+	StartDate    string `json:"start_date"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+// 预算在某个周期内的执行情况（金额单位：分）
+type BudgetStatus struct {
+	BudgetID     int64   `json:"budget_id"`
+	CategoryID   int64   `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Period       string  `json:"period"`
+	PeriodStart  string  `json:"period_start"`
+	PeriodEnd    string  `json:"period_end"` // 不包含
+	Limit        int64   `json:"limit"`
+	Carryover    int64   `json:"carryover"` // 从之前周期结转来的额度
+	Available    int64   `json:"available"` // limit + carryover
+	Spent        int64   `json:"spent"`
+	Remaining    int64   `json:"remaining"` // 为负表示超支
+	Percent      float64 `json:"percent"`   // spent / available * 100
+	Overspent    bool    `json:"overspent"`
+	SpentStr     string  `json:"spent_str"`
+	RemainingStr string  `json:"remaining_str"`
+}
+
+// 导入结果（dry-run 时不写入数据库，只返回校验结果与预览）
+type ImportResult struct {
+	DryRun   bool               `json:"dry_run"`
+	Total    int                `json:"total"`    // 解析出的记录数
+	Imported int                `json:"imported"` // 写入（dry-run 时为可写入）的记录数
+	Skipped  int                `json:"skipped"`  // 被跳过的记录数（如重复导入）
+	Errors   []ImportRowError   `json:"errors"`
+	Preview  []ImportPreviewRow `json:"preview,omitempty"`
+}
+
+// 导入中某一行的错误（Line 为源文件中的行号，从 1 开始）
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// dry-run 时返回的解析结果
+type ImportPreviewRow struct {
+	Line       int    `json:"line"`
+	Type       string `json:"type"`
+	Amount     string `json:"amount"`
+	Category   string `json:"category"`
+	Payee      string `json:"payee,omitempty"`
+	Note       string `json:"note"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// 恢复备份的结果
+type RestoreResult struct {
+	SchemaVersion int            `json:"schema_version"` // 备份时的数据库结构版本
+	CreatedAt     string         `json:"created_at"`     // 备份的时间
+	Tables        map[string]int `json:"tables"`         // 每张表恢复的行数
+}
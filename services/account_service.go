@@ -0,0 +1,159 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"strings"
+)
+
+// 账户服务
+type AccountService struct {
+	masterDB *sql.DB
+}
+
+// 新建账户服务的方法
+func NewAccountService(masterDB *sql.DB) *AccountService {
+	return &AccountService{masterDB: masterDB}
+}
+
+// 允许的账户类型
+var accountKinds = map[string]bool{
+	"cash":   true, // 现金
+	"bank":   true, // 银行卡
+	"credit": true, // 信用卡
+	"alipay": true, // 支付宝
+	"wechat": true, // 微信
+	"other":  true,
+}
+
+const defaultCurrency = "CNY"
+
+// normalizeCurrency 货币代码统一为大写的三位字母（ISO 4217），空表示默认人民币
+func normalizeCurrency(currency string) (string, error) {
+	c := strings.ToUpper(strings.TrimSpace(currency))
+	if c == "" {
+		return defaultCurrency, nil
+	}
+	if len(c) != 3 {
+		return "", utils.ErrInvalidParameter
+	}
+	for _, ch := range c {
+		if ch < 'A' || ch > 'Z' {
+			return "", utils.ErrInvalidParameter
+		}
+	}
+	return c, nil
+}
+
+// 新建账户服务（openingBalance 为元，可带负号，空表示 0）
+func (s *AccountService) CreateAccount(userID int64, name string, kind string, openingBalance string, currency string) (int64, error) {
+	account := models.Account{Name: strings.TrimSpace(name), Kind: kind}
+	if account.Name == "" {
+		return 0, utils.ErrEmptyContent
+	}
+	if account.Kind == "" {
+		account.Kind = "cash"
+	}
+	if !accountKinds[account.Kind] {
+		return 0, utils.ErrInvalidAccountKind
+	}
+	if openingBalance != "" {
+		cents, err := utils.ParseSignedToCents(openingBalance)
+		if err != nil {
+			return 0, err
+		}
+		account.OpeningBalance = cents
+	}
+	c, err := normalizeCurrency(currency)
+	if err != nil {
+		return 0, err
+	}
+	account.Currency = c
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+	return database.CreateAccount(userDB, &account)
+}
+
+// 获取账户列表服务
+func (s *AccountService) GetAccounts(userID int64, includeArchived bool) ([]models.Account, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetAccounts(userDB, includeArchived)
+}
+
+// 获取单个账户服务
+func (s *AccountService) GetAccount(userID int64, accountID int64) (*models.Account, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetAccountByID(userDB, accountID)
+}
+
+// 更新账户服务（nil 表示不更新）
+func (s *AccountService) UpdateAccount(userID int64, accountID int64, name *string, kind *string, openingBalance *string, currency *string, archived *bool) error {
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return utils.ErrEmptyContent
+		}
+		name = &trimmed
+	}
+	if kind != nil && !accountKinds[*kind] {
+		return utils.ErrInvalidAccountKind
+	}
+	var balancePtr *int64
+	if openingBalance != nil {
+		cents, err := utils.ParseSignedToCents(*openingBalance)
+		if err != nil {
+			return err
+		}
+		balancePtr = &cents
+	}
+	if currency != nil {
+		c, err := normalizeCurrency(*currency)
+		if err != nil {
+			return err
+		}
+		currency = &c
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.UpdateAccount(userDB, accountID, name, kind, balancePtr, currency, archived)
+}
+
+// 删除账户服务
+func (s *AccountService) DeleteAccount(userID int64, accountID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeleteAccount(userDB, accountID)
+}
+
+// checkAccountUsable 校验账户存在且未归档（记账时使用）
+func checkAccountUsable(db database.DBTX, accountID int64) error {
+	account, err := database.GetAccountByID(db, accountID)
+	if err != nil {
+		return err
+	}
+	if account.Archived {
+		return utils.ErrAccountArchived
+	}
+	return nil
+}
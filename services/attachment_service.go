@@ -0,0 +1,312 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器，用于生成缩略图
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码器，用于生成缩略图
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 附件服务
+// 账单可以附带小票照片、发票 PDF 等文件。文件类型按内容识别（不信任文件名与客户端声明的类型），
+// 以随机文件名保存在用户的附件目录中；JPEG、PNG、GIF 图片同时生成一张 JPEG 缩略图。
+// 账单被删除后，不再被任何附件记录引用的文件会被清理。
+type AttachmentService struct {
+	masterDB *sql.DB
+}
+
+// 新建附件服务的方法
+func NewAttachmentService(masterDB *sql.DB) *AttachmentService {
+	return &AttachmentService{masterDB: masterDB}
+}
+
+const (
+	MaxAttachmentSize       = 10 << 20 // 单个附件的最大字节数
+	maxAttachmentsPerTx     = 20       // 每笔账单最多的附件数
+	maxAttachmentNameLength = 255      // 原始文件名的最大字符数
+	thumbnailMaxSide        = 320      // 缩略图长边的像素数
+	thumbnailMaxPixels      = 40 << 20 // 超过该像素数的图片不生成缩略图，避免解码占用过多内存
+	thumbnailQuality        = 80
+)
+
+// 允许上传的文件类型（按内容识别的 MIME 类型）及保存时使用的扩展名
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// 上传附件服务：r 为文件内容（最多读取 MaxAttachmentSize+1 字节以判断是否过大），fileName 为原始文件名
+func (s *AttachmentService) UploadAttachment(userID int64, transactionID int64, fileName string, r io.Reader) (*models.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrReadFailed, err)
+	}
+	if len(data) == 0 {
+		return nil, utils.ErrEmptyContent
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, utils.ErrAttachmentTooLarge
+	}
+	mimeType, ext, ok := sniffAttachmentType(data)
+	if !ok {
+		return nil, utils.ErrAttachmentTypeRejected
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	if _, err := database.GetTransactionByID(userDB, transactionID); err != nil {
+		return nil, err
+	}
+	existing, err := database.GetAttachments(userDB, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAttachmentsPerTx {
+		return nil, utils.ErrTooManyAttachments
+	}
+
+	dir := database.UserAttachmentDir(userID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, utils.WrapError(utils.ErrCreateDirFailed, err)
+	}
+	stem, err := randomFileStem()
+	if err != nil {
+		return nil, err
+	}
+	attachment := &models.Attachment{
+		TransactionID: transactionID,
+		FileName:      cleanAttachmentName(fileName, ext),
+		StoredName:    stem + ext,
+		MimeType:      mimeType,
+		Size:          int64(len(data)),
+	}
+	// 缩略图生成失败（如图片已损坏）不影响附件本身
+	thumb := makeThumbnail(data)
+	if thumb != nil {
+		attachment.ThumbnailName = stem + "_thumb.jpg"
+	}
+
+	// 先写入记录再写文件：目录中的文件总是有记录引用，清理孤立文件时不会误删正在上传的文件
+	if attachment.ID, err = database.CreateAttachment(userDB, attachment); err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(dir, attachment.StoredName), data, 0644)
+	if err == nil && thumb != nil {
+		err = os.WriteFile(filepath.Join(dir, attachment.ThumbnailName), thumb, 0644)
+	}
+	if err != nil {
+		database.DeleteAttachment(userDB, attachment.ID)
+		removeAttachmentFiles(dir, *attachment)
+		return nil, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return database.GetAttachmentByID(userDB, attachment.ID)
+}
+
+// 获取账单的全部附件服务
+func (s *AttachmentService) GetAttachments(userID int64, transactionID int64) ([]models.Attachment, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	if _, err := database.GetTransactionByID(userDB, transactionID); err != nil {
+		return nil, err
+	}
+	return database.GetAttachments(userDB, transactionID)
+}
+
+// 获取附件文件服务：返回附件及其文件（thumbnail 为 true 时为缩略图）的路径
+func (s *AttachmentService) GetAttachmentFile(userID int64, attachmentID int64, thumbnail bool) (*models.Attachment, string, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	defer userDB.Close()
+
+	attachment, err := database.GetAttachmentByID(userDB, attachmentID)
+	if err != nil {
+		return nil, "", err
+	}
+	name := attachment.StoredName
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			return nil, "", utils.ErrAttachmentNotFound
+		}
+		name = attachment.ThumbnailName
+	}
+	path := filepath.Join(database.UserAttachmentDir(userID), name)
+	if _, err := os.Stat(path); err != nil {
+		// 记录还在但文件已丢失（如恢复了其他服务器上的备份）
+		return nil, "", utils.ErrAttachmentNotFound
+	}
+	return attachment, path, nil
+}
+
+// 删除附件服务
+func (s *AttachmentService) DeleteAttachment(userID int64, attachmentID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	attachment, err := database.GetAttachmentByID(userDB, attachmentID)
+	if err != nil {
+		return err
+	}
+	if err := database.DeleteAttachment(userDB, attachmentID); err != nil {
+		return err
+	}
+	removeAttachmentFiles(database.UserAttachmentDir(userID), *attachment)
+	return nil
+}
+
+// removeOrphanAttachmentFiles 删除用户附件目录中不再被任何附件记录引用的文件（账单删除、恢复备份之后调用）。
+// 清理失败不影响调用方的操作，下次清理时会再次尝试
+func removeOrphanAttachmentFiles(userDB database.DBTX, userID int64) {
+	dir := database.UserAttachmentDir(userID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // 目录不存在说明没有上传过附件
+	}
+	referenced, err := database.GetAttachmentFileNames(userDB)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || referenced[entry.Name()] {
+			continue
+		}
+		os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// removeAttachmentFiles 删除附件的文件与缩略图，文件已不存在时忽略
+func removeAttachmentFiles(dir string, a models.Attachment) {
+	os.Remove(filepath.Join(dir, a.StoredName))
+	if a.ThumbnailName != "" {
+		os.Remove(filepath.Join(dir, a.ThumbnailName))
+	}
+}
+
+// sniffAttachmentType 按文件内容识别类型，返回 MIME 类型与保存时的扩展名；不允许的类型返回 false
+func sniffAttachmentType(data []byte) (string, string, bool) {
+	mimeType := http.DetectContentType(data)
+	ext, ok := attachmentTypes[mimeType]
+	return mimeType, ext, ok
+}
+
+// cleanAttachmentName 只保留原始文件名的最后一段（去掉路径与控制字符），过长时截断；为空时使用 "attachment"+ext
+func cleanAttachmentName(name string, ext string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "attachment" + ext
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentNameLength {
+		name = string([]rune(name)[:maxAttachmentNameLength])
+	}
+	return name
+}
+
+// randomFileStem 生成附件在目录中的随机文件名（不含扩展名）
+func randomFileStem() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// makeThumbnail 为 JPEG、PNG、GIF 图片生成长边不超过 thumbnailMaxSide 的 JPEG 缩略图；
+// 其他类型、无法解码或过大的图片返回 nil
+func makeThumbnail(data []byte) []byte {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > thumbnailMaxPixels {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	width, height := thumbnailSize(img.Bounds().Dx(), img.Bounds().Dy(), thumbnailMaxSide)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(img, width, height), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// thumbnailSize 按比例缩小到长边不超过 maxSide 的尺寸（不放大），短边至少为 1
+func thumbnailSize(width, height, maxSide int) (int, int) {
+	if width <= maxSide && height <= maxSide {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+// scaleImage 把图片缩小为 width × height：每个目标像素取对应源区域内所有像素的平均值。
+// 透明部分按白色背景合成（JPEG 不支持透明）
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					// 预乘 alpha 的颜色加上白色背景中未被覆盖的部分
+					white := 0xffff - ca
+					r += uint64(cr + white)
+					g += uint64(cg + white)
+					b += uint64(cb + white)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSniffAttachmentType(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		ext      string
+		ok       bool
+	}{
+		{"PNG", pngData.Bytes(), "image/png", ".png", true},
+		{"JPEG", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00"), "image/jpeg", ".jpg", true},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00"), "image/gif", ".gif", true},
+		{"WebP", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp", ".webp", true},
+		{"PDF", []byte("%PDF-1.7\n%âãÏÓ\n"), "application/pdf", ".pdf", true},
+		{"文本", []byte("hello"), "", "", false},
+		{"HTML", []byte("<html><script>alert(1)</script>"), "", "", false},
+		{"ZIP", []byte("PK\x03\x04"), "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mimeType, ext, ok := sniffAttachmentType(tt.data)
+			if ok != tt.ok || (ok && (mimeType != tt.mimeType || ext != tt.ext)) {
+				t.Errorf("sniffAttachmentType = %q, %q, %v, want %q, %q, %v", mimeType, ext, ok, tt.mimeType, tt.ext, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCleanAttachmentName(t *testing.T) {
+	tests := map[string]string{
+		"小票.jpg":                  "小票.jpg",
+		"../../etc/passwd":        "passwd",
+		`C:\Users\me\发票 2024.pdf`: "发票 2024.pdf",
+		"  a\x00b\n.png ":         "ab.png",
+		"":                        "attachment.pdf",
+		"dir/":                    "attachment.pdf",
+		"..":                      "attachment.pdf",
+		strings.Repeat("长", 300):  strings.Repeat("长", maxAttachmentNameLength),
+	}
+	for input, expected := range tests {
+		if got := cleanAttachmentName(input, ".pdf"); got != expected {
+			t.Errorf("cleanAttachmentName(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
+
+func TestThumbnailSize(t *testing.T) {
+	tests := []struct {
+		width, height, wantW, wantH int
+	}{
+		{100, 50, 100, 50}, // 不放大
+		{320, 320, 320, 320},
+		{1600, 1200, 320, 240},
+		{1200, 1600, 240, 320},
+		{10000, 10, 320, 1}, // 短边至少为 1
+	}
+	for _, tt := range tests {
+		w, h := thumbnailSize(tt.width, tt.height, 320)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("thumbnailSize(%d, %d) = %d×%d, want %d×%d", tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	// 左半红色、右半透明的 640×200 图片
+	src := image.NewNRGBA(image.Rect(0, 0, 640, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 320; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var data bytes.Buffer
+	if err := png.Encode(&data, src); err != nil {
+		t.Fatal(err)
+	}
+
+	thumb := makeThumbnail(data.Bytes())
+	if thumb == nil {
+		t.Fatal("makeThumbnail returned nil for a PNG image")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 320 || b.Dy() != 100 {
+		t.Fatalf("thumbnail size = %d×%d, want 320×100", b.Dx(), b.Dy())
+	}
+	// JPEG 有损，颜色只做近似比较；透明部分合成为白色
+	if r, g, b, _ := img.At(80, 50).RGBA(); r>>8 < 200 || g>>8 > 60 || b>>8 > 60 {
+		t.Errorf("left pixel = (%d, %d, %d), want red", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := img.At(240, 50).RGBA(); r>>8 < 200 || g>>8 < 200 || b>>8 < 200 {
+		t.Errorf("right pixel = (%d, %d, %d), want white", r>>8, g>>8, b>>8)
+	}
+
+	if makeThumbnail([]byte("%PDF-1.7\n")) != nil {
+		t.Error("makeThumbnail returned a thumbnail for a PDF")
+	}
+	if makeThumbnail(data.Bytes()[:100]) != nil {
+		t.Error("makeThumbnail returned a thumbnail for a truncated PNG")
+	}
+}
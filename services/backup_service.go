@@ -0,0 +1,309 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 备份与恢复服务
+// 备份文件是 gzip 压缩的 JSON：格式名与格式版本、用户数据库的结构版本（PRAGMA user_version）、
+// 备份时间、用户名，以及用户数据库中每张表的列名和全部行。以后新增的表会自动包含在备份中。
+// 恢复时按备份的结构版本新建数据库、写入全部数据，再迁移到当前版本并替换用户原来的数据库，
+// 因此旧版本服务产生的备份可以恢复到新版本的服务上。主库中的用户名、密码不在恢复范围内。
+// 附件只备份数据库中的记录，附件文件本身不在备份文件中。
+type BackupService struct {
+	masterDB  *sql.DB
+	restoreMu sync.Mutex // 同一时间只进行一次恢复
+}
+
+// 新建备份服务的方法
+func NewBackupService(masterDB *sql.DB) *BackupService {
+	return &BackupService{masterDB: masterDB}
+}
+
+const (
+	backupFormat        = "accounting-assistant-backup"
+	backupFormatVersion = 1
+	maxBackupSize       = 512 << 20 // 解压后的最大字节数
+)
+
+// backupHeader 备份文件中 tables 之前的字段
+type backupHeader struct {
+	Format        string `json:"format"`
+	FormatVersion int    `json:"format_version"`
+	SchemaVersion int    `json:"schema_version"`
+	CreatedAt     string `json:"created_at"`
+	Username      string `json:"username"`
+}
+
+// backupArchive 读取备份文件时使用的完整结构
+type backupArchive struct {
+	backupHeader
+	Tables map[string]backupTable `json:"tables"`
+}
+
+// backupTable 一张表的列名与全部行；BLOB 值写为 {"$blob": "base64 内容"}
+type backupTable struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Backup 把用户数据库的全部数据写为备份文件。读取在一个事务中进行，保证各表数据一致。
+func (s *BackupService) Backup(userID int64, w io.Writer) error {
+	username, err := database.GetUsernameByID(s.masterDB, userID)
+	if err != nil {
+		return err
+	}
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 只读事务
+
+	version, err := database.GetUserDBVersion(tx)
+	if err != nil {
+		return err
+	}
+	tables, err := database.UserDBTables(tx)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gz)
+	header, err := json.Marshal(backupHeader{
+		Format:        backupFormat,
+		FormatVersion: backupFormatVersion,
+		SchemaVersion: version,
+		CreatedAt:     utils.FormatDateTime(time.Now()),
+		Username:      username,
+	})
+	if err != nil {
+		return err
+	}
+	bw.Write(header[:len(header)-1])
+	bw.WriteString(`,"tables":{`)
+	for i, table := range tables {
+		columns, err := database.TableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		name, _ := json.Marshal(table)
+		columnsJSON, _ := json.Marshal(columns)
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.Write(name)
+		bw.WriteString(`:{"columns":`)
+		bw.Write(columnsJSON)
+		bw.WriteString(`,"rows":[`)
+		first := true
+		err = database.EachTableRow(tx, table, func(_ []string, values []interface{}) error {
+			row, err := json.Marshal(backupRow(values))
+			if err != nil {
+				return err
+			}
+			if !first {
+				bw.WriteString(",")
+			}
+			first = false
+			_, err = bw.Write(row)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		bw.WriteString("]}")
+	}
+	bw.WriteString("}}\n")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Restore 校验备份文件并用其中的数据替换用户的全部数据（gzip 压缩或未压缩的 JSON 均可）。
+// 任何一步失败都不会改动用户现有的数据。
+func (s *BackupService) Restore(userID int64, r io.Reader) (*models.RestoreResult, error) {
+	archive, err := readBackupArchive(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s.restoreMu.Lock()
+	defer s.restoreMu.Unlock()
+
+	restoreDB, restorePath, err := database.CreateRestoreDatabase(userID, archive.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	replaced := false
+	defer func() {
+		if !replaced {
+			restoreDB.Close()
+			os.Remove(restorePath)
+		}
+	}()
+
+	result := &models.RestoreResult{
+		SchemaVersion: archive.SchemaVersion,
+		CreatedAt:     archive.CreatedAt,
+		Tables:        make(map[string]int),
+	}
+	if err := replayBackupTables(restoreDB, archive, result); err != nil {
+		return nil, err
+	}
+	if err := database.ReplaceUserDatabase(userID, restoreDB, restorePath); err != nil {
+		return nil, err
+	}
+	replaced = true
+
+	// 备份中只有附件的记录，没有文件本身：清理恢复后不再被引用的附件文件
+	if userDB, err := database.GetUserDB(userID); err == nil {
+		removeOrphanAttachmentFiles(userDB, userID)
+		userDB.Close()
+	}
+	return result, nil
+}
+
+// readBackupArchive 读取并校验备份文件的格式与版本
+func readBackupArchive(r io.Reader) (*backupArchive, error) {
+	br := bufio.NewReader(r)
+	var reader io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrBackupInvalid, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	decoder := json.NewDecoder(io.LimitReader(reader, maxBackupSize))
+	decoder.UseNumber()
+	var archive backupArchive
+	if err := decoder.Decode(&archive); err != nil {
+		return nil, utils.WrapError(utils.ErrBackupInvalid, err)
+	}
+	if archive.Format != backupFormat || archive.FormatVersion < 1 {
+		return nil, utils.ErrBackupInvalid
+	}
+	if archive.FormatVersion > backupFormatVersion || archive.SchemaVersion > database.UserDBSchemaVersion() {
+		return nil, utils.ErrBackupUnsupported
+	}
+	if archive.SchemaVersion < 0 {
+		return nil, utils.ErrBackupInvalid
+	}
+	return &archive, nil
+}
+
+// replayBackupTables 在一个事务中把备份中的每张表写入新建的数据库。
+// 表和列都必须存在于该结构版本的数据库中，每行的值个数必须与列数一致。
+func replayBackupTables(db *sql.DB, archive *backupArchive, result *models.RestoreResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	for table, data := range archive.Tables {
+		existing, err := database.TableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 || len(data.Columns) == 0 || !containsAll(existing, data.Columns) {
+			return utils.ErrBackupInvalid
+		}
+		for _, row := range data.Rows {
+			if len(row) != len(data.Columns) {
+				return utils.ErrBackupInvalid
+			}
+			values := make([]interface{}, len(row))
+			for i, cell := range row {
+				if values[i], err = backupCellValue(cell); err != nil {
+					return err
+				}
+			}
+			if err := database.InsertTableRow(tx, table, data.Columns, values); err != nil {
+				return utils.WrapError(utils.ErrBackupInvalid, err)
+			}
+		}
+		result.Tables[table] = len(data.Rows)
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return nil
+}
+
+// backupRow 把一行的值转换为可写入 JSON 的形式（BLOB 以 base64 表示）
+func backupRow(values []interface{}) []interface{} {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			row[i] = map[string]string{"$blob": base64.StdEncoding.EncodeToString(b)}
+			continue
+		}
+		row[i] = v
+	}
+	return row
+}
+
+// backupCellValue 把备份中的一个值还原为写入数据库的值
+func backupCellValue(cell interface{}) (interface{}, error) {
+	switch v := cell.(type) {
+	case nil, string:
+		return v, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrBackupInvalid, err)
+		}
+		return f, nil
+	case map[string]interface{}:
+		if encoded, ok := v["$blob"].(string); ok && len(v) == 1 {
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, utils.WrapError(utils.ErrBackupInvalid, err)
+			}
+			return data, nil
+		}
+	}
+	return nil, utils.ErrBackupInvalid
+}
+
+func containsAll(set []string, items []string) bool {
+	for _, item := range items {
+		found := false
+		for _, s := range set {
+			if strings.EqualFold(s, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
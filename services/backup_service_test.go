@@ -0,0 +1,73 @@
+package services
+
+import (
+	"AccountingAssistant/utils"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadBackupArchive(t *testing.T) {
+	data := `{"format":"accounting-assistant-backup","format_version":1,"schema_version":1,"created_at":"2025-01-01 00:00:00",
+"username":"u","tables":{"categories":{"columns":["id","name"],"rows":[[1,"餐饮"]]}}}`
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(data))
+	w.Close()
+
+	for name, input := range map[string]*bytes.Reader{"plain": bytes.NewReader([]byte(data)), "gzip": bytes.NewReader(gz.Bytes())} {
+		archive, err := readBackupArchive(input)
+		if err != nil {
+			t.Fatalf("%s: readBackupArchive returned error: %v", name, err)
+		}
+		rows := archive.Tables["categories"].Rows
+		if archive.SchemaVersion != 1 || len(rows) != 1 || rows[0][1] != "餐饮" {
+			t.Errorf("%s: unexpected archive %+v", name, archive)
+		}
+	}
+
+	cases := map[string]*utils.Error{
+		`{"format":"other","format_version":1}`:                                            utils.ErrBackupInvalid,
+		`{"format":"accounting-assistant-backup","format_version":2}`:                      utils.ErrBackupUnsupported,
+		`{"format":"accounting-assistant-backup","format_version":1,"schema_version":999}`: utils.ErrBackupUnsupported,
+		`{"format":"accounting-assistant-backup"`:                                          utils.ErrBackupInvalid,
+	}
+	for input, want := range cases {
+		var appErr *utils.Error
+		if _, err := readBackupArchive(strings.NewReader(input)); !errors.As(err, &appErr) || appErr.Code != want.Code {
+			t.Errorf("readBackupArchive(%s) error = %v, want %v", input, err, want)
+		}
+	}
+}
+
+func TestBackupCellValue(t *testing.T) {
+	row, err := json.Marshal(backupRow([]interface{}{int64(-1250), "午饭", nil, 1.5, []byte{0, 1, 2}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(row))
+	decoder.UseNumber()
+	var cells []interface{}
+	if err := decoder.Decode(&cells); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []interface{}
+	for _, cell := range cells {
+		v, err := backupCellValue(cell)
+		if err != nil {
+			t.Fatalf("backupCellValue(%v) returned error: %v", cell, err)
+		}
+		got = append(got, v)
+	}
+	if got[0] != int64(-1250) || got[1] != "午饭" || got[2] != nil || got[3] != 1.5 || !bytes.Equal(got[4].([]byte), []byte{0, 1, 2}) {
+		t.Errorf("unexpected values: %#v", got)
+	}
+	if _, err := backupCellValue(true); !errors.Is(err, utils.ErrBackupInvalid) {
+		t.Errorf("expected ErrBackupInvalid for a boolean cell, got %v", err)
+	}
+}
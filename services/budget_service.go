@@ -0,0 +1,249 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// 预算服务
+type BudgetService struct {
+	masterDB *sql.DB
+}
+
+// 新建预算服务的方法
+func NewBudgetService(masterDB *sql.DB) *BudgetService {
+	return &BudgetService{masterDB: masterDB}
+}
+
+// 允许的预算周期
+var budgetPeriods = map[string]bool{
+	"week":  true, // 周一至周日
+	"month": true,
+	"year":  true,
+}
+
+// 新建预算服务（categoryID 为 0 表示总体预算；amount 为元；startDate 为空表示从当前周期开始）
+func (s *BudgetService) CreateBudget(userID int64, categoryID int64, period string, amount string, rollover bool, startDate string) (int64, error) {
+	if !budgetPeriods[period] {
+		return 0, utils.ErrInvalidBudgetPeriod
+	}
+	cents, err := parseBudgetAmount(amount)
+	if err != nil {
+		return 0, err
+	}
+	start, err := parseBudgetStart(period, startDate)
+	if err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+
+	if categoryID != 0 {
+		category, err := database.GetCategoryByID(userDB, categoryID)
+		if err != nil {
+			return 0, err
+		}
+		if category == nil {
+			return 0, utils.ErrCategoryNotFound
+		}
+	}
+	return database.CreateBudget(userDB, &models.Budget{
+		CategoryID: categoryID,
+		Period:     period,
+		Amount:     cents,
+		Rollover:   rollover,
+		StartDate:  start,
+	})
+}
+
+// 获取预算列表服务（period 为空表示全部）
+func (s *BudgetService) GetBudgets(userID int64, period string) ([]models.Budget, error) {
+	if period != "" && !budgetPeriods[period] {
+		return nil, utils.ErrInvalidBudgetPeriod
+	}
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetBudgets(userDB, period)
+}
+
+// 更新预算服务（nil 表示不更新；类别与周期不可修改，需删除后重建）
+func (s *BudgetService) UpdateBudget(userID int64, budgetID int64, amount *string, rollover *bool, startDate *string) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	budget, err := database.GetBudgetByID(userDB, budgetID)
+	if err != nil {
+		return err
+	}
+	var centsPtr *int64
+	if amount != nil {
+		cents, err := parseBudgetAmount(*amount)
+		if err != nil {
+			return err
+		}
+		centsPtr = &cents
+	}
+	var startPtr *string
+	if startDate != nil {
+		start, err := parseBudgetStart(budget.Period, *startDate)
+		if err != nil {
+			return err
+		}
+		startPtr = &start
+	}
+	return database.UpdateBudget(userDB, budgetID, centsPtr, rollover, startPtr)
+}
+
+// 删除预算服务
+func (s *BudgetService) DeleteBudget(userID int64, budgetID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeleteBudget(userDB, budgetID)
+}
+
+// 预算执行情况服务：date 所在周期（空表示当前）的额度、支出与剩余；period 非空时只看该周期的预算
+func (s *BudgetService) GetBudgetStatus(userID int64, date string, period string) ([]models.BudgetStatus, error) {
+	at := time.Now()
+	if date != "" {
+		t, _, err := utils.ParseDateTime(date)
+		if err != nil {
+			return nil, err
+		}
+		at = t
+	}
+
+	budgets, err := s.GetBudgets(userID, period)
+	if err != nil {
+		return nil, err
+	}
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	statuses := make([]models.BudgetStatus, 0, len(budgets))
+	for i := range budgets {
+		status, err := budgetStatus(userDB, &budgets[i], at)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// budgetStatus 计算预算在 at 所在周期的执行情况。
+// 开启结转时，从预算生效的周期起逐个周期累计：本周期结余 = 额度 + 上期结转 - 支出，只有结余为正才结转。
+func budgetStatus(userDB *sql.DB, b *models.Budget, at time.Time) (*models.BudgetStatus, error) {
+	current := periodStart(b.Period, at)
+	end := nextPeriodStart(b.Period, current)
+
+	from := current
+	if b.Rollover {
+		if start, err := time.ParseInLocation(utils.DateTimeLayout, b.StartDate, time.Local); err == nil && start.Before(current) {
+			from = periodStart(b.Period, start)
+		}
+	}
+	spending, err := database.GetBudgetSpending(userDB, b.Period, b.CategoryID,
+		utils.FormatDateTime(from), utils.FormatDateTime(end))
+	if err != nil {
+		return nil, err
+	}
+
+	var carryover int64
+	for p := from; p.Before(current); p = nextPeriodStart(b.Period, p) {
+		carryover = b.Amount + carryover - spending[utils.FormatDateTime(p)]
+		if carryover < 0 {
+			carryover = 0
+		}
+	}
+
+	status := &models.BudgetStatus{
+		BudgetID:     b.ID,
+		CategoryID:   b.CategoryID,
+		CategoryName: b.CategoryName,
+		Period:       b.Period,
+		PeriodStart:  utils.FormatDateTime(current),
+		PeriodEnd:    utils.FormatDateTime(end),
+		Limit:        b.Amount,
+		Carryover:    carryover,
+		Available:    b.Amount + carryover,
+		Spent:        spending[utils.FormatDateTime(current)],
+	}
+	status.Remaining = status.Available - status.Spent
+	status.Overspent = status.Remaining < 0
+	if status.Available > 0 {
+		status.Percent = math.Round(float64(status.Spent)*10000/float64(status.Available)) / 100
+	}
+	status.SpentStr = utils.CentsToYuanString(status.Spent)
+	status.RemainingStr = utils.CentsToYuanString(status.Remaining)
+	return status, nil
+}
+
+// periodStart 返回 t 所在周期的开始时间（周从周一开始）
+func periodStart(period string, t time.Time) time.Time {
+	switch period {
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextPeriodStart 返回下一个周期的开始时间（start 须为周期开始时间）
+func nextPeriodStart(period string, start time.Time) time.Time {
+	switch period {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "year":
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+// parseBudgetAmount 解析预算额度（元），必须大于 0
+func parseBudgetAmount(amount string) (int64, error) {
+	cents, err := utils.ParseToCents(amount)
+	if err != nil {
+		return 0, err
+	}
+	if cents == 0 {
+		return 0, utils.ErrAmountZero
+	}
+	return cents, nil
+}
+
+// parseBudgetStart 返回预算生效周期的开始时间，空表示当前周期
+func parseBudgetStart(period string, startDate string) (string, error) {
+	start := time.Now()
+	if startDate != "" {
+		t, _, err := utils.ParseDateTime(startDate)
+		if err != nil {
+			return "", err
+		}
+		start = t
+	}
+	return utils.FormatDateTime(periodStart(period, start)), nil
+}
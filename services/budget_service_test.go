@@ -0,0 +1,40 @@
+package services
+
+import (
+	"AccountingAssistant/utils"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	tests := []struct {
+		name      string
+		period    string
+		input     string
+		expected  string
+		nextStart string
+	}{
+		{"月", "month", "2025-03-15 10:00:00", "2025-03-01 00:00:00", "2025-04-01 00:00:00"},
+		{"年末月", "month", "2025-12-31 23:59:59", "2025-12-01 00:00:00", "2026-01-01 00:00:00"},
+		{"周三所在周", "week", "2025-01-01 12:00:00", "2024-12-30 00:00:00", "2025-01-06 00:00:00"},
+		{"周一", "week", "2025-01-06 00:00:00", "2025-01-06 00:00:00", "2025-01-13 00:00:00"},
+		{"周日属于前一个周一", "week", "2025-01-12 23:00:00", "2025-01-06 00:00:00", "2025-01-13 00:00:00"},
+		{"年", "year", "2024-02-29 08:00:00", "2024-01-01 00:00:00", "2025-01-01 00:00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := time.ParseInLocation(utils.DateTimeLayout, tt.input, time.Local)
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := periodStart(tt.period, input)
+			if got := utils.FormatDateTime(start); got != tt.expected {
+				t.Errorf("periodStart(%s, %q) = %q, want %q", tt.period, tt.input, got, tt.expected)
+			}
+			if got := utils.FormatDateTime(nextPeriodStart(tt.period, start)); got != tt.nextStart {
+				t.Errorf("nextPeriodStart(%s, %q) = %q, want %q", tt.period, tt.expected, got, tt.nextStart)
+			}
+		})
+	}
+}
@@ -0,0 +1,529 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// 自动分类规则服务
+// 记账（POST /transaction）或导入时没有给出类别的收支账单，按 priority、id 顺序检查启用中的规则，
+// 第一条所有条件都满足、且类别的收支类型与账单一致的规则决定账单的类别，并给账单加上规则的标签。
+// 也可以对某个时间范围内的已有账单重新运行规则，先预览哪些账单会改变。
+type CategoryRuleService struct {
+	masterDB *sql.DB
+}
+
+// 新建自动分类规则服务的方法
+func NewCategoryRuleService(masterDB *sql.DB) *CategoryRuleService {
+	return &CategoryRuleService{masterDB: masterDB}
+}
+
+// CategoryRuleInput "新建自动分类规则"的输入（金额为元，空表示不限）
+type CategoryRuleInput struct {
+	Name         string
+	Priority     int
+	CategoryID   int64
+	Type         string // income / expense，空表示不限
+	NoteContains string
+	NoteRegex    string
+	MinAmount    string
+	MaxAmount    string
+	AccountID    int64
+	PayeeID      int64
+	Tags         []string // 匹配后给账单加上的标签，不存在时自动创建
+	Active       *bool    // nil 表示启用
+}
+
+// UpdateCategoryRuleInput "修改自动分类规则"的输入，nil 表示不更新；字符串条件传空表示取消该条件
+type UpdateCategoryRuleInput struct {
+	Name         *string
+	Priority     *int
+	CategoryID   *int64
+	Type         *string
+	NoteContains *string
+	NoteRegex    *string
+	MinAmount    *string
+	MaxAmount    *string
+	AccountID    *int64 // 0 表示不限
+	PayeeID      *int64 // 0 表示不限
+	Tags         *[]string
+	Active       *bool
+}
+
+// ApplyCategoryRulesInput 对已有账单重新运行规则的范围
+type ApplyCategoryRulesInput struct {
+	StartDate string // 发生时间起点（包含），空表示不限
+	EndDate   string // 发生时间终点（包含），空表示不限
+	Overwrite bool   // 为 false 时只处理未分类的账单
+	DryRun    bool   // 只预览，不修改
+}
+
+// 新建自动分类规则服务
+func (s *CategoryRuleService) CreateRule(userID int64, input CategoryRuleInput) (int64, error) {
+	rule := models.CategoryRule{
+		Name:         strings.TrimSpace(input.Name),
+		Priority:     input.Priority,
+		CategoryID:   input.CategoryID,
+		Type:         input.Type,
+		NoteContains: strings.TrimSpace(input.NoteContains),
+		NoteRegex:    input.NoteRegex,
+		AccountID:    input.AccountID,
+		PayeeID:      input.PayeeID,
+		Active:       input.Active == nil || *input.Active,
+	}
+	var err error
+	if rule.MinAmount, err = parseRuleAmount(input.MinAmount); err != nil {
+		return 0, err
+	}
+	if rule.MaxAmount, err = parseRuleAmount(input.MaxAmount); err != nil {
+		return 0, err
+	}
+	if rule.Tags, err = parseTagNames(input.Tags); err != nil {
+		return 0, err
+	}
+	if err := validateCategoryRule(rule); err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+
+	if err := checkCategoryRuleRefs(userDB, rule); err != nil {
+		return 0, err
+	}
+	tx, err := userDB.Begin()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	ruleID, err := database.CreateCategoryRule(tx, &rule)
+	if err != nil {
+		return 0, err
+	}
+	if err := saveCategoryRuleTags(tx, ruleID, rule.Tags); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return ruleID, nil
+}
+
+// 获取全部自动分类规则（按匹配顺序）
+func (s *CategoryRuleService) GetRules(userID int64) ([]models.CategoryRule, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetCategoryRules(userDB, false)
+}
+
+// 获取单条自动分类规则
+func (s *CategoryRuleService) GetRule(userID int64, ruleID int64) (*models.CategoryRule, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetCategoryRuleByID(userDB, ruleID)
+}
+
+// 修改自动分类规则服务
+func (s *CategoryRuleService) UpdateRule(userID int64, ruleID int64, input UpdateCategoryRuleInput) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	rule, err := database.GetCategoryRuleByID(userDB, ruleID)
+	if err != nil {
+		return err
+	}
+	if input.Name != nil {
+		rule.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Priority != nil {
+		rule.Priority = *input.Priority
+	}
+	if input.CategoryID != nil {
+		rule.CategoryID = *input.CategoryID
+	}
+	if input.Type != nil {
+		rule.Type = *input.Type
+	}
+	if input.NoteContains != nil {
+		rule.NoteContains = strings.TrimSpace(*input.NoteContains)
+	}
+	if input.NoteRegex != nil {
+		rule.NoteRegex = *input.NoteRegex
+	}
+	if input.MinAmount != nil {
+		if rule.MinAmount, err = parseRuleAmount(*input.MinAmount); err != nil {
+			return err
+		}
+	}
+	if input.MaxAmount != nil {
+		if rule.MaxAmount, err = parseRuleAmount(*input.MaxAmount); err != nil {
+			return err
+		}
+	}
+	if input.AccountID != nil {
+		rule.AccountID = *input.AccountID
+	}
+	if input.PayeeID != nil {
+		rule.PayeeID = *input.PayeeID
+	}
+	if input.Active != nil {
+		rule.Active = *input.Active
+	}
+	if input.Tags != nil {
+		if rule.Tags, err = parseTagNames(*input.Tags); err != nil {
+			return err
+		}
+	}
+	if err := validateCategoryRule(*rule); err != nil {
+		return err
+	}
+	if err := checkCategoryRuleRefs(userDB, *rule); err != nil {
+		return err
+	}
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	if err := database.UpdateCategoryRule(tx, rule); err != nil {
+		return err
+	}
+	if input.Tags != nil {
+		if err := saveCategoryRuleTags(tx, ruleID, rule.Tags); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// saveCategoryRuleTags 把规则的标签替换为 names（不存在的标签自动创建）
+func saveCategoryRuleTags(db database.DBTX, ruleID int64, names []string) error {
+	tagIDs, err := resolveTagIDs(db, names)
+	if err != nil {
+		return err
+	}
+	return database.SetCategoryRuleTags(db, ruleID, tagIDs)
+}
+
+// 删除自动分类规则服务
+func (s *CategoryRuleService) DeleteRule(userID int64, ruleID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeleteCategoryRule(userDB, ruleID)
+}
+
+// ApplyRules 对时间范围内的已有收支账单重新运行启用中的规则，返回类别或标签会改变（或已改变）的账单。
+// 没有规则匹配的账单保持不变；规则的标签只会添加，不会去掉账单已有的标签。
+func (s *CategoryRuleService) ApplyRules(actor Actor, input ApplyCategoryRulesInput) (*models.CategoryRuleApplyResult, error) {
+	filter, err := buildTransactionFilter(TransactionQuery{StartDate: input.StartDate, EndDate: input.EndDate})
+	if err != nil {
+		return nil, err
+	}
+	filter.SortOrder = "asc"
+	if !input.Overwrite {
+		uncategorized := int64(0)
+		filter.CategoryID = &uncategorized
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	rules, err := database.GetCategoryRules(userDB, true)
+	if err != nil {
+		return nil, err
+	}
+	matchers := compileCategoryRules(rules)
+	tags, err := database.GetTags(userDB)
+	if err != nil {
+		return nil, err
+	}
+	tagIDs := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		tagIDs[tag.Name] = tag.ID
+	}
+	// 拆分账单的类别在各拆分行上，规则不修改（未分类的筛选条件已排除拆分账单）
+	var splits map[int64][]models.TransactionSplit
+	if input.Overwrite {
+		if splits, err = database.GetTransactionSplits(userDB, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &models.CategoryRuleApplyResult{DryRun: input.DryRun, Changes: []models.CategoryRuleChange{}}
+	updates := make(map[int64]int64)
+	tagUpdates := make(map[int64][]int64)
+	err = database.EachTransaction(userDB, filter, func(t models.DisplayTransaction, cents int64) error {
+		if t.Type != "income" && t.Type != "expense" || len(splits[t.ID]) > 0 {
+			return nil
+		}
+		result.Checked++
+		rule := matchCategoryRule(matchers, categoryRuleSubject{
+			Type: t.Type, Cents: abs(cents), Note: t.Note, AccountID: t.AccountID, PayeeID: t.PayeeID,
+		})
+		if rule == nil {
+			return nil
+		}
+		addedTags := missingTags(t.Tags, rule.Tags)
+		if rule.CategoryID == t.CategoryID && len(addedTags) == 0 {
+			return nil
+		}
+		updates[t.ID] = rule.CategoryID
+		for _, name := range addedTags {
+			tagUpdates[t.ID] = append(tagUpdates[t.ID], tagIDs[name])
+		}
+		result.Changes = append(result.Changes, models.CategoryRuleChange{
+			TransactionID:   t.ID,
+			OccurredAt:      t.OccurredAt,
+			Type:            t.Type,
+			Amount:          t.Amount,
+			Note:            t.Note,
+			OldCategoryID:   t.CategoryID,
+			OldCategoryName: t.CategoryName,
+			NewCategoryID:   rule.CategoryID,
+			NewCategoryName: rule.CategoryName,
+			AddedTags:       addedTags,
+			RuleID:          rule.ID,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = len(result.Changes)
+
+	if !input.DryRun && len(updates) > 0 {
+		if err := applyRuleUpdates(userDB, actor, updates, tagUpdates); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// applyRuleUpdates 在一个事务中写入规则给出的类别与标签，并为每笔账单记录修改历史
+func applyRuleUpdates(userDB *sql.DB, actor Actor, categories map[int64]int64, tags map[int64][]int64) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	baseline := &historyBaseline{transactions: make(map[int64]*models.TransactionSnapshot, len(categories))}
+	for id := range categories {
+		if baseline.transactions[id], _, err = database.GetTransactionSnapshot(tx, id); err != nil {
+			return err
+		}
+	}
+	if err := database.SetTransactionCategories(tx, categories, tags); err != nil {
+		return err
+	}
+	if err := baseline.recordUpdates(tx, actor); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// categoryRuleSubject 规则匹配时使用的账单信息（金额为分，绝对值）
+type categoryRuleSubject struct {
+	Type      string
+	Cents     int64
+	Note      string
+	AccountID int64
+	PayeeID   int64
+}
+
+// categoryRuleMatcher 预编译了正则表达式的规则
+type categoryRuleMatcher struct {
+	rule  models.CategoryRule
+	lower string // 小写的 NoteContains
+	re    *regexp.Regexp
+}
+
+// compileCategoryRules 按原顺序编译规则；正则表达式无效的规则被跳过（保存时已校验）
+func compileCategoryRules(rules []models.CategoryRule) []categoryRuleMatcher {
+	matchers := make([]categoryRuleMatcher, 0, len(rules))
+	for _, r := range rules {
+		m := categoryRuleMatcher{rule: r, lower: strings.ToLower(r.NoteContains)}
+		if r.NoteRegex != "" {
+			re, err := regexp.Compile(r.NoteRegex)
+			if err != nil {
+				continue
+			}
+			m.re = re
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers
+}
+
+// matches 规则的所有条件是否都满足
+func (m categoryRuleMatcher) matches(subject categoryRuleSubject) bool {
+	r := m.rule
+	if !r.Active || r.CategoryID == 0 {
+		return false
+	}
+	if r.Type != "" && r.Type != subject.Type {
+		return false
+	}
+	// 类别的收支类型与账单不一致时规则不适用，继续尝试后面的规则
+	if r.CategoryKind != "" && !categoryKindAllows(r.CategoryKind, subject.Type) {
+		return false
+	}
+	if m.lower != "" && !strings.Contains(strings.ToLower(subject.Note), m.lower) {
+		return false
+	}
+	if m.re != nil && !m.re.MatchString(subject.Note) {
+		return false
+	}
+	if r.MinAmount != 0 && subject.Cents < r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != 0 && subject.Cents > r.MaxAmount {
+		return false
+	}
+	if r.AccountID != 0 && r.AccountID != subject.AccountID {
+		return false
+	}
+	if r.PayeeID != 0 && r.PayeeID != subject.PayeeID {
+		return false
+	}
+	return true
+}
+
+// matchCategoryRule 返回第一条匹配的规则，没有时返回 nil
+func matchCategoryRule(matchers []categoryRuleMatcher, subject categoryRuleSubject) *models.CategoryRule {
+	for i := range matchers {
+		if matchers[i].matches(subject) {
+			return &matchers[i].rule
+		}
+	}
+	return nil
+}
+
+// applyCategoryRules 没有给出类别的收支账单按规则设置 CategoryID 并追加规则的标签，返回匹配的规则（没有时为 nil）。
+// matchers 为 nil 时从数据库读取启用中的规则（批量记账时由调用方预先读取一次）
+func applyCategoryRules(db database.DBTX, input *RecordTransactionInput, matchers []categoryRuleMatcher) (*models.CategoryRule, error) {
+	if input.Category != "" || input.CategoryID != 0 || len(input.Splits) > 0 || (input.Type != "income" && input.Type != "expense") {
+		return nil, nil
+	}
+	if matchers == nil {
+		rules, err := database.GetCategoryRules(db, true)
+		if err != nil {
+			return nil, err
+		}
+		matchers = compileCategoryRules(rules)
+	}
+	cents, err := utils.ParseToCents(input.Amount)
+	if err != nil {
+		return nil, nil // 金额由记账时校验
+	}
+	rule := matchCategoryRule(matchers, categoryRuleSubject{
+		Type: input.Type, Cents: abs(cents), Note: input.Note, AccountID: input.AccountID, PayeeID: input.PayeeID,
+	})
+	if rule != nil {
+		input.CategoryID = rule.CategoryID
+		input.Tags = append(input.Tags, rule.Tags...)
+	}
+	return rule, nil
+}
+
+// missingTags 返回 ruleTags 中账单还没有的标签（按名称比较，忽略大小写）
+func missingTags(existing []string, ruleTags []string) []string {
+	have := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		have[strings.ToLower(name)] = true
+	}
+	missing := []string{}
+	for _, name := range ruleTags {
+		if !have[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// parseRuleAmount 解析规则的金额条件（元，取绝对值），空表示不限
+func parseRuleAmount(str string) (int64, error) {
+	if strings.TrimSpace(str) == "" {
+		return 0, nil
+	}
+	return utils.ParseToCents(str)
+}
+
+// validateCategoryRule 校验规则的字段：类型有效、正则表达式可编译、金额上下限有序，并且至少有一个条件
+func validateCategoryRule(r models.CategoryRule) error {
+	if r.CategoryID == 0 {
+		return utils.ErrInvalidParameter
+	}
+	if r.Type != "" && r.Type != "income" && r.Type != "expense" {
+		return utils.ErrInvalidTransactionType
+	}
+	if r.NoteRegex != "" {
+		if _, err := regexp.Compile(r.NoteRegex); err != nil {
+			return utils.WrapError(utils.ErrInvalidCategoryRule, err)
+		}
+	}
+	if r.MinAmount != 0 && r.MaxAmount != 0 && r.MinAmount > r.MaxAmount {
+		return utils.ErrInvalidCategoryRule
+	}
+	if r.NoteContains == "" && r.NoteRegex == "" && r.MinAmount == 0 && r.MaxAmount == 0 && r.AccountID == 0 && r.PayeeID == 0 {
+		// 只限定类型的规则会把该类型的账单全部归入一个类别，不允许
+		return utils.ErrInvalidCategoryRule
+	}
+	return nil
+}
+
+// checkCategoryRuleRefs 校验规则引用的类别、账户与商户存在，且类别的收支类型与规则限定的类型一致
+func checkCategoryRuleRefs(db database.DBTX, r models.CategoryRule) error {
+	category, err := database.GetCategoryByID(db, r.CategoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return utils.ErrCategoryNotFound
+	}
+	if r.Type != "" && !categoryKindAllows(category.Kind, r.Type) {
+		return utils.ErrCategoryKindMismatch
+	}
+	if r.AccountID != 0 {
+		if _, err := database.GetAccountByID(db, r.AccountID); err != nil {
+			return err
+		}
+	}
+	if r.PayeeID != 0 {
+		if _, err := database.GetPayeeByID(db, r.PayeeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
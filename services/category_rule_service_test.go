@@ -0,0 +1,86 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"testing"
+)
+
+func TestMatchCategoryRule(t *testing.T) {
+	rules := []models.CategoryRule{
+		{ID: 1, Priority: 0, CategoryID: 10, CategoryKind: "expense", NoteContains: "Starbucks", Active: true},
+		{ID: 2, Priority: 0, CategoryID: 20, CategoryKind: "expense", NoteRegex: `^滴滴|打车`, MaxAmount: 10000, Active: true},
+		{ID: 3, Priority: 0, CategoryID: 30, CategoryKind: "both", MinAmount: 100000, AccountID: 7, Active: true},
+		{ID: 4, Priority: 0, CategoryID: 40, CategoryKind: "income", Type: "income", NoteContains: "工资", Active: true},
+		{ID: 5, Priority: 0, CategoryID: 50, CategoryKind: "expense", NoteContains: "超市", Active: false},
+		// 收入类别的规则不会用于支出账单，继续尝试后面的规则
+		{ID: 6, Priority: 0, CategoryID: 60, CategoryKind: "income", NoteContains: "退款", Active: true},
+		{ID: 7, Priority: 0, CategoryID: 70, CategoryKind: "expense", NoteContains: "退款", Active: true},
+		{ID: 8, Priority: 0, CategoryID: 80, NoteRegex: `(`, Active: true}, // 无效正则被跳过
+		{ID: 9, Priority: 0, CategoryID: 90, CategoryKind: "expense", PayeeID: 5, Active: true},
+	}
+	matchers := compileCategoryRules(rules)
+
+	tests := []struct {
+		name     string
+		subject  categoryRuleSubject
+		expected int64 // 匹配规则的 id，0 表示没有匹配
+	}{
+		{"备注包含不区分大小写", categoryRuleSubject{Type: "expense", Cents: 3000, Note: "STARBUCKS 拿铁"}, 1},
+		{"正则与金额上限", categoryRuleSubject{Type: "expense", Cents: 2500, Note: "滴滴快车"}, 2},
+		{"超过金额上限", categoryRuleSubject{Type: "expense", Cents: 20000, Note: "滴滴快车"}, 0},
+		{"金额下限与账户", categoryRuleSubject{Type: "expense", Cents: 200000, Note: "家电", AccountID: 7}, 3},
+		{"账户不符", categoryRuleSubject{Type: "expense", Cents: 200000, Note: "家电", AccountID: 8}, 0},
+		{"限定类型", categoryRuleSubject{Type: "income", Cents: 1000000, Note: "十月工资"}, 4},
+		{"类型不符", categoryRuleSubject{Type: "expense", Cents: 100, Note: "工资卡年费"}, 0},
+		{"停用的规则", categoryRuleSubject{Type: "expense", Cents: 100, Note: "超市"}, 0},
+		{"收支类型不符的类别", categoryRuleSubject{Type: "expense", Cents: 100, Note: "退款"}, 7},
+		{"收入匹配收入类别", categoryRuleSubject{Type: "income", Cents: 100, Note: "退款"}, 6},
+		{"商户", categoryRuleSubject{Type: "expense", Cents: 100, Note: "午饭", PayeeID: 5}, 9},
+		{"商户不符", categoryRuleSubject{Type: "expense", Cents: 100, Note: "午饭", PayeeID: 6}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int64
+			if rule := matchCategoryRule(matchers, tt.subject); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.expected {
+				t.Errorf("matchCategoryRule(%+v) = rule %d, want %d", tt.subject, got, tt.expected)
+			}
+		})
+	}
+
+	// 按顺序取第一条匹配的规则
+	ordered := compileCategoryRules([]models.CategoryRule{
+		{ID: 2, CategoryID: 20, CategoryKind: "both", NoteContains: "咖啡", Active: true},
+		{ID: 1, CategoryID: 10, CategoryKind: "both", NoteContains: "咖啡", Active: true},
+	})
+	if rule := matchCategoryRule(ordered, categoryRuleSubject{Type: "expense", Cents: 100, Note: "咖啡"}); rule == nil || rule.ID != 2 {
+		t.Errorf("first match = %+v, want rule 2", rule)
+	}
+}
+
+func TestValidateCategoryRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  models.CategoryRule
+		valid bool
+	}{
+		{"备注包含", models.CategoryRule{CategoryID: 1, NoteContains: "星巴克"}, true},
+		{"金额范围", models.CategoryRule{CategoryID: 1, Type: "expense", MinAmount: 100, MaxAmount: 200}, true},
+		{"只有账户", models.CategoryRule{CategoryID: 1, AccountID: 3}, true},
+		{"只有商户", models.CategoryRule{CategoryID: 1, PayeeID: 2}, true},
+		{"没有条件", models.CategoryRule{CategoryID: 1, Type: "expense"}, false},
+		{"没有类别", models.CategoryRule{NoteContains: "a"}, false},
+		{"无效类型", models.CategoryRule{CategoryID: 1, Type: "transfer", NoteContains: "a"}, false},
+		{"无效正则", models.CategoryRule{CategoryID: 1, NoteRegex: "(("}, false},
+		{"上下限颠倒", models.CategoryRule{CategoryID: 1, MinAmount: 300, MaxAmount: 200}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCategoryRule(tt.rule); (err == nil) != tt.valid {
+				t.Errorf("validateCategoryRule(%+v) = %v, want valid %v", tt.rule, err, tt.valid)
+			}
+		})
+	}
+}
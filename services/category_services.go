@@ -3,10 +3,19 @@ package services
 import (
 	"AccountingAssistant/database"
 	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
 	"database/sql"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
 // 类别服务
+// 类别可以有上级类别（如 "餐饮" 下的 "早餐"、"外卖"），构成一棵或多棵树；
+// 修改上级时不允许移到自身或自己的子孙类别下。删除类别时其子类别仍挂在它之下（在回收站期间按顶级类别显示），
+// 彻底删除时子类别移到被删除类别的上级，合并时移到目标类别下。
+// 每个类别有收支类型 kind：income / expense 只能用于对应类型的账单，both 收支均可。
+// 类别名称忽略大小写与多余空白后不能重复；重复的类别可以合并，删除类别时也可以把账单转到另一个类别。
 type CategoryService struct {
 	masterDB *sql.DB
 }
@@ -16,42 +25,452 @@ func NewCategoryService(masterDB *sql.DB) *CategoryService {
 	return &CategoryService{masterDB: masterDB}
 }
 
+// CategoryInput "新建类别"的输入
+type CategoryInput struct {
+	Name     string
+	ParentID int64  // 0 表示顶级类别
+	Kind     string // income / expense / both，空表示 both
+	Icon     string
+	Color    string // "#RRGGBB"，可为空
+}
+
+// UpdateCategoryInput "更新类别"的输入，nil 表示不更新该字段
+type UpdateCategoryInput struct {
+	Name     string
+	ParentID *int64 // 0 表示移为顶级类别
+	Kind     *string
+	Icon     *string
+	Color    *string
+}
+
+// 类别颜色只接受 "#RRGGBB"
+var categoryColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+const maxCategoryIconLength = 32 // 图标名称或 emoji 的最大字符数
+
 // 新建类别服务
-func (s *CategoryService) CreateCategory(userID int64, name string) (int64, error) {
-	userDB, err := database.GetUserDB(userID)
+func (s *CategoryService) CreateCategory(actor Actor, input CategoryInput) (int64, error) {
+	category := models.Category{Name: cleanCategoryName(input.Name), ParentID: input.ParentID, Kind: input.Kind, Icon: input.Icon, Color: input.Color}
+	if category.Kind == "" {
+		category.Kind = "both"
+	}
+	if err := validateCategory(category); err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
 	if err != nil {
 		return 0, err
 	}
 	defer userDB.Close()
-	return database.CreateCategory(userDB, name)
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	if category.ParentID != 0 {
+		parent, err := database.GetCategoryByID(tx, category.ParentID)
+		if err != nil {
+			return 0, err
+		}
+		if parent == nil {
+			return 0, utils.ErrCategoryNotFound
+		}
+	}
+	categoryID, err := database.CreateCategory(tx, category)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordCategoryHistory(tx, actor, historyActionCreate, categoryID, nil); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return categoryID, nil
 }
 
-// 删除类别服务
-func (s *CategoryService) DeleteCategory(userID int64, categoryID int64) error {
-	userDB, err := database.GetUserDB(userID)
+// 删除类别服务：reassignTo 为 0 时类别移到回收站（其账单在恢复前按未分类处理，子类别仍挂在它之下，在回收站期间按顶级类别显示）；
+// 否则相当于把该类别合并到 reassignTo 类别（账单、周期规则、预算与子类别都转过去，来源类别直接删除）
+func (s *CategoryService) DeleteCategory(actor Actor, categoryID int64, reassignTo int64) error {
+	if reassignTo != 0 {
+		_, err := s.MergeCategories(actor, []int64{categoryID}, reassignTo)
+		return err
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	category, err := database.GetCategoryByID(tx, categoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return utils.ErrCategoryNotFound
+	}
+	old, err := database.GetCategorySnapshot(tx, categoryID)
 	if err != nil {
 		return err
 	}
+	if err := database.DeleteCategory(tx, categoryID); err != nil {
+		return err
+	}
+	if err := recordCategoryHistory(tx, actor, historyActionDelete, categoryID, old); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// 合并类别服务：把 sourceIDs 类别的账单、周期规则、预算与子类别转到 targetID 类别并删除来源类别。
+// 来源类别的收支类型与目标不同时，目标类别改为 both，保证转过来的账单仍然有效。
+func (s *CategoryService) MergeCategories(actor Actor, sourceIDs []int64, targetID int64) (*models.CategoryMergeResult, error) {
+	if len(sourceIDs) == 0 || targetID == 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
 	defer userDB.Close()
-	return database.DeleteCategory(userDB, categoryID)
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	categories, err := database.GetCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	target, ok := byID[targetID]
+	if !ok {
+		return nil, utils.ErrCategoryNotFound
+	}
+	parents := categoryParents(categories)
+	kind := target.Kind
+	seen := make(map[int64]bool)
+	var sources []int64
+	for _, id := range sourceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		source, ok := byID[id]
+		if !ok {
+			return nil, utils.ErrCategoryNotFound
+		}
+		if id == targetID {
+			return nil, utils.ErrInvalidParameter
+		}
+		// 合并到自己的子孙类别会使子类别成环
+		if isCategoryDescendant(parents, targetID, id) {
+			return nil, utils.ErrCategoryCycle
+		}
+		if source.Kind != kind {
+			kind = "both"
+		}
+		sources = append(sources, id)
+	}
+
+	baseline, err := captureHistoryBaseline(tx, sources)
+	if err != nil {
+		return nil, err
+	}
+	result, err := database.MergeCategories(tx, sources, targetID, kind)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range sources {
+		if err := recordCategoryHistory(tx, actor, historyActionMerge, id, baseline.categories[id]); err != nil {
+			return nil, err
+		}
+	}
+	if err := baseline.recordUpdates(tx, actor); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return result, nil
 }
 
-// 获取类别服务
-func (s *CategoryService) GetCategory(userID int64) ([]models.Category, error) {
+// 获取类别服务：flat 为 true 时返回平铺的列表，否则返回嵌套的类别树；
+// kind 为 income 或 expense 时只返回可用于该类型账单的类别（含 both），空表示全部
+func (s *CategoryService) GetCategory(userID int64, flat bool, kind string) ([]models.Category, error) {
+	if kind != "" && kind != "income" && kind != "expense" {
+		return nil, utils.ErrInvalidCategoryKind
+	}
+
 	userDB, err := database.GetUserDB(userID)
 	if err != nil {
 		return nil, err
 	}
 	defer userDB.Close()
-	return database.GetCategories(userDB)
+
+	categories, err := database.GetCategories(userDB)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		matched := []models.Category{}
+		for _, c := range categories {
+			if categoryKindAllows(c.Kind, kind) {
+				matched = append(matched, c)
+			}
+		}
+		categories = matched
+	}
+	if flat {
+		return categories, nil
+	}
+	return buildCategoryTree(categories), nil
 }
 
 // 更新类别服务
-func (s *CategoryService) UpdateCategory(userID int64, catecoryID int64, updateName string) error {
-	userDB, err := database.GetUserDB(userID)
+func (s *CategoryService) UpdateCategory(actor Actor, catecoryID int64, input UpdateCategoryInput) error {
+	userDB, err := database.GetUserDB(actor.UserID)
 	if err != nil {
 		return err
 	}
 	defer userDB.Close()
-	return database.UpdateCategory(userDB, catecoryID, updateName)
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	category, err := database.GetCategoryByID(tx, catecoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return utils.ErrCategoryNotFound
+	}
+	parentID := input.ParentID
+	newParent := category.ParentID
+	if parentID != nil && *parentID != category.ParentID {
+		newParent = *parentID
+		if newParent != 0 {
+			categories, err := database.GetCategories(tx)
+			if err != nil {
+				return err
+			}
+			parents := categoryParents(categories)
+			if _, ok := parents[newParent]; !ok {
+				return utils.ErrCategoryNotFound
+			}
+			if isCategoryDescendant(parents, newParent, catecoryID) {
+				return utils.ErrCategoryCycle
+			}
+		}
+	}
+	category.Name = cleanCategoryName(input.Name)
+	category.ParentID = newParent
+	oldKind := category.Kind
+	if input.Kind != nil {
+		category.Kind = *input.Kind
+	}
+	if input.Icon != nil {
+		category.Icon = *input.Icon
+	}
+	if input.Color != nil {
+		category.Color = *input.Color
+	}
+	if err := validateCategory(*category); err != nil {
+		return err
+	}
+	// 收紧收支类型时，已有的账单（含拆分行）必须仍然可用；与合并类别时放宽为 both 是同一个约束
+	if category.Kind != oldKind {
+		types, err := database.GetCategoryTransactionTypes(tx)
+		if err != nil {
+			return err
+		}
+		for _, transactionType := range types[catecoryID] {
+			if !categoryKindAllows(category.Kind, transactionType) {
+				return utils.ErrInvalidCategoryKind
+			}
+		}
+	}
+	old, err := database.GetCategorySnapshot(tx, catecoryID)
+	if err != nil {
+		return err
+	}
+	if err := database.UpdateCategory(tx, *category); err != nil {
+		return err
+	}
+	if err := recordCategoryHistory(tx, actor, historyActionUpdate, catecoryID, old); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// validateCategory 校验类别的名称、收支类型、图标与颜色
+func validateCategory(c models.Category) error {
+	if c.Name == "" {
+		return utils.ErrInvalidParameter
+	}
+	if c.Kind != "income" && c.Kind != "expense" && c.Kind != "both" {
+		return utils.ErrInvalidCategoryKind
+	}
+	if utf8.RuneCountInString(c.Icon) > maxCategoryIconLength {
+		return utils.ErrInvalidParameter
+	}
+	if c.Color != "" && !categoryColorPattern.MatchString(c.Color) {
+		return utils.ErrInvalidParameter
+	}
+	return nil
+}
+
+// cleanCategoryName 去掉名称首尾空白，中间连续的空白合并为一个空格
+func cleanCategoryName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// categoryKindAllows 类别的收支类型是否可用于某一类型（income / expense）的账单
+func categoryKindAllows(kind string, transactionType string) bool {
+	return kind == "both" || kind == transactionType
+}
+
+// checkCategoryKind 校验类别可用于该类型的账单（categoryID 为 0 表示未分类，不校验）
+func checkCategoryKind(db database.DBTX, categoryID int64, transactionType string) error {
+	if categoryID == 0 {
+		return nil
+	}
+	category, err := database.GetCategoryByID(db, categoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return utils.ErrCategoryNotFound
+	}
+	if !categoryKindAllows(category.Kind, transactionType) {
+		return utils.ErrCategoryKindMismatch
+	}
+	return nil
+}
+
+// categoryParents 类别 id 到上级类别 id 的映射（顶级类别为 0）
+func categoryParents(categories []models.Category) map[int64]int64 {
+	parents := make(map[int64]int64, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+	return parents
+}
+
+// isCategoryDescendant 判断 id 是否为 ancestor 本身或其子孙类别。
+// 沿上级链最多走 len(parents) 步，数据中即使存在环也能结束。
+func isCategoryDescendant(parents map[int64]int64, id int64, ancestor int64) bool {
+	for i := 0; i <= len(parents) && id != 0; i++ {
+		if id == ancestor {
+			return true
+		}
+		id = parents[id]
+	}
+	return false
+}
+
+// categoryRoots 返回应作为树根的类别：没有上级、上级不存在（如在回收站中），或上级链成环（成环的类别中 id 最小的作为根）
+func categoryRoots(parents map[int64]int64) map[int64]bool {
+	roots := make(map[int64]bool)
+	for id, parent := range parents {
+		if parent == 0 {
+			roots[id] = true
+			continue
+		}
+		if _, ok := parents[parent]; !ok {
+			roots[id] = true
+		}
+	}
+	for id := range parents {
+		// 从 id 出发走不到任何根，说明 id 在环上或挂在环下；把环上 id 最小的类别作为根
+		seen := make(map[int64]bool)
+		cur := id
+		for !roots[cur] && !seen[cur] {
+			seen[cur] = true
+			cur = parents[cur]
+		}
+		if roots[cur] {
+			continue
+		}
+		minID := cur
+		for next := parents[cur]; next != cur; next = parents[next] {
+			if next < minID {
+				minID = next
+			}
+		}
+		roots[minID] = true
+	}
+	return roots
+}
+
+// categoryPaths 每个类别从顶级类别开始的完整路径，各级名称以 ":" 连接，如 "餐饮:早餐"
+func categoryPaths(categories []models.Category) map[int64]string {
+	parents := categoryParents(categories)
+	roots := categoryRoots(parents)
+	names := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+	paths := make(map[int64]string, len(categories))
+	for _, c := range categories {
+		path := []string{c.Name}
+		for id := c.ID; !roots[id]; {
+			id = parents[id]
+			path = append([]string{names[id]}, path...)
+		}
+		paths[c.ID] = strings.Join(path, ":")
+	}
+	return paths
+}
+
+// buildCategoryTree 把平铺的类别列表组装为嵌套的类别树，同级类别保持原来的顺序
+func buildCategoryTree(categories []models.Category) []models.Category {
+	parents := categoryParents(categories)
+	roots := categoryRoots(parents)
+	children := make(map[int64][]models.Category)
+	var top []models.Category
+	for _, c := range categories {
+		if roots[c.ID] {
+			top = append(top, c)
+		} else {
+			children[c.ParentID] = append(children[c.ParentID], c)
+		}
+	}
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	tree := attach(top)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+	return tree
 }
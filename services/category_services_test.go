@@ -0,0 +1,233 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"strings"
+	"testing"
+)
+
+// 餐饮(1) > 早餐(2)、外卖(3)；交通(4)；外卖(3) > 夜宵(5)
+var testCategories = []models.Category{
+	{ID: 5, Name: "夜宵", ParentID: 3},
+	{ID: 4, Name: "交通"},
+	{ID: 3, Name: "外卖", ParentID: 1},
+	{ID: 2, Name: "早餐", ParentID: 1},
+	{ID: 1, Name: "餐饮"},
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	tree := buildCategoryTree(testCategories)
+	if len(tree) != 2 || tree[0].ID != 4 || tree[1].ID != 1 {
+		t.Fatalf("roots = %+v, want 交通, 餐饮", tree)
+	}
+	food := tree[1]
+	if len(food.Children) != 2 || food.Children[0].ID != 3 || food.Children[1].ID != 2 {
+		t.Fatalf("餐饮 children = %+v, want 外卖, 早餐", food.Children)
+	}
+	if len(food.Children[0].Children) != 1 || food.Children[0].Children[0].ID != 5 {
+		t.Errorf("外卖 children = %+v, want 夜宵", food.Children[0].Children)
+	}
+
+	// 上级不存在或成环的类别不会丢失
+	broken := []models.Category{
+		{ID: 1, Name: "a", ParentID: 2},
+		{ID: 2, Name: "b", ParentID: 1},
+		{ID: 3, Name: "c", ParentID: 99},
+	}
+	tree = buildCategoryTree(broken)
+	count := 0
+	var walk func(nodes []models.Category)
+	walk = func(nodes []models.Category) {
+		for _, n := range nodes {
+			count++
+			walk(n.Children)
+		}
+	}
+	walk(tree)
+	if count != 3 {
+		t.Errorf("broken tree has %d categories, want 3: %+v", count, tree)
+	}
+}
+
+func TestIsCategoryDescendant(t *testing.T) {
+	parents := categoryParents(testCategories)
+	tests := []struct {
+		id, ancestor int64
+		expected     bool
+	}{
+		{5, 1, true},
+		{5, 3, true},
+		{1, 1, true},
+		{2, 3, false},
+		{1, 5, false},
+		{4, 1, false},
+	}
+	for _, tt := range tests {
+		if got := isCategoryDescendant(parents, tt.id, tt.ancestor); got != tt.expected {
+			t.Errorf("isCategoryDescendant(%d, %d) = %v, want %v", tt.id, tt.ancestor, got, tt.expected)
+		}
+	}
+
+	cyclic := map[int64]int64{1: 2, 2: 1}
+	if isCategoryDescendant(cyclic, 1, 3) {
+		t.Error("isCategoryDescendant on a cycle = true, want false")
+	}
+}
+
+func TestCategoryPaths(t *testing.T) {
+	paths := categoryPaths(testCategories)
+	expected := map[int64]string{1: "餐饮", 2: "餐饮:早餐", 3: "餐饮:外卖", 4: "交通", 5: "餐饮:外卖:夜宵"}
+	for id, want := range expected {
+		if paths[id] != want {
+			t.Errorf("path of %d = %q, want %q", id, paths[id], want)
+		}
+	}
+}
+
+func TestBuildCategoryStats(t *testing.T) {
+	amounts := map[int64]models.CategoryStat{
+		0: {OwnCount: 1, OwnAmount: -100},
+		1: {OwnCount: 1, OwnAmount: -500},
+		2: {OwnCount: 2, OwnAmount: -1200},
+		5: {OwnCount: 1, OwnAmount: -3000},
+	}
+
+	stats := buildCategoryStats(testCategories, amounts, 0)
+	// 交通没有账单，不出现；未分类排在最后
+	if len(stats) != 2 || stats[0].CategoryID != 1 || stats[1].CategoryID != 0 {
+		t.Fatalf("stats = %+v, want 餐饮, 未分类", stats)
+	}
+	food := stats[0]
+	if food.Amount != -4700 || food.TransactionCount != 4 || food.OwnAmount != -500 || food.OwnCount != 1 {
+		t.Errorf("餐饮 = %+v, want amount -4700 count 4 own -500/1", food)
+	}
+	if food.AmountStr != "-47.00" {
+		t.Errorf("餐饮 amount_str = %q, want -47.00", food.AmountStr)
+	}
+	// 外卖（含夜宵）金额大于早餐，排在前面
+	if len(food.Children) != 2 || food.Children[0].CategoryID != 3 || food.Children[0].Amount != -3000 {
+		t.Fatalf("餐饮 children = %+v", food.Children)
+	}
+	if len(food.Children[0].Children) != 1 {
+		t.Errorf("外卖 children = %+v, want 夜宵", food.Children[0].Children)
+	}
+
+	// 只看顶级类别：金额不变，不再展开子类别
+	top := buildCategoryStats(testCategories, amounts, 1)
+	if top[0].Amount != -4700 || top[0].Children != nil {
+		t.Errorf("level 1 餐饮 = %+v", top[0])
+	}
+	second := buildCategoryStats(testCategories, amounts, 2)
+	if len(second[0].Children) != 2 || second[0].Children[0].Children != nil {
+		t.Errorf("level 2 餐饮 children = %+v", second[0].Children)
+	}
+}
+
+// 一笔拆分账单（id 9）有行分别在餐饮、外卖、夜宵下，另有一笔普通账单在夜宵下
+func TestBuildCategoryStatsSplitCountedOnce(t *testing.T) {
+	amounts := map[int64]models.CategoryStat{
+		1: {OwnCount: 1, OwnAmount: -100, SplitIDs: []int64{9}},
+		3: {OwnCount: 1, OwnAmount: -200, SplitIDs: []int64{9}},
+		5: {OwnCount: 2, OwnAmount: -800, SplitIDs: []int64{9}},
+	}
+	stats := buildCategoryStats(testCategories, amounts, 0)
+	if len(stats) != 1 || stats[0].CategoryID != 1 {
+		t.Fatalf("stats = %+v, want 餐饮", stats)
+	}
+	food := stats[0]
+	if food.Amount != -1100 || food.TransactionCount != 2 || food.OwnCount != 1 {
+		t.Errorf("餐饮 = %+v, want amount -1100 count 2 own 1", food)
+	}
+	takeout := food.Children[0]
+	if takeout.CategoryID != 3 || takeout.TransactionCount != 2 || takeout.Children[0].TransactionCount != 2 {
+		t.Errorf("外卖 = %+v, want count 2 (夜宵 count 2)", takeout)
+	}
+}
+
+// 合并时账单数按笔计：一笔拆分账单的两行都属于来源类别（且分属两个来源）时只计一次
+func TestMergeCategoriesCountsSplitOnce(t *testing.T) {
+	actor, _ := newTestUser(t)
+	categories := NewCategoryService(nil)
+	var ids []int64
+	for _, name := range []string{"早餐", "午餐", "正餐"} {
+		id, err := categories.CreateCategory(actor, CategoryInput{Name: name, Kind: "expense"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	transactions := NewTransactionService(nil)
+	if _, err := transactions.RecordTransaction(actor, RecordTransactionInput{
+		Type: "expense", Amount: "30",
+		Splits: []SplitInput{{Category: "早餐", Amount: "10"}, {Category: "午餐", Amount: "20"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transactions.RecordTransaction(actor, RecordTransactionInput{Type: "expense", Amount: "5", Category: "早餐"}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := categories.MergeCategories(actor, ids[:2], ids[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Transactions != 2 {
+		t.Errorf("merged transactions = %d, want 2", result.Transactions)
+	}
+}
+
+func TestValidateCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		category models.Category
+		valid    bool
+	}{
+		{"支出", models.Category{Name: "餐饮", Kind: "expense", Icon: "🍜", Color: "#FF7043"}, true},
+		{"收支均可、无颜色", models.Category{Name: "退款", Kind: "both"}, true},
+		{"小写颜色", models.Category{Name: "a", Kind: "income", Color: "#ff00aa"}, true},
+		{"无效类型", models.Category{Name: "a", Kind: "transfer"}, false},
+		{"空类型", models.Category{Name: "a"}, false},
+		{"颜色名", models.Category{Name: "a", Kind: "both", Color: "red"}, false},
+		{"三位颜色", models.Category{Name: "a", Kind: "both", Color: "#f00"}, false},
+		{"图标过长", models.Category{Name: "a", Kind: "both", Icon: strings.Repeat("x", maxCategoryIconLength+1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCategory(tt.category); (err == nil) != tt.valid {
+				t.Errorf("validateCategory(%+v) = %v, want valid %v", tt.category, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCategoryKindAllows(t *testing.T) {
+	tests := []struct {
+		kind, transactionType string
+		expected              bool
+	}{
+		{"expense", "expense", true},
+		{"expense", "income", false},
+		{"income", "income", true},
+		{"income", "expense", false},
+		{"both", "income", true},
+		{"both", "expense", true},
+	}
+	for _, tt := range tests {
+		if got := categoryKindAllows(tt.kind, tt.transactionType); got != tt.expected {
+			t.Errorf("categoryKindAllows(%q, %q) = %v, want %v", tt.kind, tt.transactionType, got, tt.expected)
+		}
+	}
+}
+
+func TestCleanCategoryName(t *testing.T) {
+	tests := map[string]string{
+		" 餐饮 ":          "餐饮",
+		"Food \t Court": "Food Court",
+		"   ":           "",
+		"外卖":            "外卖",
+	}
+	for input, expected := range tests {
+		if got := cleanCategoryName(input); got != expected {
+			t.Errorf("cleanCategoryName(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
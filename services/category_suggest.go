@@ -0,0 +1,239 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 类别建议
+// 根据用户自己的历史账单为正在记录的账单推荐类别，完全在本地的用户数据库上计算：
+// 每笔已分类的历史账单按与输入的相似度（备注词语重合、金额接近、一天中的时间接近）给其类别投票，
+// 得分最高的类别排在最前。备注相同的账单权重远大于只是金额或时间接近的账单。
+
+const (
+	suggestHistoryLimit   = 5000 // 参与计算的最近历史账单数
+	defaultSuggestLimit   = 5
+	maxSuggestLimit       = 20
+	suggestNoteMissBase   = 0.05  // 输入了备注但词语完全不重合的历史账单的权重
+	suggestScorePrecision = 10000 // 得分保留四位小数
+)
+
+// SuggestCategoryInput 类别建议的输入（均可为空）
+type SuggestCategoryInput struct {
+	Note       string
+	Amount     string // 元
+	Type       string // income / expense，空表示不限
+	OccurredAt string // 日期时间，空表示当前时间；只有日期时不比较时间
+	Limit      int
+}
+
+// suggestQuery 解析后的输入
+type suggestQuery struct {
+	tokens  map[string]bool
+	cents   int64 // 0 表示不比较金额
+	minutes int   // 一天中的第几分钟，-1 表示不比较时间
+}
+
+// 类别建议服务：返回按得分排序的候选类别，没有可参考的历史账单时返回空列表
+func (s *CategoryService) SuggestCategories(userID int64, input SuggestCategoryInput) ([]models.CategorySuggestion, error) {
+	if input.Type != "" && input.Type != "income" && input.Type != "expense" {
+		return nil, utils.ErrInvalidTransactionType
+	}
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+	query := suggestQuery{tokens: tokenizeNote(input.Note), minutes: -1}
+	if strings.TrimSpace(input.Amount) != "" {
+		cents, err := utils.ParseToCents(input.Amount)
+		if err != nil {
+			return nil, err
+		}
+		query.cents = abs(cents)
+	}
+	if input.OccurredAt == "" {
+		now := time.Now()
+		query.minutes = now.Hour()*60 + now.Minute()
+	} else {
+		t, dateOnly, err := utils.ParseDateTime(input.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		if !dateOnly {
+			query.minutes = t.Hour()*60 + t.Minute()
+		}
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	history, err := database.GetCategorizedHistory(userDB, input.Type, suggestHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := database.GetCategories(userDB)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.Category, len(categories))
+	for _, c := range categories {
+		if input.Type == "" || categoryKindAllows(c.Kind, input.Type) {
+			byID[c.ID] = c
+		}
+	}
+
+	suggestions := rankCategorySuggestions(query, history)
+	result := []models.CategorySuggestion{}
+	for _, sg := range suggestions {
+		c, ok := byID[sg.CategoryID]
+		if !ok {
+			continue
+		}
+		sg.Name, sg.Icon, sg.Color = c.Name, c.Icon, c.Color
+		result = append(result, sg)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// rankCategorySuggestions 按历史账单与输入的相似度给类别打分，返回按得分从高到低排序的候选（不含名称）。
+// 得分为各类别在总分中的占比；得分相同时历史账单多的类别在前，再按类别 id。
+func rankCategorySuggestions(query suggestQuery, history []models.Transaction) []models.CategorySuggestion {
+	scores := make(map[int64]float64)
+	counts := make(map[int64]int)
+	matches := make(map[int64]int)
+	var total float64
+	for _, h := range history {
+		text := 1.0
+		if len(query.tokens) > 0 {
+			overlap := tokenOverlap(query.tokens, tokenizeNote(h.Note))
+			if overlap > 0 {
+				matches[h.CategoryID]++
+			}
+			text = suggestNoteMissBase + overlap
+		}
+		// 相似度取平方，使只是略微接近的账单贡献明显小于几乎相同的账单，避免账单多的类别总是排在前面
+		amount := amountSimilarity(query.cents, abs(h.Amount))
+		weight := text * (0.2 + 0.8*amount*amount)
+		if query.minutes >= 0 {
+			// 只有日期的账单（如导入的账单）记为 00:00:00，不比较时间
+			if t, _, err := utils.ParseDateTime(h.OccurredAt); err == nil && (t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0) {
+				tod := timeOfDaySimilarity(query.minutes, t.Hour()*60+t.Minute())
+				weight *= 0.5 + 0.5*tod*tod
+			}
+		}
+		scores[h.CategoryID] += weight
+		counts[h.CategoryID]++
+		total += weight
+	}
+
+	suggestions := make([]models.CategorySuggestion, 0, len(scores))
+	for id, score := range scores {
+		suggestions = append(suggestions, models.CategorySuggestion{
+			CategoryID: id,
+			Score:      math.Round(score/total*suggestScorePrecision) / suggestScorePrecision,
+			Matches:    matches[id],
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if scores[a.CategoryID] != scores[b.CategoryID] {
+			return scores[a.CategoryID] > scores[b.CategoryID]
+		}
+		if counts[a.CategoryID] != counts[b.CategoryID] {
+			return counts[a.CategoryID] > counts[b.CategoryID]
+		}
+		return a.CategoryID < b.CategoryID
+	})
+	return suggestions
+}
+
+// tokenizeNote 把备注切分为词语集合：字母数字按连续片段成词（转为小写），
+// 汉字等其他文字按相邻两个字成词（只有一个字时取单字），标点与空白作为分隔
+func tokenizeNote(note string) map[string]bool {
+	tokens := make(map[string]bool)
+	var word []rune // 当前的字母数字片段
+	var han []rune  // 当前的汉字片段
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens[strings.ToLower(string(word))] = true
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens[string(han)] = true
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens[string(han[i:i+2])] = true
+		}
+		han = han[:0]
+	}
+	for _, r := range note {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			flushHan()
+			word = append(word, r)
+		case unicode.IsLetter(r):
+			flushWord()
+			han = append(han, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// tokenOverlap 输入的词语中有多少比例出现在历史备注中（0~1）
+func tokenOverlap(query map[string]bool, other map[string]bool) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+	n := 0
+	for token := range query {
+		if other[token] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(query))
+}
+
+// amountSimilarity 两个金额（分，非负）的接近程度：较小值与较大值之比（0~1）；未输入金额时为 1
+func amountSimilarity(query int64, other int64) float64 {
+	if query == 0 {
+		return 1
+	}
+	if other == 0 {
+		return 0
+	}
+	return float64(min(query, other)) / float64(max(query, other))
+}
+
+// timeOfDaySimilarity 一天中两个时刻（分钟）的接近程度：相差 0 为 1，相差 12 小时为 0（跨零点按较近的方向计算）
+func timeOfDaySimilarity(a int, b int) float64 {
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 12*60 {
+		diff = 24*60 - diff
+	}
+	return 1 - float64(diff)/(12*60)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTokenizeNote(t *testing.T) {
+	tests := map[string][]string{
+		"星巴克 Latte": {"latte", "巴克", "星巴"},
+		"午饭":        {"午饭"},
+		"KFC,早":     {"kfc", "早"},
+		"滴滴-打车 2号线": {"2", "打车", "滴滴", "号线"},
+		"  ":        {},
+	}
+	for note, expected := range tests {
+		tokens := tokenizeNote(note)
+		got := []string{}
+		for token := range tokens {
+			got = append(got, token)
+		}
+		sort.Strings(got)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("tokenizeNote(%q) = %v, want %v", note, got, expected)
+		}
+	}
+}
+
+func TestTimeOfDaySimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     int
+		expected float64
+	}{
+		{8 * 60, 8 * 60, 1},
+		{8 * 60, 20 * 60, 0},
+		{23 * 60, 1 * 60, 1 - 2.0/12}, // 跨零点
+		{12 * 60, 15 * 60, 0.75},
+	}
+	for _, tt := range tests {
+		if got := timeOfDaySimilarity(tt.a, tt.b); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("timeOfDaySimilarity(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestRankCategorySuggestions(t *testing.T) {
+	history := []models.Transaction{
+		{CategoryID: 1, Amount: -3000, Note: "星巴克 拿铁", OccurredAt: "2025-01-02 08:30:00"},
+		{CategoryID: 1, Amount: -3200, Note: "星巴克 美式", OccurredAt: "2025-01-03 09:00:00"},
+		{CategoryID: 2, Amount: -1500, Note: "午饭", OccurredAt: "2025-01-02 12:10:00"},
+		{CategoryID: 2, Amount: -1800, Note: "午饭", OccurredAt: "2025-01-03 12:00:00"},
+		{CategoryID: 2, Amount: -2000, Note: "午饭 加饮料", OccurredAt: "2025-01-04 12:30:00"},
+		{CategoryID: 3, Amount: -3000, Note: "地铁", OccurredAt: "2025-01-04 08:00:00"},
+	}
+
+	// 备注相同的类别胜过账单更多的类别
+	ranked := rankCategorySuggestions(suggestQuery{tokens: tokenizeNote("星巴克"), minutes: -1}, history)
+	if ranked[0].CategoryID != 1 || ranked[0].Matches != 2 {
+		t.Fatalf("note ranking = %+v, want category 1 first with 2 matches", ranked)
+	}
+	var sum float64
+	for _, s := range ranked {
+		sum += s.Score
+	}
+	if math.Abs(sum-1) > 0.001 {
+		t.Errorf("scores sum to %v, want 1", sum)
+	}
+
+	// 没有备注时按金额与时间：30 元、早上 8 点最接近星巴克，午饭的账单虽多但不接近
+	ranked = rankCategorySuggestions(suggestQuery{cents: 3000, minutes: 8 * 60}, history)
+	if ranked[0].CategoryID != 1 {
+		t.Errorf("amount/time ranking = %+v, want category 1 first", ranked)
+	}
+	ranked = rankCategorySuggestions(suggestQuery{cents: 1600, minutes: 12 * 60}, history)
+	if ranked[0].CategoryID != 2 {
+		t.Errorf("lunch ranking = %+v, want category 2 first", ranked)
+	}
+
+	if got := rankCategorySuggestions(suggestQuery{minutes: -1}, nil); len(got) != 0 {
+		t.Errorf("empty history = %+v, want none", got)
+	}
+}
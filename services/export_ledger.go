@@ -0,0 +1,305 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bufio"
+	"database/sql"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 纯文本账本导出（Beancount / Ledger / hledger）
+// 类别写为 Expenses:类别名 / Income:类别名（子类别写为 Expenses:上级类别:子类别），账户写为 Assets:账户名（信用卡为 Liabilities:账户名），
+// 每笔账单写为一条借贷平衡的分录，备注作为摘要，发生时间不是零点时以 time 元数据记录。
+// 转账的两条腿合并为一条分录；期初余额记为与 Equity:Opening-Balances 的分录。
+
+const (
+	ledgerOpenDate          = "1970-01-01"
+	ledgerUnassignedAccount = "Assets:Unassigned"       // 没有指定账户的账单
+	ledgerOpeningAccount    = "Equity:Opening-Balances" // 账户的期初余额
+	ledgerTransferAccount   = "Equity:Transfers"        // 只导出了一条腿的转账
+	ledgerUncategorized     = "其他"                      // 与账单列表中未分类的显示名称一致
+)
+
+// ledgerPostingLine 分录中的一行
+type ledgerPostingLine struct {
+	account  string
+	cents    int64
+	currency string
+	note     string // 写为行尾注释（拆分行的备注）
+}
+
+// ledgerWriter 按 Beancount 或 Ledger（hledger 兼容）的写法输出账本
+type ledgerWriter struct {
+	w         *bufio.Writer
+	beancount bool
+}
+
+// ledgerAccountInfo 本系统账户在账本中的名称与币种
+type ledgerAccountInfo struct {
+	name     string
+	currency string
+}
+
+func exportLedger(userDB *sql.DB, filter models.TransactionFilter, w io.Writer, beancount bool) error {
+	lw := &ledgerWriter{w: bufio.NewWriter(w), beancount: beancount}
+
+	accounts, err := database.GetAccounts(userDB, true)
+	if err != nil {
+		return err
+	}
+	categories, err := database.GetCategories(userDB)
+	if err != nil {
+		return err
+	}
+	categoryTypes, err := database.GetCategoryTransactionTypes(userDB)
+	if err != nil {
+		return err
+	}
+
+	// 账户声明：本系统账户、类别以及导出用到的辅助账户
+	accountInfo := make(map[int64]ledgerAccountInfo)
+	names := map[string]bool{ledgerUnassignedAccount: true, ledgerOpeningAccount: true, ledgerTransferAccount: true}
+	for _, a := range accounts {
+		info := ledgerAccountInfo{name: ledgerAccountName(a, beancount), currency: a.Currency}
+		if info.currency == "" {
+			info.currency = defaultCurrency
+		}
+		accountInfo[a.ID] = info
+		names[info.name] = true
+	}
+	paths := categoryPaths(categories)
+	for _, c := range categories {
+		types := categoryTypes[c.ID]
+		if len(types) == 0 {
+			// 没有账单的类别按其收支类型声明
+			types = []string{"expense"}
+			if c.Kind == "income" {
+				types = []string{"income"}
+			}
+		}
+		for _, t := range types {
+			names[ledgerCategoryAccount(t, paths[c.ID], beancount)] = true
+		}
+	}
+	for _, t := range categoryTypes[0] {
+		names[ledgerCategoryAccount(t, ledgerUncategorized, beancount)] = true
+	}
+	lw.writeHeader(names)
+
+	for _, a := range accounts {
+		if a.OpeningBalance == 0 {
+			continue
+		}
+		info := accountInfo[a.ID]
+		lw.writeEntry(ledgerOpenDate, "", "期初余额", []ledgerPostingLine{
+			{account: info.name, cents: a.OpeningBalance, currency: info.currency},
+			{account: ledgerOpeningAccount, cents: -a.OpeningBalance, currency: info.currency},
+		})
+	}
+
+	lookup := func(accountID int64) ledgerAccountInfo {
+		if info, ok := accountInfo[accountID]; ok {
+			return info
+		}
+		return ledgerAccountInfo{name: ledgerUnassignedAccount, currency: defaultCurrency}
+	}
+	type transferLeg struct {
+		t     models.DisplayTransaction
+		cents int64
+	}
+	pending := make(map[int64]transferLeg) // 等待另一条腿的转账
+	splits, err := database.GetTransactionSplits(userDB, nil)
+	if err != nil {
+		return err
+	}
+	categoryAccount := func(transactionType string, categoryID int64, name string) string {
+		if path, ok := paths[categoryID]; ok {
+			name = path
+		}
+		return ledgerCategoryAccount(transactionType, name, beancount)
+	}
+	err = database.EachTransaction(userDB, filter, func(t models.DisplayTransaction, cents int64) error {
+		date, clock := splitLedgerDateTime(t.OccurredAt)
+		account := lookup(t.AccountID)
+		if t.Type != "transfer" {
+			// 拆分账单的每一行记为一个类别分录，备注写为该行的注释
+			var postings []ledgerPostingLine
+			if lines := splits[t.ID]; len(lines) > 0 {
+				for _, line := range lines {
+					postings = append(postings, ledgerPostingLine{
+						account: categoryAccount(t.Type, line.CategoryID, line.CategoryName), cents: -line.Amount, currency: account.currency,
+						note: line.Note,
+					})
+				}
+			} else {
+				postings = append(postings, ledgerPostingLine{
+					account: categoryAccount(t.Type, t.CategoryID, t.CategoryName), cents: -cents, currency: account.currency,
+				})
+			}
+			lw.writeEntry(date, clock, t.Note, append(postings,
+				ledgerPostingLine{account: account.name, cents: cents, currency: account.currency}))
+			return nil
+		}
+		other, ok := pending[t.TransferID]
+		if !ok {
+			pending[t.TransferID] = transferLeg{t: t, cents: cents}
+			return nil
+		}
+		delete(pending, t.TransferID)
+		// 转入腿在前，转出腿在后
+		in, out := transferLeg{t: t, cents: cents}, other
+		if in.cents < 0 {
+			in, out = out, in
+		}
+		lw.writeEntry(date, clock, t.Note, []ledgerPostingLine{
+			{account: lookup(in.t.AccountID).name, cents: in.cents, currency: account.currency},
+			{account: lookup(out.t.AccountID).name, cents: out.cents, currency: account.currency},
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 筛选条件只包含转账的一条腿时，另一方记为 Equity:Transfers
+	transferIDs := make([]int64, 0, len(pending))
+	for id := range pending {
+		transferIDs = append(transferIDs, id)
+	}
+	sort.Slice(transferIDs, func(i, j int) bool { return transferIDs[i] < transferIDs[j] })
+	for _, id := range transferIDs {
+		leg := pending[id]
+		date, clock := splitLedgerDateTime(leg.t.OccurredAt)
+		account := lookup(leg.t.AccountID)
+		lw.writeEntry(date, clock, leg.t.Note, []ledgerPostingLine{
+			{account: account.name, cents: leg.cents, currency: account.currency},
+			{account: ledgerTransferAccount, cents: -leg.cents, currency: account.currency},
+		})
+	}
+	return lw.w.Flush()
+}
+
+// writeHeader 输出账户声明（Beancount 的 open 指令，Ledger 的 account 指令）
+func (lw *ledgerWriter) writeHeader(names map[string]bool) {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	if lw.beancount {
+		lw.w.WriteString(`option "operating_currency" "` + defaultCurrency + "\"\n\n")
+	}
+	for _, name := range sorted {
+		if lw.beancount {
+			lw.w.WriteString(ledgerOpenDate + " open " + name + "\n")
+		} else {
+			lw.w.WriteString("account " + name + "\n")
+		}
+	}
+	lw.w.WriteString("\n")
+}
+
+// writeEntry 输出一条分录，clock 为空表示不记录时间
+func (lw *ledgerWriter) writeEntry(date, clock, note string, postings []ledgerPostingLine) {
+	note = strings.Join(strings.Fields(note), " ")
+	indent := "    "
+	if lw.beancount {
+		indent = "  "
+		lw.w.WriteString(date + " * " + quoteBeancountString(note) + "\n")
+		if clock != "" {
+			lw.w.WriteString(indent + `time: "` + clock + "\"\n")
+		}
+	} else {
+		lw.w.WriteString(strings.TrimSpace(date+" * "+note) + "\n")
+		if clock != "" {
+			lw.w.WriteString(indent + "; time: " + clock + "\n")
+		}
+	}
+	for _, p := range postings {
+		lw.w.WriteString(indent + p.account + "  " + strings.TrimPrefix(utils.CentsToYuanString(p.cents), "+") + " " + p.currency)
+		if note := strings.Join(strings.Fields(p.note), " "); note != "" {
+			lw.w.WriteString("  ; " + note)
+		}
+		lw.w.WriteString("\n")
+	}
+	lw.w.WriteString("\n")
+}
+
+// splitLedgerDateTime 把 "2006-01-02 15:04:05" 拆为日期与时间，零点时时间为空
+func splitLedgerDateTime(occurredAt string) (string, string) {
+	date, clock, _ := strings.Cut(occurredAt, " ")
+	if clock == "00:00:00" {
+		clock = ""
+	}
+	return date, clock
+}
+
+// ledgerAccountName 本系统账户在账本中的名称
+func ledgerAccountName(account models.Account, beancount bool) string {
+	root := "Assets"
+	if account.Kind == "credit" {
+		root = "Liabilities"
+	}
+	return root + ":" + ledgerAccountPath(account.Name, beancount)
+}
+
+// ledgerCategoryAccount 类别在账本中的名称：收入类为 Income:类别名，其余为 Expenses:类别名
+func ledgerCategoryAccount(transactionType, category string, beancount bool) string {
+	root := "Expenses"
+	if transactionType == "income" {
+		root = "Income"
+	}
+	return root + ":" + ledgerAccountPath(category, beancount)
+}
+
+// ledgerAccountPath 把名称转换为账户名的一段或多段（名称中的 ":" 作为层级分隔）。
+// Beancount 的每一段只能包含字母、数字与 "-"，且必须以大写字母、数字或非 ASCII 字符开头；
+// Ledger 允许空格，但连续两个空格会被当作账户名的结束，因此合并为一个。
+func ledgerAccountPath(name string, beancount bool) string {
+	var parts []string
+	for _, part := range strings.Split(name, ":") {
+		if beancount {
+			part = sanitizeBeancountComponent(part)
+		} else {
+			part = strings.Join(strings.Fields(strings.ReplaceAll(part, ";", " ")), " ")
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "Unnamed"
+	}
+	return strings.Join(parts, ":")
+}
+
+func sanitizeBeancountComponent(part string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.TrimSpace(part) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			// 空格、"-" 及其他符号统一为一个 "-"
+			if !dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = true
+			continue
+		}
+		if b.Len() == 0 && r >= 'a' && r <= 'z' {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		dash = false
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// quoteBeancountString 输出带引号的 Beancount 字符串
+func quoteBeancountString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 导出服务：按与账单列表相同的筛选条件，逐行把账单写出为 CSV / JSON / XLSX
+type ExportService struct {
+	masterDB *sql.DB
+}
+
+// 新建导出服务的方法
+func NewExportService(masterDB *sql.DB) *ExportService {
+	return &ExportService{masterDB: masterDB}
+}
+
+// 支持的导出格式及其 Content-Type
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	// 纯文本账本
+	"beancount": "text/plain; charset=utf-8",
+	"ledger":    "text/plain; charset=utf-8",
+	"hledger":   "text/plain; charset=utf-8",
+}
+
+// 导出文件的扩展名，未列出的与格式名相同
+var exportFileExtensions = map[string]string{
+	"ledger":  "journal",
+	"hledger": "journal",
+}
+
+// 导出的列（CSV / XLSX 的表头）；date、type、amount、category、payee、note、tags 可被 CSV 导入直接识别
+var exportHeader = []string{
+	"id", "occurred_at", "type", "amount", "amount_cents", "category", "account", "payee",
+	"note", "tags", "transfer_id", "recurring_rule_id", "created_at", "updated_at",
+}
+
+// JSON 导出的一行：展示字段加上原始金额（分）
+type exportTransaction struct {
+	models.DisplayTransaction
+	AmountCents int64 `json:"amount_cents"`
+}
+
+// ExportContentType 返回导出格式对应的 Content-Type，不支持的格式 ok 为 false
+func ExportContentType(format string) (contentType string, ok bool) {
+	contentType, ok = exportContentTypes[format]
+	return contentType, ok
+}
+
+// ExportFileExtension 返回导出格式对应的文件扩展名
+func ExportFileExtension(format string) string {
+	if ext, ok := exportFileExtensions[format]; ok {
+		return ext
+	}
+	return format
+}
+
+// ExportTransactions 把符合条件的账单以 format 格式写入 w（不分页，默认按发生时间从早到晚）。
+// 参数错误在写出任何内容之前返回。
+func (s *ExportService) ExportTransactions(userID int64, query TransactionQuery, format string, w io.Writer) error {
+	if _, ok := exportContentTypes[format]; !ok {
+		return utils.ErrInvalidParameter
+	}
+	if query.SortOrder == "" {
+		query.SortOrder = "asc"
+	}
+	filter, err := buildTransactionFilter(query)
+	if err != nil {
+		return err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	if err := resolveTagFilter(userDB, &filter, query.Tags); err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		return exportJSON(userDB, filter, w)
+	case "xlsx":
+		return exportXLSX(userDB, filter, w)
+	case "beancount":
+		return exportLedger(userDB, filter, w, true)
+	case "ledger", "hledger":
+		return exportLedger(userDB, filter, w, false)
+	default:
+		return exportCSV(userDB, filter, w)
+	}
+}
+
+func exportCSV(userDB *sql.DB, filter models.TransactionFilter, w io.Writer) error {
+	// 写入 UTF-8 BOM，Excel 才能正确识别中文
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportHeader); err != nil {
+		return err
+	}
+	count := 0
+	err := database.EachTransaction(userDB, filter, func(t models.DisplayTransaction, cents int64) error {
+		cw.Write([]string{
+			strconv.FormatInt(t.ID, 10), t.OccurredAt, t.Type, t.Amount, strconv.FormatInt(cents, 10),
+			spreadsheetText(t.CategoryName), spreadsheetText(t.AccountName), spreadsheetText(t.PayeeName),
+			spreadsheetText(t.Note), spreadsheetText(strings.Join(t.Tags, ",")),
+			formatOptionalID(t.TransferID), formatOptionalID(t.RecurringRuleID), t.CreatedAt, t.UpdatedAt,
+		})
+		// 定期刷新，边查询边输出
+		if count++; count%100 == 0 {
+			cw.Flush()
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportJSON(userDB *sql.DB, filter models.TransactionFilter, w io.Writer) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := database.EachTransaction(userDB, filter, func(t models.DisplayTransaction, cents int64) error {
+		data, err := json.Marshal(exportTransaction{DisplayTransaction: t, AmountCents: cents})
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func exportXLSX(userDB *sql.DB, filter models.TransactionFilter, w io.Writer) error {
+	xw, err := utils.NewXLSXWriter(w, "账单")
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(exportHeader))
+	for i, h := range exportHeader {
+		header[i] = h
+	}
+	if err := xw.WriteRow(header...); err != nil {
+		return err
+	}
+	err = database.EachTransaction(userDB, filter, func(t models.DisplayTransaction, cents int64) error {
+		// 金额写为数字单元格，便于在 Excel 中直接求和
+		return xw.WriteRow(
+			t.ID, t.OccurredAt, t.Type, utils.XLSXNumber(strings.TrimPrefix(t.Amount, "+")), cents,
+			spreadsheetText(t.CategoryName), spreadsheetText(t.AccountName), spreadsheetText(t.PayeeName),
+			spreadsheetText(t.Note), spreadsheetText(strings.Join(t.Tags, ",")),
+			formatOptionalID(t.TransferID), formatOptionalID(t.RecurringRuleID), t.CreatedAt, t.UpdatedAt,
+		)
+	})
+	if err != nil {
+		return err
+	}
+	return xw.Close()
+}
+
+// 以这些字符开头的单元格在 Excel 中会被当作公式
+const formulaPrefixes = "=+-@\t\r"
+
+// spreadsheetText 用户输入的文本（类别、账户、商户名，备注与标签，常来自导入的交易对方）以公式字符开头时
+// 前加单引号，避免在 Excel 中打开 CSV/XLSX 时被当作公式执行
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unspreadsheetText 去掉 spreadsheetText 加上的单引号，导出的 CSV 导回时还原原文
+func unspreadsheetText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// formatOptionalID 0 表示没有，导出为空
+func formatOptionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,30 @@
+package services
+
+import "testing"
+
+func TestSpreadsheetText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"午饭", "午饭"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := spreadsheetText(tt.input); got != tt.expected {
+			t.Errorf("spreadsheetText(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+		if got := unspreadsheetText(tt.expected); got != tt.input {
+			t.Errorf("unspreadsheetText(%q) = %q, want %q", tt.expected, got, tt.input)
+		}
+	}
+	if got := unspreadsheetText("'引号开头"); got != "'引号开头" {
+		t.Errorf("unspreadsheetText should keep other quoted text, got %q", got)
+	}
+}
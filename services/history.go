@@ -0,0 +1,208 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// 变更历史：账单与类别的每次新建、修改、删除、恢复、彻底删除都在同一个数据库事务中追加一条历史，
+// 记录变更前后的快照、操作人与会话。转账的两条腿记在 transfer_id 下。
+
+// Actor 发起变更的用户与会话
+type Actor struct {
+	UserID    int64
+	SessionID string // 会话 token，后台任务为空
+}
+
+// systemActor 后台任务（定期账单、回收站自动清理等）以账户所有者的身份记录历史
+func systemActor(userID int64) Actor {
+	return Actor{UserID: userID}
+}
+
+const (
+	historyEntityTransaction = "transaction"
+	historyEntityCategory    = "category"
+
+	historyActionCreate  = "create"
+	historyActionUpdate  = "update"
+	historyActionDelete  = "delete"  // 移到回收站
+	historyActionRestore = "restore" // 从回收站恢复
+	historyActionPurge   = "purge"   // 彻底删除
+	historyActionMerge   = "merge"   // 类别合并到其他类别
+	historyActionRevert  = "revert"  // 还原到某条历史记录之后的状态
+)
+
+// sessionFingerprint 返回会话的指纹（SHA-256 的前 16 位十六进制），历史中不保存会话 token 本身
+func sessionFingerprint(sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// historyKeepsEntity 报告操作之后实体是否仍然可用（需要记录变更后的快照）
+func historyKeepsEntity(action string) bool {
+	switch action {
+	case historyActionDelete, historyActionPurge, historyActionMerge:
+		return false
+	}
+	return true
+}
+
+// marshalSnapshot 把快照序列化为 JSON，nil 表示没有快照
+func marshalSnapshot[T any](s *T) (json.RawMessage, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return data, nil
+}
+
+// addHistory 追加一条历史。修改前后没有差别的 update 不记录
+func addHistory[T any](db database.DBTX, actor Actor, entityType string, entityID int64, action string, oldValues *T, newValues *T) error {
+	if action == historyActionUpdate && reflect.DeepEqual(oldValues, newValues) {
+		return nil
+	}
+	oldJSON, err := marshalSnapshot(oldValues)
+	if err != nil {
+		return err
+	}
+	newJSON, err := marshalSnapshot(newValues)
+	if err != nil {
+		return err
+	}
+	return database.AddHistory(db, &models.HistoryEntry{
+		EntityType:  entityType,
+		EntityID:    entityID,
+		Action:      action,
+		OldValues:   oldJSON,
+		NewValues:   newJSON,
+		ActorUserID: actor.UserID,
+		Session:     sessionFingerprint(actor.SessionID),
+	})
+}
+
+// recordTransactionHistory 记录账单（entityID 为历史中使用的 id）的一次变更：old 为变更前的快照（新建、恢复时为 nil），
+// 变更后的快照从数据库读取
+func recordTransactionHistory(db database.DBTX, actor Actor, action string, entityID int64, old *models.TransactionSnapshot) error {
+	var current *models.TransactionSnapshot
+	if historyKeepsEntity(action) {
+		s, _, err := database.GetTransactionSnapshot(db, entityID)
+		if err != nil {
+			return err
+		}
+		current = s
+	}
+	return addHistory(db, actor, historyEntityTransaction, entityID, action, old, current)
+}
+
+// recordCategoryHistory 记录类别的一次变更，规则同 recordTransactionHistory
+func recordCategoryHistory(db database.DBTX, actor Actor, action string, categoryID int64, old *models.CategorySnapshot) error {
+	var current *models.CategorySnapshot
+	if historyKeepsEntity(action) {
+		s, err := database.GetCategorySnapshot(db, categoryID)
+		if err != nil {
+			return err
+		}
+		current = s
+	}
+	return addHistory(db, actor, historyEntityCategory, categoryID, action, old, current)
+}
+
+// recordCreatedCategories 为 id 大于 afterID 的类别（即本次操作中自动创建的类别）记录新建历史
+func recordCreatedCategories(db database.DBTX, actor Actor, afterID int64) error {
+	ids, err := database.GetCategoryIDsAfter(db, afterID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := recordCategoryHistory(db, actor, historyActionCreate, id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotBeforeChange 读取将被修改的账单的快照；账单不存在时返回 nil 而不报错，由随后的修改返回相应的错误
+func snapshotBeforeChange(db database.DBTX, transactionID int64) (*models.TransactionSnapshot, int64, error) {
+	s, entityID, err := database.GetTransactionSnapshot(db, transactionID)
+	if errors.Is(err, utils.ErrTransactionNotFound) {
+		return nil, transactionID, nil
+	}
+	return s, entityID, err
+}
+
+// historyBaseline 类别操作之前的快照，用于记录操作对其他账单与类别的连带修改
+// （如合并、彻底删除类别时账单改为目标类别或未分类，彻底删除类别时子类别移到上一级）
+type historyBaseline struct {
+	transactions map[int64]*models.TransactionSnapshot
+	categories   map[int64]*models.CategorySnapshot
+}
+
+// captureHistoryBaseline 读取全部类别与属于 categoryIDs 的账单在操作之前的快照
+func captureHistoryBaseline(db database.DBTX, categoryIDs []int64) (*historyBaseline, error) {
+	categories, err := database.GetCategorySnapshots(db)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := database.GetTransactionIDsInCategories(db, categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make(map[int64]*models.TransactionSnapshot, len(ids))
+	for _, id := range ids {
+		if transactions[id], _, err = database.GetTransactionSnapshot(db, id); err != nil {
+			return nil, err
+		}
+	}
+	return &historyBaseline{transactions: transactions, categories: categories}, nil
+}
+
+// recordUpdates 为操作前后有变化、且仍然存在的账单与类别记录 update；handled 中的类别已由调用方单独记录
+func (b *historyBaseline) recordUpdates(db database.DBTX, actor Actor, handled ...int64) error {
+	for _, id := range sortedIDs(b.transactions) {
+		if err := recordTransactionHistory(db, actor, historyActionUpdate, id, b.transactions[id]); err != nil {
+			return err
+		}
+	}
+	if b.categories == nil {
+		return nil
+	}
+	current, err := database.GetCategorySnapshots(db)
+	if err != nil {
+		return err
+	}
+	skip := make(map[int64]bool, len(handled))
+	for _, id := range handled {
+		skip[id] = true
+	}
+	for _, id := range sortedIDs(b.categories) {
+		if s, ok := current[id]; ok && !skip[id] {
+			if err := addHistory(db, actor, historyEntityCategory, id, historyActionUpdate, b.categories[id], s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedIDs 返回按 id 排序的键，使历史记录的顺序固定
+func sortedIDs[T any](m map[int64]T) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// 变更历史服务：查看账单、类别的变更历史，把账单还原到历史中的某个版本
+type HistoryService struct {
+	masterDB *sql.DB
+}
+
+// 新建变更历史服务的方法
+func NewHistoryService(masterDB *sql.DB) *HistoryService {
+	return &HistoryService{masterDB: masterDB}
+}
+
+// 获取账单变更历史服务：转账可以用任意一条腿的 id 查询；已彻底删除的账单仍可查询其历史
+func (s *HistoryService) GetTransactionHistory(userID int64, transactionID int64) ([]models.HistoryEntry, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	entityID, err := database.HistoryTransactionID(userDB, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := database.GetHistory(userDB, historyEntityTransaction, entityID)
+	if err != nil {
+		return nil, err
+	}
+	// 没有历史时区分"账单不存在"与"启用变更历史之前记录、此后未改过的账单"
+	if len(entries) == 0 {
+		if _, _, err := database.GetTransactionSnapshot(userDB, transactionID); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// 获取类别变更历史服务
+func (s *HistoryService) GetCategoryHistory(userID int64, categoryID int64) ([]models.HistoryEntry, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	entries, err := database.GetHistory(userDB, historyEntityCategory, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		if _, err := database.GetCategorySnapshot(userDB, categoryID); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// 还原账单服务：把账单的金额、类型、类别、账户、商户、备注、时间、标签与拆分行还原为 historyID 这条历史之后的版本，
+// 并记录一条 revert 历史。回收站中的账单需先恢复；历史中的类别、商户已彻底删除时还原为未分类、无商户。
+func (s *HistoryService) RevertTransaction(actor Actor, transactionID int64, historyID int64) error {
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	current, err := database.GetTransactionByID(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	entityID := transactionID
+	if current.TransferID != 0 {
+		entityID = current.TransferID
+	}
+	entry, err := database.GetHistoryEntry(tx, historyID)
+	if err != nil {
+		return err
+	}
+	if entry.EntityType != historyEntityTransaction || entry.EntityID != entityID {
+		return utils.ErrHistoryNotFound
+	}
+	if entry.NewValues == nil {
+		return utils.ErrHistoryNotRevertible
+	}
+	var target models.TransactionSnapshot
+	if err := json.Unmarshal(entry.NewValues, &target); err != nil {
+		return utils.WrapError(utils.ErrHistoryNotRevertible, err)
+	}
+	// 转账与收支账单之间不能互相还原
+	if (current.TransferID != 0) != (target.Type == "transfer") {
+		return utils.ErrHistoryNotRevertible
+	}
+
+	old, _, err := database.GetTransactionSnapshot(tx, entityID)
+	if err != nil {
+		return err
+	}
+	if current.TransferID != 0 {
+		err = revertTransfer(tx, entityID, old, &target)
+	} else {
+		err = revertTransaction(tx, entityID, old, &target)
+	}
+	if err != nil {
+		return err
+	}
+	if err := recordTransactionHistory(tx, actor, historyActionRevert, entityID, old); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// revertTransfer 把转账的两条腿还原为快照中的金额、账户、备注与时间
+func revertTransfer(tx database.DBTX, transferID int64, old *models.TransactionSnapshot, target *models.TransactionSnapshot) error {
+	if target.AccountID != old.AccountID || target.ToAccountID != old.ToAccountID {
+		if err := checkTransferAccounts(tx, target.AccountID, target.ToAccountID); err != nil {
+			return err
+		}
+	}
+	return database.UpdateTransfer(tx, transferID, &target.Amount, &target.AccountID, &target.ToAccountID, &target.Note, &target.OccurredAt)
+}
+
+// revertTransaction 把收支账单还原为快照中的版本
+func revertTransaction(tx database.DBTX, transactionID int64, old *models.TransactionSnapshot, target *models.TransactionSnapshot) error {
+	categoryID, err := existingCategoryID(tx, target.CategoryID)
+	if err != nil {
+		return err
+	}
+	accountID := target.AccountID
+	if accountID != 0 && accountID != old.AccountID {
+		if err := checkAccountUsable(tx, accountID); err != nil {
+			return err
+		}
+	}
+	payeeID := target.PayeeID
+	if payeeID != 0 {
+		if _, err := database.GetPayeeByID(tx, payeeID); errors.Is(err, utils.ErrPayeeNotFound) {
+			payeeID = 0
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := database.UpdateTransaction(tx, transactionID, &target.Type, &target.Amount, &categoryID, &accountID, &payeeID,
+		&target.Note, &target.OccurredAt); err != nil {
+		return err
+	}
+
+	tagIDs, err := resolveTagIDs(tx, target.Tags)
+	if err != nil {
+		return err
+	}
+	if err := database.SetTransactionTags(tx, transactionID, tagIDs); err != nil {
+		return err
+	}
+	splits := make([]models.TransactionSplit, 0, len(target.Splits))
+	for _, line := range target.Splits {
+		splitCategoryID, err := existingCategoryID(tx, line.CategoryID)
+		if err != nil {
+			return err
+		}
+		splits = append(splits, models.TransactionSplit{CategoryID: splitCategoryID, Amount: line.Amount, Note: line.Note})
+	}
+	return database.SetTransactionSplits(tx, transactionID, splits)
+}
+
+// existingCategoryID 类别仍然存在（含回收站中的）时原样返回，已彻底删除时返回 0（未分类）
+func existingCategoryID(db database.DBTX, categoryID int64) (int64, error) {
+	if categoryID == 0 {
+		return 0, nil
+	}
+	if _, err := database.GetCategorySnapshot(db, categoryID); err != nil {
+		if errors.Is(err, utils.ErrCategoryNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return categoryID, nil
+}
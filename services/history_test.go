@@ -0,0 +1,165 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestSessionFingerprint(t *testing.T) {
+	if got := sessionFingerprint(""); got != "" {
+		t.Errorf("sessionFingerprint(\"\") = %q, want empty", got)
+	}
+	a := sessionFingerprint("token-a")
+	if len(a) != 16 {
+		t.Errorf("sessionFingerprint length = %d, want 16", len(a))
+	}
+	if a == "token-a" || a != sessionFingerprint("token-a") {
+		t.Errorf("sessionFingerprint should be a stable hash, got %q", a)
+	}
+	if a == sessionFingerprint("token-b") {
+		t.Errorf("different sessions should have different fingerprints")
+	}
+}
+
+func TestHistoryKeepsEntity(t *testing.T) {
+	tests := []struct {
+		action   string
+		expected bool
+	}{
+		{historyActionCreate, true},
+		{historyActionUpdate, true},
+		{historyActionRestore, true},
+		{historyActionRevert, true},
+		{historyActionDelete, false},
+		{historyActionPurge, false},
+		{historyActionMerge, false},
+	}
+	for _, tt := range tests {
+		if got := historyKeepsEntity(tt.action); got != tt.expected {
+			t.Errorf("historyKeepsEntity(%q) = %v, want %v", tt.action, got, tt.expected)
+		}
+	}
+}
+
+// newTestUser 在临时目录中注册一个用户（数据库文件使用相对路径），返回其身份与数据库连接
+func newTestUser(t *testing.T) (Actor, *sql.DB) {
+	t.Chdir(t.TempDir())
+	masterDB, err := database.InitMasterDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { masterDB.Close() })
+	userID, err := database.RegisterUser(masterDB, "tester", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { userDB.Close() })
+	return Actor{UserID: userID}, userDB
+}
+
+func TestRevertTransaction(t *testing.T) {
+	actor, userDB := newTestUser(t)
+	transactions := NewTransactionService(nil)
+
+	id, err := transactions.RecordTransaction(actor, RecordTransactionInput{
+		Type:   "expense",
+		Amount: "30",
+		Tags:   []string{"出差", "报销"},
+		Splits: []SplitInput{{Category: "差旅", Amount: "10", Note: "车票"}, {Category: "餐饮", Amount: "20"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	category, amount, tags, splits := "交通", "50", []string{}, []SplitInput{}
+	if err := transactions.UpdateTransaction(actor, id, UpdateTransactionInput{
+		Amount: &amount, Category: &category, Tags: &tags, Splits: &splits,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 拆分行用到的"差旅"类别被彻底删除后，还原时该行为未分类
+	travelID, err := database.GetCategoryIdByName(userDB, "差旅")
+	if err != nil || travelID == 0 {
+		t.Fatalf("category 差旅 not found: %v", err)
+	}
+	if err := NewCategoryService(nil).DeleteCategory(actor, travelID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTrashService(nil).PurgeCategory(actor, travelID); err != nil {
+		t.Fatal(err)
+	}
+
+	history := NewHistoryService(nil)
+	entries, err := history.GetTransactionHistory(actor.UserID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var createdID int64
+	for _, entry := range entries {
+		if entry.Action == historyActionCreate {
+			createdID = entry.ID
+		}
+	}
+	if createdID == 0 {
+		t.Fatalf("no create entry in history: %+v", entries)
+	}
+	if err := history.RevertTransaction(actor, id, createdID); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _, err := database.GetTransactionSnapshot(userDB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foodID, err := database.GetCategoryIdByName(userDB, "餐饮")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Amount != -3000 || got.CategoryID != 0 {
+		t.Errorf("amount = %d, category = %d, want -3000 and split (0)", got.Amount, got.CategoryID)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"出差", "报销"}) {
+		t.Errorf("tags = %v, want [出差 报销]", got.Tags)
+	}
+	if len(got.Splits) != 2 {
+		t.Fatalf("splits = %+v, want 2 lines", got.Splits)
+	}
+	if s := got.Splits[0]; s.CategoryID != 0 || s.Amount != -1000 || s.Note != "车票" {
+		t.Errorf("split 0 = %+v, want purged category as uncategorized", s)
+	}
+	if s := got.Splits[1]; s.CategoryID != foodID || s.Amount != -2000 {
+		t.Errorf("split 1 = %+v, want category %d amount -2000", s, foodID)
+	}
+
+	entries, err = history.GetTransactionHistory(actor.UserID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.Action != historyActionRevert {
+		t.Errorf("last history action = %q, want %q", last.Action, historyActionRevert)
+	}
+}
+
+func TestChangeHistoryAppendOnly(t *testing.T) {
+	actor, userDB := newTestUser(t)
+	if _, err := NewTransactionService(nil).RecordTransaction(actor, RecordTransactionInput{Type: "expense", Amount: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"UPDATE change_history SET action = 'delete'",
+		"DELETE FROM change_history",
+	} {
+		if _, err := userDB.Exec(stmt); err == nil {
+			t.Errorf("%s: expected error, change_history should be append-only", stmt)
+		}
+	}
+	var count int
+	if err := userDB.QueryRow("SELECT COUNT(*) FROM change_history").Scan(&count); err != nil || count != 1 {
+		t.Errorf("change_history rows = %d (%v), want 1", count, err)
+	}
+}
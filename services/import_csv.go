@@ -0,0 +1,238 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSV 导入
+
+// CSVMapping CSV 的列映射。每一列可以写表头名称（不区分大小写）或从 1 开始的列序号；
+// 留空时按常见表头名自动识别。没有类型列时，金额列按带符号金额处理（负数为支出）。
+type CSVMapping struct {
+	DateColumn     string
+	AmountColumn   string
+	TypeColumn     string
+	CategoryColumn string
+	NoteColumn     string
+	TagColumn      string // 多个标签以逗号分隔
+	PayeeColumn    string
+	HasHeader      bool
+	Delimiter      string // 默认逗号，"tab" 或 "\t" 表示制表符
+	AccountID      int64  // 导入到哪个账户，0 表示不指定
+}
+
+// 各列留空时自动识别的表头名
+var csvDefaultHeaders = map[string][]string{
+	"date":     {"date", "日期", "时间", "occurred_at", "交易时间"},
+	"amount":   {"amount", "金额", "金额(元)"},
+	"type":     {"type", "类型", "收/支", "收支"},
+	"category": {"category", "类别", "分类", "category_name"},
+	"note":     {"note", "备注", "说明", "memo"},
+	"tags":     {"tags", "标签", "tag"},
+	"payee":    {"payee", "商户", "交易对方", "对方"},
+}
+
+// 类型列中可识别的取值
+var csvTypeValues = map[string]string{
+	"income": "income", "收入": "income", "收": "income", "in": "income", "credit": "income",
+	"expense": "expense", "支出": "expense", "支": "expense", "out": "expense", "debit": "expense",
+}
+
+// ImportCSV 导入 CSV 文件中的账单（dryRun 时只校验不写入）
+func (s *ImportService) ImportCSV(actor Actor, r io.Reader, mapping CSVMapping, dryRun bool) (*models.ImportResult, error) {
+	batch, err := parseCSVRecords(r, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// parseCSVRecords 按列映射解析 CSV，返回可导入的记录与解析失败的行
+func parseCSVRecords(r io.Reader, mapping CSVMapping) (*importBatch, error) {
+	reader, err := newCSVReader(r, mapping.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	if mapping.HasHeader {
+		header, err = reader.Read()
+		if err != nil {
+			return nil, utils.WrapError(utils.ErrImportInvalidFile, err)
+		}
+	}
+	dateCol, err := resolveCSVColumn(mapping.DateColumn, header, csvDefaultHeaders["date"])
+	if err != nil {
+		return nil, err
+	}
+	amountCol, err := resolveCSVColumn(mapping.AmountColumn, header, csvDefaultHeaders["amount"])
+	if err != nil {
+		return nil, err
+	}
+	if dateCol < 0 || amountCol < 0 {
+		return nil, utils.ErrImportNoColumn
+	}
+	typeCol, err := resolveCSVColumn(mapping.TypeColumn, header, csvDefaultHeaders["type"])
+	if err != nil {
+		return nil, err
+	}
+	categoryCol, err := resolveCSVColumn(mapping.CategoryColumn, header, csvDefaultHeaders["category"])
+	if err != nil {
+		return nil, err
+	}
+	noteCol, err := resolveCSVColumn(mapping.NoteColumn, header, csvDefaultHeaders["note"])
+	if err != nil {
+		return nil, err
+	}
+	tagCol, err := resolveCSVColumn(mapping.TagColumn, header, csvDefaultHeaders["tags"])
+	if err != nil {
+		return nil, err
+	}
+	payeeCol, err := resolveCSVColumn(mapping.PayeeColumn, header, csvDefaultHeaders["payee"])
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &importBatch{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				batch.errors = append(batch.errors, models.ImportRowError{Line: parseErr.StartLine, Error: "CSV 格式错误"})
+				continue
+			}
+			return nil, utils.WrapError(utils.ErrImportInvalidFile, err)
+		}
+		if isBlankRow(row) {
+			continue
+		}
+		if len(batch.records)+len(batch.errors) >= maxImportRows {
+			return nil, utils.ErrImportTooManyRows
+		}
+
+		cell := func(col int) string {
+			if col < 0 || col >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[col])
+		}
+		// 文本列可能是本系统导出的 CSV，去掉导出时防公式加上的单引号
+		input := RecordTransactionInput{
+			Category:   unspreadsheetText(cell(categoryCol)),
+			Note:       unspreadsheetText(cell(noteCol)),
+			Payee:      unspreadsheetText(cell(payeeCol)),
+			OccurredAt: cell(dateCol),
+			AccountID:  mapping.AccountID,
+		}
+		if tags := unspreadsheetText(cell(tagCol)); tags != "" {
+			input.Tags = []string{tags}
+		}
+		if input.OccurredAt == "" {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: "缺少日期"})
+			continue
+		}
+		amount := strings.TrimLeft(cell(amountCol), "¥￥$")
+		var cents int64
+		if typeCol >= 0 {
+			input.Type = csvTypeValues[strings.ToLower(cell(typeCol))]
+			if input.Type == "" {
+				batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: utils.ErrInvalidTransactionType.Message})
+				continue
+			}
+			cents, err = utils.ParseToCents(amount)
+		} else {
+			// 带符号金额：负数为支出，正数为收入
+			cents, err = utils.ParseSignedToCents(amount)
+			input.Type = "income"
+			if cents < 0 {
+				input.Type = "expense"
+			}
+		}
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: errorMessage(err)})
+			continue
+		}
+		if cents == 0 {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: utils.ErrAmountZero.Message})
+			continue
+		}
+		input.Amount = utils.CentsToYuanString(abs(cents))
+		batch.records = append(batch.records, importRecord{Line: line, Input: input})
+	}
+	return batch, nil
+}
+
+// newCSVReader 创建 CSV 读取器：去掉 UTF-8 BOM，行的列数允许不一致
+func newCSVReader(r io.Reader, delimiter string) (*csv.Reader, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	switch delimiter {
+	case "", ",":
+	case "tab", `\t`, "\t":
+		reader.Comma = '\t'
+	default:
+		comma, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || comma == '"' || comma == '\r' || comma == '\n' {
+			return nil, utils.ErrInvalidParameter
+		}
+		reader.Comma = comma
+	}
+	return reader, nil
+}
+
+// resolveCSVColumn 把列映射解析为从 0 开始的列下标；spec 为空时按默认表头名查找，找不到返回 -1
+func resolveCSVColumn(spec string, header []string, defaults []string) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		for _, name := range defaults {
+			if i := findCSVHeader(header, name); i >= 0 {
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+	if i := findCSVHeader(header, spec); i >= 0 {
+		return i, nil
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n >= 1 {
+		return n - 1, nil
+	}
+	return -1, utils.ErrImportNoColumn
+}
+
+func findCSVHeader(header []string, name string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isBlankRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCSVRecordsSignedAmount(t *testing.T) {
+	data := "\xEF\xBB\xBF日期,金额,类别,备注\n" +
+		"2025/1/2,-12.50,餐饮,午饭\n" +
+		"\n" +
+		"2025-01-03,\"1,000.00\",工资,\n" +
+		",5,其他,缺日期\n" +
+		"2025-01-04,abc,其他,金额错误\n" +
+		"2025-01-05,0,其他,零\n"
+
+	batch, err := parseCSVRecords(strings.NewReader(data), CSVMapping{HasHeader: true})
+	if err != nil {
+		t.Fatalf("parseCSVRecords returned error: %v", err)
+	}
+	records, rowErrors := batch.records, batch.errors
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	first := records[0]
+	if first.Line != 2 || first.Input.Type != "expense" || first.Input.Amount != "+12.50" ||
+		first.Input.Category != "餐饮" || first.Input.Note != "午饭" || first.Input.OccurredAt != "2025/1/2" {
+		t.Errorf("unexpected first record: %+v", first)
+	}
+	if second := records[1]; second.Line != 4 || second.Input.Type != "income" || second.Input.Amount != "+1000.00" {
+		t.Errorf("unexpected second record: %+v", second)
+	}
+
+	wantLines := []int{5, 6, 7}
+	if len(rowErrors) != len(wantLines) {
+		t.Fatalf("got %d row errors, want %d: %+v", len(rowErrors), len(wantLines), rowErrors)
+	}
+	for i, line := range wantLines {
+		if rowErrors[i].Line != line {
+			t.Errorf("row error %d on line %d, want %d", i, rowErrors[i].Line, line)
+		}
+	}
+}
+
+func TestParseCSVRecordsMapping(t *testing.T) {
+	data := "2025-01-02;支出;30;交通\n2025-01-03;收;8;红包\n2025-01-04;转账;1;x\n"
+	mapping := CSVMapping{
+		DateColumn: "1", TypeColumn: "2", AmountColumn: "3", CategoryColumn: "4",
+		Delimiter: ";",
+	}
+	batch, err := parseCSVRecords(strings.NewReader(data), mapping)
+	if err != nil {
+		t.Fatalf("parseCSVRecords returned error: %v", err)
+	}
+	records, rowErrors := batch.records, batch.errors
+	if len(records) != 2 || records[0].Input.Type != "expense" || records[1].Input.Type != "income" {
+		t.Errorf("unexpected records: %+v", records)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Line != 3 {
+		t.Errorf("unexpected row errors: %+v", rowErrors)
+	}
+
+	if _, err := parseCSVRecords(strings.NewReader("a,b\n1,2\n"), CSVMapping{HasHeader: true}); err == nil {
+		t.Errorf("expected error when date/amount columns cannot be found")
+	}
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// 纯文本账本导入（Beancount / Ledger / hledger）
+// 每条分录按记账行拆为本系统的账单：
+//   - Expenses:… 记账行为支出（金额为负时为收入，如退款），Income:… 记账行为收入（金额为正时为支出），
+//     根账户之后的部分是类别的层级路径（如 "Food:Dining" 为 Food 下的 Dining，与导出时的写法一致），
+//     记账时逐级查找或创建类别
+//   - 一条分录有多个同向的收支记账行时为一笔拆分账单，每个记账行为一个拆分行（记账行的注释为拆分行的备注）；
+//     方向不同的（如支出中夹着一行退款）仍拆为多笔账单
+//   - 分录中的第一个 Assets:/Liabilities: 记账行决定账单所属的账户
+//   - 只有两个 Assets:/Liabilities: 记账行的分录为转账，两个账户都必须能对应到本系统的账户
+//   - 其余分录（期初余额、Equity 调整等）以及 open、balance、price 等指令跳过
+// 分录没有唯一编号，按分录内容以及同样内容在文件中第几次出现生成 ExternalID，重复导入是安全的。
+
+var (
+	ledgerDatePattern     = regexp.MustCompile(`^(\d{4}[-/]\d{1,2}[-/]\d{1,2})(=\S+)?(\s+|$)`)
+	ledgerMetadataPattern = regexp.MustCompile(`^([a-z][A-Za-z0-9_-]*):(?:\s+(.*))?$`)
+	ledgerTimePattern     = regexp.MustCompile(`\btime:\s*"?(\d{1,2}:\d{2}(:\d{2})?)"?`)
+)
+
+// Beancount 中不是交易的指令
+var beancountDirectives = map[string]bool{
+	"open": true, "close": true, "balance": true, "pad": true, "note": true, "document": true,
+	"price": true, "event": true, "query": true, "custom": true, "commodity": true,
+}
+
+// ledgerPosting 分录中的一个记账行
+type ledgerPosting struct {
+	line      int
+	account   string
+	cents     int64
+	hasAmount bool
+	note      string // 行尾注释
+}
+
+// ledgerEntry 账本中的一条交易分录
+type ledgerEntry struct {
+	line     int
+	date     string
+	clock    string
+	note     string
+	postings []ledgerPosting
+	source   []string // 分录的原文，用于生成去重编号
+	err      *models.ImportRowError
+}
+
+// ImportLedger 导入 Beancount / Ledger / hledger 账本。
+// 账本中的 Assets:/Liabilities: 账户按导出时的命名规则自动对应到同名的本系统账户，options.AccountMap 可覆盖。
+func (s *ImportService) ImportLedger(actor Actor, r io.Reader, options BankImportOptions, dryRun bool) (*models.ImportResult, error) {
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := database.GetAccounts(userDB, true)
+	userDB.Close()
+	if err != nil {
+		return nil, err
+	}
+	accountMap := make(map[string]int64)
+	for _, a := range accounts {
+		accountMap[ledgerAccountName(a, true)] = a.ID
+		accountMap[ledgerAccountName(a, false)] = a.ID
+	}
+	for name, id := range options.AccountMap {
+		accountMap[name] = id
+	}
+	options.AccountMap = accountMap
+
+	batch, err := parseLedgerRecords(r, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// parseLedgerRecords 解析账本，把每条分录转换为待导入的账单
+func parseLedgerRecords(r io.Reader, options BankImportOptions) (*importBatch, error) {
+	entries, err := scanLedgerEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	batch := &importBatch{}
+	seen := make(map[string]int)
+	for _, entry := range entries {
+		if len(batch.records)+len(batch.errors)+batch.skipped >= maxImportRows {
+			return nil, utils.ErrImportTooManyRows
+		}
+		if entry.err != nil {
+			batch.errors = append(batch.errors, *entry.err)
+			continue
+		}
+		inputs, err := ledgerEntryInputs(entry, options)
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: entry.line, Error: errorMessage(err)})
+			continue
+		}
+		if len(inputs) == 0 {
+			batch.skipped++
+			continue
+		}
+		sum := sha1.Sum([]byte(strings.Join(entry.source, "\n")))
+		key := hex.EncodeToString(sum[:10])
+		seen[key]++
+		for i, input := range inputs {
+			input.ExternalID = fmt.Sprintf("ledger:%s#%d.%d", key, seen[key], i+1)
+			batch.records = append(batch.records, importRecord{Line: entry.line, Input: input})
+		}
+	}
+	return batch, nil
+}
+
+// ledgerEntryInputs 把一条分录转换为记账输入；不对应任何收支或转账时返回空
+func ledgerEntryInputs(entry ledgerEntry, options BankImportOptions) ([]RecordTransactionInput, error) {
+	occurredAt := entry.date
+	if entry.clock != "" {
+		occurredAt += " " + entry.clock
+	}
+	t, dateOnly, err := utils.ParseDateTime(occurredAt)
+	if err != nil {
+		return nil, err
+	}
+	occurredAt = utils.FormatDateTime(t)
+	if dateOnly {
+		occurredAt = t.Format(utils.DateLayout)
+	}
+
+	var categories, assets []ledgerPosting
+	other := 0
+	for _, p := range entry.postings {
+		switch ledgerRoot(p.account) {
+		case "expenses", "expense", "income", "revenue", "revenues":
+			categories = append(categories, p)
+		case "assets", "asset", "liabilities", "liability":
+			assets = append(assets, p)
+		default:
+			other++
+		}
+	}
+
+	if len(categories) == 0 {
+		if len(assets) != 2 || other != 0 || assets[0].cents != -assets[1].cents || assets[0].cents == 0 {
+			return nil, nil
+		}
+		out, in := assets[0], assets[1]
+		if out.cents > 0 {
+			out, in = in, out
+		}
+		// 转账的两个账户都必须明确对应到本系统的账户
+		fromID, fromOK := options.AccountMap[out.account]
+		toID, toOK := options.AccountMap[in.account]
+		if !fromOK || !toOK {
+			return nil, nil
+		}
+		return []RecordTransactionInput{{
+			Type:        "transfer",
+			Amount:      utils.CentsToYuanString(in.cents),
+			Note:        entry.note,
+			OccurredAt:  occurredAt,
+			AccountID:   fromID,
+			ToAccountID: toID,
+		}}, nil
+	}
+
+	var accountID int64
+	if len(assets) > 0 {
+		accountID = options.targetAccount(assets[0].account)
+	} else {
+		accountID = options.AccountID
+	}
+	var lines []ledgerPosting
+	for _, p := range categories {
+		if p.cents != 0 {
+			lines = append(lines, p)
+		}
+	}
+	// 记账行的金额从账户角度记录：支出为正，收入为负
+	newInput := func(cents int64) RecordTransactionInput {
+		input := RecordTransactionInput{
+			Type:       "expense",
+			Amount:     utils.CentsToYuanString(abs(cents)),
+			Note:       entry.note,
+			OccurredAt: occurredAt,
+			AccountID:  accountID,
+		}
+		if cents < 0 {
+			input.Type = "income"
+		}
+		return input
+	}
+	if ledgerSameDirection(lines) {
+		var total int64
+		splits := make([]SplitInput, 0, len(lines))
+		for _, p := range lines {
+			total += p.cents
+			splits = append(splits, SplitInput{
+				Category: ledgerCategoryName(p.account),
+				Amount:   utils.CentsToYuanString(abs(p.cents)),
+				Note:     p.note,
+			})
+		}
+		input := newInput(total)
+		input.Splits = splits
+		return []RecordTransactionInput{input}, nil
+	}
+	var inputs []RecordTransactionInput
+	for _, p := range lines {
+		input := newInput(p.cents)
+		input.Category = ledgerCategoryName(p.account)
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// ledgerSameDirection 报告是否有两个以上的收支记账行且方向相同（可以合成一笔拆分账单）
+func ledgerSameDirection(lines []ledgerPosting) bool {
+	if len(lines) < 2 {
+		return false
+	}
+	for _, p := range lines[1:] {
+		if (p.cents < 0) != (lines[0].cents < 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanLedgerEntries 逐行读取账本中的交易分录
+func scanLedgerEntries(r io.Reader) ([]ledgerEntry, error) {
+	text, err := decodeStatementText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ledgerEntry
+	var current *ledgerEntry
+	finish := func() {
+		if current != nil {
+			if current.err == nil {
+				if err := fillElidedAmount(current); err != nil {
+					current.err = err
+				}
+			}
+			entries = append(entries, *current)
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if raw[0] != ' ' && raw[0] != '\t' {
+			finish()
+			m := ledgerDatePattern.FindStringSubmatch(raw)
+			if m == nil {
+				// option、include、account、注释等
+				continue
+			}
+			rest := strings.TrimSpace(raw[len(m[0]):])
+			if fields := strings.Fields(rest); len(fields) > 0 && beancountDirectives[fields[0]] {
+				continue
+			}
+			current = &ledgerEntry{line: line, date: m[1], note: ledgerEntryNote(rest), source: []string{raw}}
+			continue
+		}
+		if current == nil {
+			// 非交易指令的缩进行
+			continue
+		}
+
+		trimmed := strings.TrimSpace(raw)
+		current.source = append(current.source, trimmed)
+		if trimmed[0] == ';' || trimmed[0] == '#' {
+			// Ledger 的注释行，可能带有 time 标签
+			if m := ledgerTimePattern.FindStringSubmatch(trimmed); m != nil {
+				current.clock = normalizeLedgerClock(m[1])
+			}
+			continue
+		}
+		if m := ledgerMetadataPattern.FindStringSubmatch(trimmed); m != nil {
+			// Beancount 的元数据
+			if m[1] == "time" {
+				current.clock = normalizeLedgerClock(strings.Trim(m[2], `"`))
+			}
+			continue
+		}
+		posting, err := parseLedgerPosting(trimmed)
+		if err != nil {
+			if current.err == nil {
+				current.err = &models.ImportRowError{Line: line, Error: errorMessage(err)}
+			}
+			continue
+		}
+		posting.line = line
+		current.postings = append(current.postings, posting)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrImportInvalidFile, err)
+	}
+	finish()
+	return entries, nil
+}
+
+// ledgerEntryNote 从分录首行（日期之后的部分）取出摘要：
+// Beancount 为 [标记] ["收付款方"] "摘要"，Ledger 为 [标记] [(编号)] 描述 [; 注释]
+func ledgerEntryNote(rest string) string {
+	if strings.HasPrefix(rest, "txn") {
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "txn"))
+	} else if strings.HasPrefix(rest, "*") || strings.HasPrefix(rest, "!") {
+		rest = strings.TrimSpace(rest[1:])
+	}
+	if strings.HasPrefix(rest, `"`) {
+		var parts []string
+		for strings.HasPrefix(rest, `"`) {
+			s, remaining, ok := readBeancountString(rest)
+			if !ok {
+				break
+			}
+			parts = append(parts, s)
+			rest = strings.TrimSpace(remaining)
+		}
+		return joinStatementNote(parts...)
+	}
+	if strings.HasPrefix(rest, "(") {
+		if i := strings.IndexByte(rest, ')'); i >= 0 {
+			rest = strings.TrimSpace(rest[i+1:])
+		}
+	}
+	for _, sep := range []string{"  ;", "\t;"} {
+		if i := strings.Index(rest, sep); i >= 0 {
+			rest = rest[:i]
+		}
+	}
+	return strings.TrimSpace(rest)
+}
+
+// readBeancountString 读取开头的一个带引号字符串，返回内容与剩余部分
+func readBeancountString(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
+
+// parseLedgerPosting 解析记账行 "[标记] 账户  金额 [币种] [@ 价格] [; 注释]"，金额可以省略
+func parseLedgerPosting(line string) (ledgerPosting, error) {
+	if len(line) > 1 && (line[0] == '*' || line[0] == '!') && (line[1] == ' ' || line[1] == '\t') {
+		line = strings.TrimSpace(line[1:])
+	}
+	var note string
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line, note = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+	}
+	// Ledger 的账户名可以包含空格，以两个空格或制表符结束；Beancount 的账户名不含空格，与金额之间可以只有一个空格
+	account, amount := line, ""
+	if i := strings.IndexAny(line, "\t"); i >= 0 {
+		account, amount = line[:i], line[i+1:]
+	} else if i := strings.Index(line, "  "); i >= 0 {
+		account, amount = line[:i], line[i+2:]
+	} else if i := strings.IndexByte(line, ' '); i >= 0 && strings.ContainsAny(line[i+1:], "0123456789") {
+		account, amount = line[:i], line[i+1:]
+	}
+	posting := ledgerPosting{account: strings.TrimSpace(account), note: note}
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return posting, nil
+	}
+	cents, err := parseLedgerAmount(amount)
+	if err != nil {
+		return posting, err
+	}
+	posting.cents, posting.hasAmount = cents, true
+	return posting, nil
+}
+
+// parseLedgerAmount 解析记账行的金额，如 "12.50 CNY"、"CNY -12.50"、"-$1,234.50"、"10 USD @ 7.1 CNY"（只取数量）
+func parseLedgerAmount(amount string) (int64, error) {
+	if i := strings.IndexAny(amount, "@{"); i >= 0 {
+		amount = amount[:i]
+	}
+	var b strings.Builder
+	for _, r := range amount {
+		if (r >= '0' && r <= '9') || strings.ContainsRune(".,-+()", r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return 0, utils.ErrInvalidParameter
+	}
+	return utils.ParseSignedToCents(b.String())
+}
+
+// fillElidedAmount 计算分录中省略了金额的记账行（最多一行），使分录借贷平衡
+func fillElidedAmount(entry *ledgerEntry) *models.ImportRowError {
+	elided := -1
+	var sum int64
+	for i, p := range entry.postings {
+		if !p.hasAmount {
+			if elided >= 0 {
+				return &models.ImportRowError{Line: p.line, Error: "分录中只能有一个记账行省略金额"}
+			}
+			elided = i
+			continue
+		}
+		sum += p.cents
+	}
+	if elided >= 0 {
+		entry.postings[elided].cents = -sum
+		entry.postings[elided].hasAmount = true
+	}
+	return nil
+}
+
+// ledgerRoot 账户名的根（小写），如 "Expenses:Food" -> "expenses"
+func ledgerRoot(account string) string {
+	root, _, _ := strings.Cut(account, ":")
+	return strings.ToLower(root)
+}
+
+// ledgerCategoryName 收支账户名中根之后的部分，作为类别路径：各级名称整理空白后以 ":" 连接，空的层级去掉。
+// 记账时 resolveCategoryID 按 ":" 逐级查找或创建上级、子类别，不会创建名称中带 ":" 的类别
+func ledgerCategoryName(account string) string {
+	_, path, _ := strings.Cut(account, ":")
+	var names []string
+	for _, name := range strings.Split(path, ":") {
+		if name = cleanCategoryName(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ":")
+}
+
+// normalizeLedgerClock 把 "9:05" 之类的时间补全为 "09:05:00"
+func normalizeLedgerClock(clock string) string {
+	parts := strings.Split(clock, ":")
+	for len(parts) < 3 {
+		parts = append(parts, "00")
+	}
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLedgerRecordsBeancount(t *testing.T) {
+	data := `option "operating_currency" "CNY"
+1970-01-01 open Assets:Cash
+2024-01-05 balance Assets:Cash 0 CNY
+
+2024-01-05 * "Cafe" "Lunch \"set\""
+  time: "12:30:00"
+  Expenses:Food:Dining  30.00 CNY ; noodles
+  Expenses:Drinks  5.00 CNY
+  Assets:Cash
+
+2024-01-10 txn "Salary"
+  Income:Salary  -1,000.00 CNY
+  Assets:Bank  1000.00 CNY
+
+2024-01-11 * "Move"
+  Assets:Bank  -200.00 CNY
+  Assets:Cash  200.00 CNY
+
+2024-01-12 * "Opening"
+  Assets:Cash  10 CNY
+  Equity:Opening-Balances
+
+2024-01-13 * "Bad"
+  Expenses:Food
+  Assets:Cash
+
+2024-01-14 * "Return"
+  Expenses:Shoes  -40.00 CNY
+  Expenses:Socks  10.00 CNY
+  Assets:Cash
+`
+	options := BankImportOptions{AccountMap: map[string]int64{"Assets:Cash": 1, "Assets:Bank": 2}}
+	batch, err := parseLedgerRecords(strings.NewReader(data), options)
+	if err != nil {
+		t.Fatalf("parseLedgerRecords returned error: %v", err)
+	}
+	if len(batch.records) != 5 {
+		t.Fatalf("got %d records, want 5: %+v", len(batch.records), batch.records)
+	}
+	// 同向的多个收支记账行为一笔拆分账单
+	dining := batch.records[0]
+	if dining.Line != 5 || dining.Input.Type != "expense" || dining.Input.Amount != "+35.00" ||
+		dining.Input.Category != "" || dining.Input.Note != `Cafe - Lunch "set"` ||
+		dining.Input.OccurredAt != "2024-01-05 12:30:00" || dining.Input.AccountID != 1 {
+		t.Errorf("unexpected first record: %+v", dining)
+	}
+	wantSplits := []SplitInput{{Category: "Food:Dining", Amount: "+30.00", Note: "noodles"}, {Category: "Drinks", Amount: "+5.00"}}
+	if !reflect.DeepEqual(dining.Input.Splits, wantSplits) {
+		t.Errorf("splits = %+v, want %+v", dining.Input.Splits, wantSplits)
+	}
+	if salary := batch.records[1].Input; salary.Type != "income" || salary.Amount != "+1000.00" ||
+		salary.Category != "Salary" || salary.AccountID != 2 || salary.OccurredAt != "2024-01-10" {
+		t.Errorf("unexpected salary record: %+v", salary)
+	}
+	if move := batch.records[2].Input; move.Type != "transfer" || move.AccountID != 2 || move.ToAccountID != 1 || move.Amount != "+200.00" {
+		t.Errorf("unexpected transfer record: %+v", move)
+	}
+	// 方向不同的收支记账行仍拆为多笔账单
+	shoes, socks := batch.records[3].Input, batch.records[4].Input
+	if shoes.Type != "income" || shoes.Amount != "+40.00" || shoes.Category != "Shoes" ||
+		socks.Type != "expense" || socks.Amount != "+10.00" || socks.Category != "Socks" {
+		t.Errorf("unexpected mixed records: %+v, %+v", shoes, socks)
+	}
+	if shoes.ExternalID == socks.ExternalID {
+		t.Errorf("postings of one entry should get distinct external ids")
+	}
+	if batch.skipped != 1 || len(batch.errors) != 1 || batch.errors[0].Line != 25 {
+		t.Errorf("skipped = %d, errors = %+v", batch.skipped, batch.errors)
+	}
+}
+
+func TestParseLedgerRecordsLedger(t *testing.T) {
+	data := "account Expenses:Food\n" +
+		"; a comment\n" +
+		"2024/1/5 * (42) Corner Shop  ; receipt\n" +
+		"    ; time: 9:05\n" +
+		"    Expenses:Food & Drink    $12.50\n" +
+		"    Liabilities:Credit Card\n" +
+		"\n" +
+		"2024/1/6 Refund\n" +
+		"    Expenses:Food & Drink    -2.50 CNY\n" +
+		"    Liabilities:Credit Card    2.50 CNY\n"
+	batch, err := parseLedgerRecords(strings.NewReader(data), BankImportOptions{AccountID: 7})
+	if err != nil {
+		t.Fatalf("parseLedgerRecords returned error: %v", err)
+	}
+	if len(batch.records) != 2 || len(batch.errors) != 0 {
+		t.Fatalf("records = %+v, errors = %+v", batch.records, batch.errors)
+	}
+	shop := batch.records[0].Input
+	if shop.Type != "expense" || shop.Amount != "+12.50" || shop.Category != "Food & Drink" ||
+		shop.Note != "Corner Shop" || shop.OccurredAt != "2024-01-05 09:05:00" || shop.AccountID != 7 {
+		t.Errorf("unexpected record: %+v", shop)
+	}
+	if refund := batch.records[1].Input; refund.Type != "income" || refund.Amount != "+2.50" {
+		t.Errorf("unexpected refund record: %+v", refund)
+	}
+}
+
+func TestLedgerWriterRoundTrip(t *testing.T) {
+	for _, beancount := range []bool{true, false} {
+		var buf bytes.Buffer
+		lw := &ledgerWriter{w: bufio.NewWriter(&buf), beancount: beancount}
+		lw.writeHeader(map[string]bool{"Assets:现金": true, "Expenses:餐饮": true, "Expenses:餐饮:早餐": true})
+		lw.writeEntry("2024-01-05", "12:30:00", "午饭 \"套餐\"", []ledgerPostingLine{
+			{account: ledgerCategoryAccount("expense", "餐饮:早餐", beancount), cents: 1250, currency: "CNY"},
+			{account: "Assets:现金", cents: -1250, currency: "CNY"},
+		})
+		lw.writeEntry("2024-01-06", "", "超市", []ledgerPostingLine{
+			{account: ledgerCategoryAccount("expense", "餐饮", beancount), cents: 800, currency: "CNY", note: "水果"},
+			{account: ledgerCategoryAccount("expense", "日用", beancount), cents: 200, currency: "CNY"},
+			{account: "Assets:现金", cents: -1000, currency: "CNY"},
+		})
+		lw.w.Flush()
+
+		batch, err := parseLedgerRecords(&buf, BankImportOptions{AccountMap: map[string]int64{"Assets:现金": 3}})
+		if err != nil {
+			t.Fatalf("beancount=%v: parseLedgerRecords returned error: %v", beancount, err)
+		}
+		if len(batch.records) != 2 {
+			t.Fatalf("beancount=%v: got %d records, want 2", beancount, len(batch.records))
+		}
+		got := batch.records[0].Input
+		if got.Type != "expense" || got.Amount != "+12.50" || got.Category != "餐饮:早餐" || got.Note != "午饭 \"套餐\"" ||
+			got.OccurredAt != "2024-01-05 12:30:00" || got.AccountID != 3 {
+			t.Errorf("beancount=%v: unexpected record %+v", beancount, got)
+		}
+		split := batch.records[1].Input
+		wantSplits := []SplitInput{{Category: "餐饮", Amount: "+8.00", Note: "水果"}, {Category: "日用", Amount: "+2.00"}}
+		if split.Type != "expense" || split.Amount != "+10.00" || split.Category != "" || !reflect.DeepEqual(split.Splits, wantSplits) {
+			t.Errorf("beancount=%v: unexpected split record %+v", beancount, split)
+		}
+	}
+}
+
+func TestLedgerAccountPath(t *testing.T) {
+	cases := []struct {
+		name      string
+		beancount bool
+		want      string
+	}{
+		{"餐饮", true, "餐饮"},
+		{"coffee & tea", true, "Coffee-tea"},
+		{"Housing:rent ", true, "Housing:Rent"},
+		{"coffee  &  tea", false, "coffee & tea"},
+		{"!!!", true, "Unnamed"},
+	}
+	for _, c := range cases {
+		if got := ledgerAccountPath(c.name, c.beancount); got != c.want {
+			t.Errorf("ledgerAccountPath(%q, %v) = %q, want %q", c.name, c.beancount, got, c.want)
+		}
+	}
+}
+
+func TestLedgerCategoryName(t *testing.T) {
+	cases := []struct {
+		account string
+		want    string
+	}{
+		{"Expenses:餐饮", "餐饮"},
+		{"Expenses:餐饮:早餐", "餐饮:早餐"},
+		{"Income:Side  job: Tutoring ", "Side job:Tutoring"},
+		{"Expenses::Food::", "Food"},
+		{"Expenses", ""},
+	}
+	for _, c := range cases {
+		if got := ledgerCategoryName(c.account); got != c.want {
+			t.Errorf("ledgerCategoryName(%q) = %q, want %q", c.account, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// OFX / QFX 银行账单导入
+// OFX 有两种写法：1.x 为 SGML（叶子元素没有结束标签），2.x 为 XML；QFX 是 Quicken 使用的 OFX。
+// 这里不区分两者，按标签顺序扫描：<STMTRS>/<CCSTMTRS> 开始一份账单，<BANKACCTFROM>/<CCACCTFROM> 中的
+// ACCTID 是账单的账号，每个 <STMTTRN>…</STMTTRN> 是一条明细。
+// 以 "ofx:账号:FITID" 作为 ExternalID，重复导入同一账户的账单时自动跳过已导入的明细。
+
+// ofxTransaction OFX 中的一条明细
+type ofxTransaction struct {
+	line    int               // <STMTTRN> 所在的行号
+	account string            // 所属账单的账号
+	fields  map[string]string // 叶子元素，如 TRNAMT、DTPOSTED、FITID、NAME、MEMO
+}
+
+var xmlEntityReplacer = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'")
+
+// ImportOFX 导入 OFX / QFX 文件
+func (s *ImportService) ImportOFX(actor Actor, r io.Reader, options BankImportOptions, dryRun bool) (*models.ImportResult, error) {
+	batch, err := parseOFXRecords(r, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// parseOFXRecords 解析 OFX 明细：TRNAMT 为带符号金额（负数为支出），NAME、MEMO 写入备注
+func parseOFXRecords(r io.Reader, options BankImportOptions) (*importBatch, error) {
+	transactions, err := scanOFX(r)
+	if err != nil {
+		return nil, err
+	}
+	batch := &importBatch{}
+	for _, trn := range transactions {
+		if len(batch.records)+len(batch.errors)+batch.skipped >= maxImportRows {
+			return nil, utils.ErrImportTooManyRows
+		}
+		occurredAt, err := parseOFXDate(trn.fields["DTPOSTED"])
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: trn.line, Error: errorMessage(err)})
+			continue
+		}
+		cents, err := utils.ParseSignedToCents(normalizeDecimalComma(trn.fields["TRNAMT"]))
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: trn.line, Error: errorMessage(err)})
+			continue
+		}
+		if cents == 0 {
+			batch.skipped++
+			continue
+		}
+		input := RecordTransactionInput{
+			Type:       "income",
+			Amount:     utils.CentsToYuanString(abs(cents)),
+			OccurredAt: occurredAt,
+			AccountID:  options.targetAccount(trn.account),
+		}
+		if cents < 0 {
+			input.Type = "expense"
+		}
+		name, memo := trn.fields["NAME"], trn.fields["MEMO"]
+		if memo == name {
+			memo = ""
+		}
+		input.Note = joinStatementNote(name, memo)
+		input.Payee = name
+		if fitID := trn.fields["FITID"]; fitID != "" {
+			input.ExternalID = "ofx:" + trn.account + ":" + fitID
+		}
+		batch.records = append(batch.records, importRecord{Line: trn.line, Input: input})
+	}
+	return batch, nil
+}
+
+// scanOFX 按标签顺序扫描 OFX 文件，取出所有明细
+func scanOFX(r io.Reader) ([]ofxTransaction, error) {
+	text, err := decodeOFXText(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(strings.ToUpper(text), "<OFX>") {
+		return nil, utils.ErrImportInvalidFile
+	}
+
+	var (
+		transactions  []ofxTransaction
+		current       *ofxTransaction
+		account       string
+		inAccountFrom bool
+		line          = 1
+	)
+	for pos := 0; pos < len(text); {
+		open := strings.IndexByte(text[pos:], '<')
+		if open < 0 {
+			break
+		}
+		line += strings.Count(text[pos:pos+open], "\n")
+		start := pos + open
+		end := strings.IndexByte(text[start:], '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.ToUpper(strings.TrimSpace(text[start+1 : start+end]))
+		pos = start + end + 1
+
+		// 标签之后、下一个标签之前的文本是叶子元素的值
+		next := strings.IndexByte(text[pos:], '<')
+		if next < 0 {
+			next = len(text) - pos
+		}
+		value := strings.TrimSpace(xmlEntityReplacer.Replace(text[pos : pos+next]))
+
+		switch {
+		case tag == "" || tag[0] == '?' || tag[0] == '!':
+			// XML 声明、OFX 处理指令与注释
+		case tag == "STMTRS" || tag == "CCSTMTRS":
+			account = ""
+		case tag == "BANKACCTFROM" || tag == "CCACCTFROM":
+			inAccountFrom = true
+		case tag == "/BANKACCTFROM" || tag == "/CCACCTFROM":
+			inAccountFrom = false
+		case tag == "ACCTID" && inAccountFrom:
+			account = value
+		case tag == "STMTTRN":
+			current = &ofxTransaction{line: line, account: account, fields: make(map[string]string)}
+		case tag == "/STMTTRN":
+			if current != nil {
+				transactions = append(transactions, *current)
+				current = nil
+			}
+		case current != nil && tag[0] != '/' && value != "":
+			// PAYEE 聚合中的 NAME 只在明细没有 NAME 时使用
+			if _, exists := current.fields[tag]; !exists {
+				current.fields[tag] = value
+			}
+		}
+	}
+	if current != nil {
+		// 最后一条明细缺少结束标签
+		transactions = append(transactions, *current)
+	}
+	return transactions, nil
+}
+
+// decodeOFXText 读取 OFX 文件；不是合法 UTF-8 时，按文件头声明的 CHARSET:1252 或 GB18030 解码
+func decodeOFXText(r io.Reader) (string, error) {
+	data, err := readImportFile(r)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		header := data
+		if i := bytes.Index(bytes.ToUpper(data), []byte("<OFX>")); i >= 0 {
+			header = data[:i]
+		}
+		if bytes.Contains(bytes.ToUpper(header), []byte("CHARSET:1252")) {
+			if data, err = charmap.Windows1252.NewDecoder().Bytes(data); err != nil {
+				return "", utils.WrapError(utils.ErrImportInvalidFile, err)
+			}
+		}
+	}
+	return decodeStatementText(bytes.NewReader(data))
+}
+
+// parseOFXDate 把 OFX 的日期（如 "20240105"、"20240105120000.000[-5:EST]"）转换为记账使用的格式。
+// 时区部分被忽略，即按银行所在地的日期记账。
+func parseOFXDate(str string) (string, error) {
+	digits := 0
+	for digits < len(str) && str[digits] >= '0' && str[digits] <= '9' {
+		digits++
+	}
+	switch {
+	case digits >= 14:
+		t, err := time.ParseInLocation("20060102150405", str[:14], time.Local)
+		if err != nil {
+			return "", utils.ErrInvalidDate
+		}
+		return utils.FormatDateTime(t), nil
+	case digits >= 8:
+		t, err := time.ParseInLocation("20060102", str[:8], time.Local)
+		if err != nil {
+			return "", utils.ErrInvalidDate
+		}
+		return t.Format(utils.DateLayout), nil
+	}
+	return "", utils.ErrInvalidDate
+}
+
+// normalizeDecimalComma 把以逗号作小数点的金额（如 "-12,50"）转换为点号，其余原样返回
+func normalizeDecimalComma(amount string) string {
+	if strings.Contains(amount, ",") && !strings.Contains(amount, ".") {
+		if i := strings.LastIndexByte(amount, ','); len(amount)-i-1 <= 2 {
+			return amount[:i] + "." + amount[i+1:]
+		}
+	}
+	return amount
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOFXRecordsSGML(t *testing.T) {
+	data := "OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nCHARSET:1252\n\n" +
+		"<OFX>\n<BANKMSGSRSV1>\n<STMTTRNRS>\n<STMTRS>\n<CURDEF>USD\n" +
+		"<BANKACCTFROM>\n<BANKID>123\n<ACCTID>0001234\n<ACCTTYPE>CHECKING\n</BANKACCTFROM>\n" +
+		"<BANKTRANLIST>\n<DTSTART>20240101\n<DTEND>20240131\n" +
+		"<STMTTRN>\n<TRNTYPE>DEBIT\n<DTPOSTED>20240105120000.000[-5:EST]\n<TRNAMT>-12,50\n<FITID>A1\n<NAME>Coffee &amp; Co\n<MEMO>Coffee &amp; Co\n</STMTTRN>\n" +
+		"<STMTTRN>\n<TRNTYPE>CREDIT\n<DTPOSTED>20240110\n<TRNAMT>1,000.00\n<FITID>A2\n<NAME>Payroll\n<MEMO>Jan\n</STMTTRN>\n" +
+		"<STMTTRN>\n<TRNTYPE>OTHER\n<DTPOSTED>20240111\n<TRNAMT>0.00\n<FITID>A3\n</STMTTRN>\n" +
+		"<STMTTRN>\n<TRNTYPE>DEBIT\n<DTPOSTED>bad\n<TRNAMT>-1\n<FITID>A4\n</STMTTRN>\n" +
+		"</BANKTRANLIST>\n</STMTRS>\n</STMTTRNRS>\n</BANKMSGSRSV1>\n</OFX>\n"
+
+	batch, err := parseOFXRecords(strings.NewReader(data), BankImportOptions{AccountID: 1, AccountMap: map[string]int64{"0001234": 5}})
+	if err != nil {
+		t.Fatalf("parseOFXRecords returned error: %v", err)
+	}
+	if len(batch.records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(batch.records), batch.records)
+	}
+	first := batch.records[0]
+	if first.Line != 19 || first.Input.Type != "expense" || first.Input.Amount != "+12.50" || first.Input.Note != "Coffee & Co" ||
+		first.Input.OccurredAt != "2024-01-05 12:00:00" || first.Input.ExternalID != "ofx:0001234:A1" || first.Input.AccountID != 5 {
+		t.Errorf("unexpected first record: %+v", first)
+	}
+	if second := batch.records[1].Input; second.Type != "income" || second.Amount != "+1000.00" ||
+		second.Note != "Payroll - Jan" || second.OccurredAt != "2024-01-10" {
+		t.Errorf("unexpected second record: %+v", second)
+	}
+	if batch.skipped != 1 || len(batch.errors) != 1 || batch.errors[0].Line != 41 {
+		t.Errorf("skipped = %d, errors = %+v", batch.skipped, batch.errors)
+	}
+}
+
+func TestParseOFXRecordsXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+<CCACCTFROM><ACCTID>4033</ACCTID></CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240203</DTPOSTED><TRNAMT>-8.8</TRNAMT><FITID>X9</FITID>
+<PAYEE><NAME>Bookshop</NAME></PAYEE></STMTTRN>
+</BANKTRANLIST></CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>`
+
+	batch, err := parseOFXRecords(strings.NewReader(data), BankImportOptions{AccountID: 1})
+	if err != nil {
+		t.Fatalf("parseOFXRecords returned error: %v", err)
+	}
+	if len(batch.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(batch.records))
+	}
+	got := batch.records[0].Input
+	if got.Type != "expense" || got.Amount != "+8.80" || got.Note != "Bookshop" ||
+		got.ExternalID != "ofx:4033:X9" || got.AccountID != 1 {
+		t.Errorf("unexpected record: %+v", got)
+	}
+
+	if _, err := parseOFXRecords(strings.NewReader("not an ofx file"), BankImportOptions{}); err == nil {
+		t.Errorf("expected error for non-OFX input")
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 支付宝、微信支付账单导入
+// 两者导出的 CSV 在表头前有若干行说明文字，表头之后才是明细；支付宝为 GBK 编码，微信为 UTF-8（带 BOM）。
+// 每条明细以 "来源:交易单号" 作为 ExternalID，重复导入有重叠的账单时会自动跳过已导入的记录。
+
+// 支付宝账单的列（新版"交易时间…交易订单号"与旧版"交易创建时间…交易号"两种表头）
+var alipayColumns = map[string][]string{
+	"time":         {"交易时间", "交易创建时间", "付款时间"},
+	"category":     {"交易分类"},
+	"counterparty": {"交易对方"},
+	"goods":        {"商品说明", "商品名称"},
+	"direction":    {"收/支"},
+	"amount":       {"金额", "金额（元）", "金额(元)"},
+	"status":       {"交易状态"},
+	"trade_no":     {"交易订单号", "交易号"},
+	"note":         {"备注"},
+}
+
+// 微信支付账单的列
+var wechatColumns = map[string][]string{
+	"time":         {"交易时间"},
+	"kind":         {"交易类型"},
+	"counterparty": {"交易对方"},
+	"goods":        {"商品"},
+	"direction":    {"收/支"},
+	"amount":       {"金额(元)", "金额（元）", "金额"},
+	"status":       {"当前状态"},
+	"trade_no":     {"交易单号"},
+	"note":         {"备注"},
+}
+
+// 表示交易未完成（关闭、失败、待付款等）的状态关键字，这些明细不导入
+var unfinishedStatusKeywords = []string{"关闭", "失败", "撤销", "取消", "等待", "未支付", "未付款"}
+
+// 微信部分退款的状态，如 "已退款(￥5.00)"
+var wechatRefundPattern = regexp.MustCompile(`已退款\s*[(（]?\s*[¥￥]?\s*([\d.,]+)`)
+
+// ImportAlipay 导入支付宝账单（accountID 为导入到的账户，0 表示不指定）
+func (s *ImportService) ImportAlipay(actor Actor, r io.Reader, accountID int64, dryRun bool) (*models.ImportResult, error) {
+	batch, err := parseAlipayRecords(r, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// ImportWechat 导入微信支付账单（accountID 为导入到的账户，0 表示不指定）
+func (s *ImportService) ImportWechat(actor Actor, r io.Reader, accountID int64, dryRun bool) (*models.ImportResult, error) {
+	batch, err := parseWechatRecords(r, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// parseAlipayRecords 解析支付宝账单：
+//   - "不计收支"（余额宝转入转出、信用卡还款、不计收支的退款等）不是收支，跳过
+//   - 交易关闭、失败、待付款的明细跳过
+//   - 交易分类作为类别，交易对方与商品说明写入备注
+func parseAlipayRecords(r io.Reader, accountID int64) (*importBatch, error) {
+	table, err := openStatementTable(r, alipayColumns, "time", "direction", "amount", "trade_no")
+	if err != nil {
+		return nil, err
+	}
+	batch := &importBatch{}
+	for {
+		row, line, err := table.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: "CSV 格式错误"})
+			continue
+		}
+		get := func(field string) string { return table.get(row, field) }
+		if get("time") == "" && get("trade_no") == "" {
+			// 明细之后的统计说明行
+			continue
+		}
+		if len(batch.records)+len(batch.errors)+batch.skipped >= maxImportRows {
+			return nil, utils.ErrImportTooManyRows
+		}
+
+		transactionType := paymentDirectionType(get("direction"))
+		if transactionType == "" || isUnfinishedStatus(get("status")) {
+			batch.skipped++
+			continue
+		}
+		input := RecordTransactionInput{
+			Type:       transactionType,
+			Amount:     get("amount"),
+			Category:   get("category"),
+			Note:       joinStatementNote(get("counterparty"), get("goods"), get("note")),
+			Payee:      joinStatementNote(get("counterparty")), // "/" 表示没有交易对方
+			OccurredAt: get("time"),
+			AccountID:  accountID,
+		}
+		if tradeNo := get("trade_no"); tradeNo != "" {
+			input.ExternalID = "alipay:" + tradeNo
+		}
+		appendPaymentRecord(batch, line, input)
+	}
+	return batch, nil
+}
+
+// parseWechatRecords 解析微信支付账单：
+//   - 收/支为 "/" 的明细（零钱提现、转入零钱通等）不是收支，跳过
+//   - 退款以原交易的状态为准："已全额退款" 的原交易跳过，"已退款(￥x)" 的原交易按扣除退款后的金额记录，
+//     单独列出的退款明细（交易类型含"退款"）跳过，避免重复计算
+//   - 交易对方与商品写入备注
+func parseWechatRecords(r io.Reader, accountID int64) (*importBatch, error) {
+	table, err := openStatementTable(r, wechatColumns, "time", "direction", "amount", "trade_no")
+	if err != nil {
+		return nil, err
+	}
+	batch := &importBatch{}
+	for {
+		row, line, err := table.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: "CSV 格式错误"})
+			continue
+		}
+		get := func(field string) string { return table.get(row, field) }
+		if get("time") == "" && get("trade_no") == "" {
+			continue
+		}
+		if len(batch.records)+len(batch.errors)+batch.skipped >= maxImportRows {
+			return nil, utils.ErrImportTooManyRows
+		}
+
+		status := get("status")
+		transactionType := paymentDirectionType(get("direction"))
+		if transactionType == "" || isUnfinishedStatus(status) ||
+			strings.Contains(get("kind"), "退款") || strings.Contains(status, "全额退款") {
+			batch.skipped++
+			continue
+		}
+
+		amount := get("amount")
+		if m := wechatRefundPattern.FindStringSubmatch(status); m != nil {
+			cents, err := utils.ParseToCents(amount)
+			if err != nil {
+				batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: errorMessage(err)})
+				continue
+			}
+			refunded, err := utils.ParseToCents(m[1])
+			if err != nil {
+				batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: errorMessage(err)})
+				continue
+			}
+			if cents <= refunded {
+				batch.skipped++
+				continue
+			}
+			amount = utils.CentsToYuanString(cents - refunded)
+		}
+
+		input := RecordTransactionInput{
+			Type:       transactionType,
+			Amount:     amount,
+			Note:       joinStatementNote(get("counterparty"), get("goods"), get("note")),
+			Payee:      joinStatementNote(get("counterparty")), // "/" 表示没有交易对方
+			OccurredAt: get("time"),
+			AccountID:  accountID,
+		}
+		if tradeNo := get("trade_no"); tradeNo != "" {
+			input.ExternalID = "wechat:" + tradeNo
+		}
+		appendPaymentRecord(batch, line, input)
+	}
+	return batch, nil
+}
+
+// appendPaymentRecord 校验金额后加入待导入记录
+func appendPaymentRecord(batch *importBatch, line int, input RecordTransactionInput) {
+	cents, err := utils.ParseToCents(input.Amount)
+	if err != nil {
+		batch.errors = append(batch.errors, models.ImportRowError{Line: line, Error: errorMessage(err)})
+		return
+	}
+	if cents == 0 {
+		batch.skipped++
+		return
+	}
+	input.Amount = utils.CentsToYuanString(cents)
+	batch.records = append(batch.records, importRecord{Line: line, Input: input})
+}
+
+// paymentDirectionType 把 收/支 列转换为账单类型，"不计收支"、"/" 等返回空字符串
+func paymentDirectionType(direction string) string {
+	switch direction {
+	case "收入":
+		return "income"
+	case "支出":
+		return "expense"
+	}
+	return ""
+}
+
+func isUnfinishedStatus(status string) bool {
+	for _, keyword := range unfinishedStatusKeywords {
+		if strings.Contains(status, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinStatementNote 用 " - " 连接非空的备注片段（"/" 视为空）
+func joinStatementNote(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" && p != "/" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, " - ")
+}
+
+// statementTable 账单文件中表头之后的明细表
+type statementTable struct {
+	reader     *csv.Reader
+	lineOffset int            // 表头所在的行号，明细行号 = lineOffset + 在明细中的行号
+	columns    map[string]int // 字段 -> 列下标
+}
+
+// openStatementTable 读取账单文件（自动识别 UTF-8 / GBK），找到包含 required 字段的表头行
+func openStatementTable(r io.Reader, columns map[string][]string, required ...string) (*statementTable, error) {
+	text, err := decodeStatementText(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		header, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			continue
+		}
+		found := make(map[string]int)
+		for field, names := range columns {
+			for _, name := range names {
+				if idx := findCSVHeader(header, name); idx >= 0 {
+					found[field] = idx
+					break
+				}
+			}
+		}
+		complete := true
+		for _, field := range required {
+			if _, ok := found[field]; !ok {
+				complete = false
+				break
+			}
+		}
+		if !complete {
+			continue
+		}
+		reader := csv.NewReader(strings.NewReader(strings.Join(lines[i+1:], "\n")))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		return &statementTable{reader: reader, lineOffset: i + 1, columns: found}, nil
+	}
+	return nil, utils.ErrImportNoColumn
+}
+
+// next 读取下一行明细，返回源文件中的行号；读完返回 io.EOF
+func (t *statementTable) next() ([]string, int, error) {
+	row, err := t.reader.Read()
+	if err == io.EOF {
+		return nil, 0, err
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, t.lineOffset + parseErr.StartLine, err
+		}
+		return nil, 0, err
+	}
+	line, _ := t.reader.FieldPos(0)
+	return row, t.lineOffset + line, nil
+}
+
+// get 返回字段对应单元格的值，去掉首尾空白与制表符（交易号后常带 \t）以及金额前的货币符号
+func (t *statementTable) get(row []string, field string) string {
+	idx, ok := t.columns[field]
+	if !ok || idx >= len(row) {
+		return ""
+	}
+	value := strings.TrimSpace(row[idx])
+	if field == "amount" {
+		value = strings.TrimLeft(value, "¥￥")
+	}
+	return value
+}
+
+// decodeStatementText 读取整个文件；不是合法 UTF-8 时按 GB18030（兼容 GBK）解码，并去掉 BOM
+func decodeStatementText(r io.Reader) (string, error) {
+	data, err := readImportFile(r)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		data, err = simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", utils.WrapError(utils.ErrImportInvalidFile, err)
+		}
+	}
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"AccountingAssistant/utils"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestParseAlipayRecords(t *testing.T) {
+	text := "------------------------------------------------------------------------------------\n" +
+		"导出信息：\n" +
+		"姓名：张三\n" +
+		"支付宝账户：zhangsan@example.com\n" +
+		"------------------------支付宝（中国）网络技术有限公司  电子客户回单------------------------\n" +
+		"交易时间,交易分类,交易对方,对方账号,商品说明,收/支,金额,收/付款方式,交易状态,交易订单号,商家订单号,备注,\n" +
+		"2024-01-05 12:30:11,餐饮美食,某某餐厅,res***@163.com,午餐,支出,25.00,花呗,交易成功,2024010522001400001\t,T001\t,,\n" +
+		"2024-01-06 09:00:00,转账红包,李四,li***@qq.com,转账,收入,100.00,,交易成功,2024010622001400002\t,,,\n" +
+		"2024-01-06 10:00:00,投资理财,余额宝,,转入,不计收支,500.00,余额,交易成功,2024010622001400003\t,,,\n" +
+		"2024-01-07 18:00:00,日用百货,某超市,,日用品,支出,30.00,余额,交易关闭,2024010722001400004\t,,,\n" +
+		"2024-01-08 18:00:00,日用百货,某超市,,日用品,支出,abc,余额,交易成功,2024010822001400005\t,,,\n"
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := parseAlipayRecords(strings.NewReader(gbk), 3)
+	if err != nil {
+		t.Fatalf("parseAlipayRecords returned error: %v", err)
+	}
+	if len(batch.records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(batch.records), batch.records)
+	}
+	first := batch.records[0].Input
+	if batch.records[0].Line != 7 || first.Type != "expense" || first.Amount != "+25.00" || first.Category != "餐饮美食" ||
+		first.Note != "某某餐厅 - 午餐" || first.OccurredAt != "2024-01-05 12:30:11" ||
+		first.ExternalID != "alipay:2024010522001400001" || first.AccountID != 3 {
+		t.Errorf("unexpected first record: %+v", batch.records[0])
+	}
+	if second := batch.records[1].Input; second.Type != "income" || second.Amount != "+100.00" {
+		t.Errorf("unexpected second record: %+v", second)
+	}
+	if batch.skipped != 2 {
+		t.Errorf("skipped = %d, want 2", batch.skipped)
+	}
+	if len(batch.errors) != 1 || batch.errors[0].Line != 11 {
+		t.Errorf("unexpected errors: %+v", batch.errors)
+	}
+}
+
+func TestParseWechatRecords(t *testing.T) {
+	text := "\uFEFF微信支付账单明细,,,,,,,,,,\n" +
+		"微信昵称：[张三],,,,,,,,,,\n" +
+		"----------------------微信支付账单明细列表--------------------,,,,,,,,,,\n" +
+		"交易时间,交易类型,交易对方,商品,收/支,金额(元),支付方式,当前状态,交易单号,商户单号,备注\n" +
+		"2024-01-05 12:30:11,商户消费,某奶茶店,奶茶,支出,¥18.00,零钱,支付成功,4200001\t,M1\t,/\n" +
+		"2024-01-05 13:00:00,商户消费,某商城,耳机,支出,¥100.00,零钱,已退款(￥40.00),4200002\t,M2\t,/\n" +
+		"2024-01-05 14:00:00,商户消费,某商城,鞋,支出,¥200.00,零钱,已全额退款,4200003\t,M3\t,/\n" +
+		"2024-01-06 09:00:00,某商城-退款,某商城,/,收入,¥40.00,零钱,已退款,4200004\t,M4\t,/\n" +
+		"2024-01-06 10:00:00,零钱提现,招商银行,/,/,¥50.00,招商银行,提现已到账,4200005\t,/,/\n" +
+		"2024-01-07 10:00:00,微信红包,王五,/,收入,¥8.88,/,已存入零钱,4200006\t,/,/\n"
+
+	batch, err := parseWechatRecords(strings.NewReader(text), 0)
+	if err != nil {
+		t.Fatalf("parseWechatRecords returned error: %v", err)
+	}
+	want := []struct{ typ, amount, note, id string }{
+		{"expense", "+18.00", "某奶茶店 - 奶茶", "wechat:4200001"},
+		{"expense", "+60.00", "某商城 - 耳机", "wechat:4200002"},
+		{"income", "+8.88", "王五", "wechat:4200006"},
+	}
+	if len(batch.records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(batch.records), len(want), batch.records)
+	}
+	for i, w := range want {
+		got := batch.records[i].Input
+		if got.Type != w.typ || got.Amount != w.amount || got.Note != w.note || got.ExternalID != w.id {
+			t.Errorf("record %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if batch.skipped != 3 || len(batch.errors) != 0 {
+		t.Errorf("skipped = %d, errors = %+v", batch.skipped, batch.errors)
+	}
+}
+
+func TestDecodeStatementTextTooLarge(t *testing.T) {
+	if _, err := decodeStatementText(io.LimitReader(zeroReader{}, MaxImportFileSize+1)); !errors.Is(err, utils.ErrImportFileTooLarge) {
+		t.Errorf("decodeStatementText error = %v, want %v", err, utils.ErrImportFileTooLarge)
+	}
+	if _, err := decodeOFXText(io.LimitReader(zeroReader{}, MaxImportFileSize+1)); !errors.Is(err, utils.ErrImportFileTooLarge) {
+		t.Errorf("decodeOFXText error = %v, want %v", err, utils.ErrImportFileTooLarge)
+	}
+	if _, err := decodeStatementText(io.LimitReader(zeroReader{}, MaxImportFileSize)); err != nil {
+		t.Errorf("file of exactly MaxImportFileSize bytes should be accepted, got %v", err)
+	}
+}
+
+// zeroReader 无限输出 '0'，用于构造大文件
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '0'
+	}
+	return len(p), nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QIF 银行账单导入
+// QIF 是纯文本格式：以 "!Type:Bank" 等行开始一段明细，每行第一个字符表示字段（D 日期、T 金额、
+// P 收付款方、M 备注、L 类别、N 支票号），"^" 结束一条记录；"!Account" 段声明之后明细所属的账户。
+// QIF 没有交易编号，按 账户、日期、金额、收付款方、备注、支票号 以及同样内容在文件中第几次出现
+// 生成 ExternalID，因此重复导入同一份（或有重叠的）账单是安全的。
+
+// 包含收支明细的 QIF 段（投资、类别列表、记忆交易等段落会被忽略）
+var qifTransactionTypes = map[string]bool{
+	"bank": true, "cash": true, "ccard": true, "oth a": true, "oth l": true,
+}
+
+// qifRecord QIF 中的一条记录
+type qifRecord struct {
+	line   int
+	fields map[byte]string // 每种字段只取第一次出现的值（拆分明细 S/E/$ 被忽略）
+}
+
+// ImportQIF 导入 QIF 文件
+func (s *ImportService) ImportQIF(actor Actor, r io.Reader, options BankImportOptions, dryRun bool) (*models.ImportResult, error) {
+	batch, err := parseQIFRecords(r, options)
+	if err != nil {
+		return nil, err
+	}
+	return s.runImport(actor, batch, dryRun)
+}
+
+// parseQIFRecords 解析 QIF 明细：金额带符号（负数为支出），L 作为类别（"[账户名]" 表示转账，不设类别），
+// P、M 写入备注
+func parseQIFRecords(r io.Reader, options BankImportOptions) (*importBatch, error) {
+	switch options.DateOrder {
+	case "":
+		options.DateOrder = "mdy"
+	case "mdy", "dmy", "ymd":
+	default:
+		return nil, utils.ErrInvalidParameter
+	}
+	text, err := decodeStatementText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &importBatch{}
+	section := "" // 当前段落：transaction、account 或空（忽略）
+	account := "" // 当前明细所属的账户名
+	seen := make(map[string]int)
+	var record *qifRecord
+	foundSection := false
+
+	flush := func() error {
+		rec := record
+		record = nil
+		if rec == nil || len(rec.fields) == 0 {
+			return nil
+		}
+		if section == "account" {
+			if name, ok := rec.fields['N']; ok {
+				account = name
+			}
+			return nil
+		}
+		if section != "transaction" {
+			return nil
+		}
+		if len(batch.records)+len(batch.errors)+batch.skipped >= maxImportRows {
+			return utils.ErrImportTooManyRows
+		}
+		input, cents, err := qifRecordInput(rec, options.DateOrder)
+		if err != nil {
+			batch.errors = append(batch.errors, models.ImportRowError{Line: rec.line, Error: errorMessage(err)})
+			return nil
+		}
+		if cents == 0 {
+			batch.skipped++
+			return nil
+		}
+		input.AccountID = options.targetAccount(account)
+		key := qifRecordKey(account, rec)
+		seen[key]++
+		input.ExternalID = fmt.Sprintf("qif:%s#%d", key, seen[key])
+		batch.records = append(batch.records, importRecord{Line: rec.line, Input: input})
+		return nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		if raw == "" {
+			continue
+		}
+		if raw[0] == '!' {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			header := strings.ToLower(strings.TrimSpace(raw[1:]))
+			switch {
+			case header == "account":
+				section = "account"
+			case strings.HasPrefix(header, "type:"):
+				section = ""
+				if qifTransactionTypes[strings.TrimSpace(strings.TrimPrefix(header, "type:"))] {
+					section = "transaction"
+					foundSection = true
+				}
+			case strings.HasPrefix(header, "option:") || strings.HasPrefix(header, "clear:"):
+				// 不影响后续段落
+			default:
+				section = ""
+			}
+			continue
+		}
+		if raw[0] == '^' {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if record == nil {
+			record = &qifRecord{line: line, fields: make(map[byte]string)}
+		}
+		if _, exists := record.fields[raw[0]]; !exists {
+			record.fields[raw[0]] = strings.TrimSpace(raw[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, utils.WrapError(utils.ErrImportInvalidFile, err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if !foundSection {
+		return nil, utils.ErrImportInvalidFile
+	}
+	return batch, nil
+}
+
+// qifRecordInput 把一条 QIF 记录转换为记账输入，同时返回带符号的金额（分）
+func qifRecordInput(rec *qifRecord, dateOrder string) (RecordTransactionInput, int64, error) {
+	occurredAt, err := parseQIFDate(rec.fields['D'], dateOrder)
+	if err != nil {
+		return RecordTransactionInput{}, 0, err
+	}
+	amount, ok := rec.fields['T']
+	if !ok {
+		amount = rec.fields['U']
+	}
+	cents, err := utils.ParseSignedToCents(amount)
+	if err != nil {
+		return RecordTransactionInput{}, 0, err
+	}
+
+	input := RecordTransactionInput{
+		Type:       "income",
+		Amount:     utils.CentsToYuanString(abs(cents)),
+		Note:       joinStatementNote(rec.fields['P'], rec.fields['M']),
+		Payee:      rec.fields['P'],
+		OccurredAt: occurredAt,
+	}
+	if cents < 0 {
+		input.Type = "expense"
+	}
+	category := rec.fields['L']
+	if i := strings.IndexByte(category, '/'); i >= 0 {
+		category = category[:i] // "类别/类" 中的类（class）不使用
+	}
+	if !strings.HasPrefix(category, "[") {
+		input.Category = strings.TrimSpace(category)
+	}
+	return input, cents, nil
+}
+
+// qifRecordKey 用于去重的记录内容摘要
+func qifRecordKey(account string, rec *qifRecord) string {
+	sum := sha1.Sum([]byte(strings.Join([]string{
+		account, rec.fields['D'], rec.fields['T'], rec.fields['U'], rec.fields['P'], rec.fields['M'], rec.fields['N'],
+	}, "\x00")))
+	return hex.EncodeToString(sum[:10])
+}
+
+// parseQIFDate 解析 QIF 日期，如 "01/05/2024"、"1/5'24"、" 1/ 5/24"、"2024-01-05"。
+// 两位年份小于 70 时为 20xx；四位数开头的日期总是按 年-月-日 解析。
+func parseQIFDate(str, dateOrder string) (string, error) {
+	s := strings.NewReplacer("'", "/", "-", "/", ".", "/", " ", "").Replace(strings.TrimSpace(str))
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return "", utils.ErrInvalidDate
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return "", utils.ErrInvalidDate
+		}
+		nums[i] = n
+	}
+
+	var year, month, day int
+	switch {
+	case len(parts[0]) == 4 || dateOrder == "ymd":
+		year, month, day = nums[0], nums[1], nums[2]
+	case dateOrder == "dmy":
+		day, month, year = nums[0], nums[1], nums[2]
+	default:
+		month, day, year = nums[0], nums[1], nums[2]
+	}
+	if year < 100 {
+		if year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return "", utils.ErrInvalidDate
+	}
+	return t.Format(utils.DateLayout), nil
+}
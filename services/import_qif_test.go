@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQIFRecords(t *testing.T) {
+	data := "!Account\nNChecking\nTBank\n^\n" +
+		"!Type:Bank\n" +
+		"D01/05'24\nT-1,234.50\nPLandlord\nMRent\nLHousing:Rent\n^\n" +
+		"D1/10/24\nT500.00\nPEmployer\nLSalary/Work\n^\n" +
+		"D1/10/24\nT500.00\nPEmployer\nLSalary/Work\n^\n" +
+		"D1/11/24\nT-50\nL[Savings]\n^\n" +
+		"D13/45/24\nT-5\n^\n" +
+		"!Type:Cat\nNFood\n^\n"
+
+	batch, err := parseQIFRecords(strings.NewReader(data), BankImportOptions{AccountMap: map[string]int64{"Checking": 2}})
+	if err != nil {
+		t.Fatalf("parseQIFRecords returned error: %v", err)
+	}
+	if len(batch.records) != 4 {
+		t.Fatalf("got %d records, want 4: %+v", len(batch.records), batch.records)
+	}
+	first := batch.records[0]
+	if first.Line != 6 || first.Input.Type != "expense" || first.Input.Amount != "+1234.50" || first.Input.OccurredAt != "2024-01-05" ||
+		first.Input.Category != "Housing:Rent" || first.Input.Note != "Landlord - Rent" || first.Input.AccountID != 2 {
+		t.Errorf("unexpected first record: %+v", first)
+	}
+	second, third := batch.records[1].Input, batch.records[2].Input
+	if second.Type != "income" || second.Category != "Salary" || second.ExternalID == third.ExternalID {
+		t.Errorf("identical records should get distinct external ids: %+v %+v", second, third)
+	}
+	if transfer := batch.records[3].Input; transfer.Category != "" {
+		t.Errorf("transfer category should be empty, got %q", transfer.Category)
+	}
+	if len(batch.errors) != 1 || batch.errors[0].Line != 26 {
+		t.Errorf("unexpected errors: %+v", batch.errors)
+	}
+
+	again, err := parseQIFRecords(strings.NewReader(data), BankImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.records[0].Input.ExternalID != first.Input.ExternalID {
+		t.Errorf("external id should be stable across imports")
+	}
+}
+
+func TestParseQIFDate(t *testing.T) {
+	cases := []struct{ in, order, want string }{
+		{"01/05/2024", "mdy", "2024-01-05"},
+		{" 1/ 5'24", "mdy", "2024-01-05"},
+		{"05.01.2024", "dmy", "2024-01-05"},
+		{"2024-01-05", "dmy", "2024-01-05"},
+		{"12/31/99", "mdy", "1999-12-31"},
+	}
+	for _, c := range cases {
+		got, err := parseQIFDate(c.in, c.order)
+		if err != nil || got != c.want {
+			t.Errorf("parseQIFDate(%q, %q) = %q, %v; want %q", c.in, c.order, got, err, c.want)
+		}
+	}
+	if _, err := parseQIFDate("2/30/24", "mdy"); err == nil {
+		t.Errorf("expected error for invalid date")
+	}
+}
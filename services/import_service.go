@@ -0,0 +1,218 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 导入服务：各种格式的解析器把文件转换为 importRecord，再统一走记账流程写入
+type ImportService struct {
+	masterDB *sql.DB
+}
+
+// 新建导入服务的方法
+func NewImportService(masterDB *sql.DB) *ImportService {
+	return &ImportService{masterDB: masterDB}
+}
+
+const (
+	MaxImportFileSize = 20 << 20 // 导入文件的最大字节数
+	maxImportRows     = 10000    // 单次导入最多的记录数
+)
+
+// readImportFile 读取整个导入文件（最多读取 MaxImportFileSize+1 字节以判断是否过大）
+func readImportFile(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImportFileSize+1))
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrImportInvalidFile, err)
+	}
+	if len(data) > MaxImportFileSize {
+		return nil, utils.ErrImportFileTooLarge
+	}
+	return data, nil
+}
+
+// importRecord 解析出的一条待导入记录
+type importRecord struct {
+	Line  int // 源文件中的行号，用于报告错误
+	Input RecordTransactionInput
+}
+
+// importBatch 解析器的输出：可导入的记录、解析失败的行，以及按规则跳过的行数
+type importBatch struct {
+	records []importRecord
+	errors  []models.ImportRowError
+	skipped int
+}
+
+// BankImportOptions 银行账单（OFX/QFX、QIF）导入选项
+type BankImportOptions struct {
+	AccountID  int64            // 默认导入到的账户，0 表示不指定
+	AccountMap map[string]int64 // 账单中的账号（OFX 的 ACCTID、QIF 的账户名）-> 账户 id，优先于 AccountID
+	DateOrder  string           // 仅 QIF：日期的顺序 "mdy"（默认）、"dmy" 或 "ymd"
+}
+
+// targetAccount 返回账单中某个账号对应的账户 id
+func (o BankImportOptions) targetAccount(statementAccount string) int64 {
+	if id, ok := o.AccountMap[statementAccount]; ok {
+		return id
+	}
+	return o.AccountID
+}
+
+// ParseAccountMap 解析账号映射，格式为 "账单账号=账户id"，多项用逗号分隔，如 "6222001234=2,4033=3"
+func ParseAccountMap(str string) (map[string]int64, error) {
+	mapping := make(map[string]int64)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, utils.ErrInvalidParameter
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || id < 0 || strings.TrimSpace(key) == "" {
+			return nil, utils.ErrInvalidParameter
+		}
+		mapping[strings.TrimSpace(key)] = id
+	}
+	return mapping, nil
+}
+
+// runImport 在一个事务中逐条记录账单（与手工记账相同的校验、类别自动创建与自动分类规则，转账同样校验两个账户）。
+// 带 ExternalID 的记录已导入过（或在本文件中重复）时跳过，因此重复导入有重叠的账单是安全的。
+// 任意一条出错时整体回滚并返回 ErrImportInvalidRows；dryRun 时总是回滚，只返回校验结果与预览。
+// 每条导入的账单与自动创建的类别都以 actor 的身份记入变更历史。
+func (s *ImportService) runImport(actor Actor, batch *importBatch, dryRun bool) (*models.ImportResult, error) {
+	result := &models.ImportResult{
+		DryRun:  dryRun,
+		Total:   len(batch.records) + len(batch.errors) + batch.skipped,
+		Skipped: batch.skipped,
+		Errors:  append([]models.ImportRowError{}, batch.errors...),
+	}
+	if result.Total > maxImportRows {
+		return nil, utils.ErrImportTooManyRows
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有类别的收支记录按自动分类规则归类
+	rules, err := database.GetCategoryRules(tx, true)
+	if err != nil {
+		return nil, err
+	}
+	matchers := compileCategoryRules(rules)
+
+	seen := make(map[string]bool)
+	for _, rec := range batch.records {
+		if id := rec.Input.ExternalID; id != "" {
+			exists, err := database.ExternalIDExists(tx, id)
+			if err != nil {
+				return nil, err
+			}
+			if exists || seen[id] {
+				result.Skipped++
+				continue
+			}
+			seen[id] = true
+		}
+		record := recordTransaction
+		if rec.Input.Type == "transfer" {
+			record = recordTransfer
+		}
+		rec.Input.SkipCategoryKindCheck = true
+		if rec.Input.Type == "transfer" {
+			rec.Input.Payee = "" // 转账没有商户（银行账单中的转账也带有交易对方）
+		}
+		payee, err := applyPayee(tx, &rec.Input)
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportRowError{Line: rec.Line, Error: errorMessage(err)})
+			continue
+		}
+		if payee != nil {
+			rec.Input.Payee = payee.Name // 预览中显示识别出的商户与其默认类别
+			if rec.Input.Category == "" && rec.Input.CategoryID == payee.DefaultCategoryID && payee.DefaultCategoryID != 0 {
+				rec.Input.Category = payee.DefaultCategoryName
+			}
+		}
+		if rule, _ := applyCategoryRules(tx, &rec.Input, matchers); rule != nil {
+			rec.Input.Category = rule.CategoryName // 预览中显示规则给出的类别
+		}
+		transactionID, err := record(tx, rec.Input)
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportRowError{Line: rec.Line, Error: errorMessage(err)})
+			continue
+		}
+		if err := recordTransactionHistory(tx, actor, historyActionCreate, transactionID, nil); err != nil {
+			return nil, err
+		}
+		result.Imported++
+		if dryRun {
+			result.Preview = append(result.Preview, previewRow(rec))
+		}
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+
+	if dryRun {
+		return result, nil
+	}
+	if len(result.Errors) > 0 {
+		result.Imported = 0
+		return result, utils.ErrImportInvalidRows
+	}
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return result, nil
+}
+
+// previewRow 把一条已通过校验的记录转换为预览（金额带符号、时间为存储格式）
+func previewRow(rec importRecord) models.ImportPreviewRow {
+	cents, _ := utils.ParseToCents(rec.Input.Amount)
+	occurredAt, _ := parseOccurredAt(rec.Input.OccurredAt)
+	return models.ImportPreviewRow{
+		Line:       rec.Line,
+		Type:       rec.Input.Type,
+		Amount:     utils.CentsToYuanString(signedAmount(rec.Input.Type, cents)),
+		Category:   rec.Input.Category,
+		Payee:      rec.Input.Payee,
+		Note:       rec.Input.Note,
+		OccurredAt: occurredAt,
+	}
+}
+
+// errorMessage 返回面向用户的错误信息（业务错误只取消息，不暴露底层细节）
+func errorMessage(err error) string {
+	var appErr *utils.Error
+	if errors.As(err, &appErr) {
+		return appErr.Message
+	}
+	return err.Error()
+}
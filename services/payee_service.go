@@ -0,0 +1,271 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// 商户服务
+// 商户是账单的交易对方（如 "星巴克"），可以有多个别名，名称与别名忽略大小写后在用户内不重复。
+// 记账时按名称或别名识别商户，不存在时自动创建；没有给出类别时使用商户的默认类别，其次才是自动分类规则。
+type PayeeService struct {
+	masterDB *sql.DB
+}
+
+// 新建商户服务的方法
+func NewPayeeService(masterDB *sql.DB) *PayeeService {
+	return &PayeeService{masterDB: masterDB}
+}
+
+const (
+	defaultPayeeLimit = 20  // 商户列表（自动补全）默认返回的数量
+	maxPayeeLimit     = 200 // 商户列表最多返回的数量
+)
+
+// PayeeInput "新建商户"的输入
+type PayeeInput struct {
+	Name              string
+	Aliases           []string
+	DefaultCategoryID int64 // 0 表示没有默认类别
+}
+
+// UpdatePayeeInput "修改商户"的输入，nil 表示不更新该字段
+type UpdatePayeeInput struct {
+	Name              *string
+	Aliases           *[]string // 替换全部别名，空列表表示清空
+	DefaultCategoryID *int64    // 0 表示清空默认类别
+}
+
+// 新建商户服务
+func (s *PayeeService) CreatePayee(userID int64, input PayeeInput) (int64, error) {
+	name, aliases, err := cleanPayeeNames(input.Name, input.Aliases)
+	if err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+
+	if err := checkPayeeDefaultCategory(userDB, input.DefaultCategoryID); err != nil {
+		return 0, err
+	}
+	return database.CreatePayee(userDB, &models.Payee{Name: name, Aliases: aliases, DefaultCategoryID: input.DefaultCategoryID})
+}
+
+// 获取商户服务：prefix 不为空时按名称或别名的前缀查找（用于记账时自动补全），常用的商户排在前面；
+// 否则按名称返回。limit 为 0 时使用默认数量
+func (s *PayeeService) GetPayees(userID int64, prefix string, limit int) ([]models.Payee, error) {
+	if limit < 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+	if limit == 0 {
+		limit = defaultPayeeLimit
+	}
+	limit = min(limit, maxPayeeLimit)
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	payees, err := database.GetPayees(userDB)
+	if err != nil {
+		return nil, err
+	}
+	return matchPayeePrefix(payees, prefix, limit), nil
+}
+
+// 获取单个商户服务
+func (s *PayeeService) GetPayee(userID int64, payeeID int64) (*models.Payee, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetPayeeByID(userDB, payeeID)
+}
+
+// 修改商户服务
+func (s *PayeeService) UpdatePayee(userID int64, payeeID int64, input UpdatePayeeInput) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	payee, err := database.GetPayeeByID(userDB, payeeID)
+	if err != nil {
+		return err
+	}
+	name, aliases := payee.Name, payee.Aliases
+	if input.Name != nil {
+		name = *input.Name
+	}
+	if input.Aliases != nil {
+		aliases = *input.Aliases
+	}
+	if payee.Name, payee.Aliases, err = cleanPayeeNames(name, aliases); err != nil {
+		return err
+	}
+	if input.DefaultCategoryID != nil {
+		if err := checkPayeeDefaultCategory(userDB, *input.DefaultCategoryID); err != nil {
+			return err
+		}
+		payee.DefaultCategoryID = *input.DefaultCategoryID
+	}
+	return database.UpdatePayee(userDB, payee)
+}
+
+// 删除商户服务：账单保留但不再关联商户，以该商户为条件的自动分类规则一并删除
+func (s *PayeeService) DeletePayee(userID int64, payeeID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeletePayee(userDB, payeeID)
+}
+
+// 合并商户服务：把 sourceIDs 商户的账单、规则转到 targetID 商户，来源商户的名称与别名成为目标商户的别名
+func (s *PayeeService) MergePayees(userID int64, sourceIDs []int64, targetID int64) (*models.PayeeMergeResult, error) {
+	if len(sourceIDs) == 0 || targetID == 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	if _, err := database.GetPayeeByID(userDB, targetID); err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	var sources []int64
+	for _, id := range sourceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == targetID {
+			return nil, utils.ErrInvalidParameter
+		}
+		if _, err := database.GetPayeeByID(userDB, id); err != nil {
+			return nil, err
+		}
+		sources = append(sources, id)
+	}
+	return database.MergePayees(userDB, sources, targetID)
+}
+
+// cleanPayeeNames 规范化商户名称与别名的空白：名称不能为空；空别名、与名称或其他别名重复（忽略大小写）的别名被去掉
+func cleanPayeeNames(name string, aliases []string) (string, []string, error) {
+	name = cleanCategoryName(name)
+	if name == "" {
+		return "", nil, utils.ErrInvalidParameter
+	}
+	seen := map[string]bool{strings.ToLower(name): true}
+	cleaned := []string{}
+	for _, alias := range aliases {
+		alias = cleanCategoryName(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		cleaned = append(cleaned, alias)
+	}
+	return name, cleaned, nil
+}
+
+// checkPayeeDefaultCategory 校验默认类别存在（0 表示没有默认类别）
+func checkPayeeDefaultCategory(db database.DBTX, categoryID int64) error {
+	if categoryID == 0 {
+		return nil
+	}
+	category, err := database.GetCategoryByID(db, categoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		return utils.ErrCategoryNotFound
+	}
+	return nil
+}
+
+// matchPayeePrefix 返回名称或任一别名以 prefix 开头（忽略大小写与多余空白）的商户，
+// 按账单数从多到少、名称排序，最多 limit 个；prefix 为空时保持原顺序返回前 limit 个
+func matchPayeePrefix(payees []models.Payee, prefix string, limit int) []models.Payee {
+	key := strings.ToLower(cleanCategoryName(prefix))
+	matched := []models.Payee{}
+	for _, p := range payees {
+		if key == "" {
+			matched = append(matched, p)
+			continue
+		}
+		for _, name := range append([]string{p.Name}, p.Aliases...) {
+			if strings.HasPrefix(strings.ToLower(name), key) {
+				matched = append(matched, p)
+				break
+			}
+		}
+	}
+	if key != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if matched[i].TransactionCount != matched[j].TransactionCount {
+				return matched[i].TransactionCount > matched[j].TransactionCount
+			}
+			return matched[i].Name < matched[j].Name
+		})
+	}
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+// applyPayee 按名称或别名识别收支账单的商户（不存在时创建）并设置 PayeeID；
+// 账单没有给出类别与拆分时使用商户的默认类别（默认类别的收支类型与账单不一致时不使用）。
+// 返回账单的商户，没有商户时为 nil
+func applyPayee(db database.DBTX, input *RecordTransactionInput) (*models.Payee, error) {
+	if input.Type != "income" && input.Type != "expense" {
+		return nil, nil
+	}
+	if input.PayeeID == 0 {
+		id, err := resolvePayeeID(db, input.Payee)
+		if err != nil || id == 0 {
+			return nil, err
+		}
+		input.PayeeID = id
+	}
+	payee, err := database.GetPayeeByID(db, input.PayeeID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Category == "" && input.CategoryID == 0 && len(input.Splits) == 0 &&
+		payee.DefaultCategoryID != 0 && categoryKindAllows(payee.DefaultCategoryKind, input.Type) {
+		input.CategoryID = payee.DefaultCategoryID
+	}
+	return payee, nil
+}
+
+// resolvePayeeID 按名称或别名查找商户，不存在则创建；空名称返回 0（不关联商户）
+func resolvePayeeID(db database.DBTX, name string) (int64, error) {
+	name = cleanCategoryName(name)
+	if name == "" {
+		return 0, nil
+	}
+	id, err := database.GetPayeeIDByName(db, name)
+	if err != nil || id != 0 {
+		return id, err
+	}
+	return database.CreatePayee(db, &models.Payee{Name: name})
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"testing"
+)
+
+func TestCleanPayeeNames(t *testing.T) {
+	name, aliases, err := cleanPayeeNames("  Star  bucks ", []string{"星巴克", "star bucks", " ", "SBUX", "星巴克 ", "sbux"})
+	if err != nil {
+		t.Fatalf("cleanPayeeNames returned error: %v", err)
+	}
+	if name != "Star bucks" {
+		t.Errorf("name = %q, want %q", name, "Star bucks")
+	}
+	// 与名称重复、空白、彼此重复（忽略大小写）的别名被去掉
+	if len(aliases) != 2 || aliases[0] != "星巴克" || aliases[1] != "SBUX" {
+		t.Errorf("aliases = %q, want [星巴克 SBUX]", aliases)
+	}
+
+	if _, _, err := cleanPayeeNames(" \t ", nil); err == nil {
+		t.Error("cleanPayeeNames with blank name returned no error")
+	}
+	if _, aliases, _ := cleanPayeeNames("a", nil); aliases == nil {
+		t.Error("aliases = nil, want empty list")
+	}
+}
+
+func TestMatchPayeePrefix(t *testing.T) {
+	payees := []models.Payee{
+		{ID: 1, Name: "Apple Store", TransactionCount: 2},
+		{ID: 2, Name: "美团外卖", Aliases: []string{"Meituan"}, TransactionCount: 9},
+		{ID: 3, Name: "Amazon", TransactionCount: 2},
+		{ID: 4, Name: "星巴克", Aliases: []string{"Starbucks", "SBUX"}, TransactionCount: 5},
+	}
+	ids := func(list []models.Payee) []int64 {
+		var out []int64
+		for _, p := range list {
+			out = append(out, p.ID)
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   []int64
+	}{
+		{"前缀为空保持原顺序", "", 10, []int64{1, 2, 3, 4}},
+		{"前缀为空受数量限制", "", 2, []int64{1, 2}},
+		{"按名称匹配，同账单数按名称排序", "a", 10, []int64{3, 1}},
+		{"按别名匹配、忽略大小写", "STAR", 10, []int64{4}},
+		{"中文前缀", "美团", 10, []int64{2}},
+		{"账单数多的在前", "s", 10, []int64{4}},
+		{"只匹配前缀", "团", 10, nil},
+		{"多余空白", "  apple   st", 10, []int64{1}},
+		{"数量限制", "a", 1, []int64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(matchPayeePrefix(payees, tt.prefix, tt.limit))
+			if len(got) != len(tt.want) {
+				t.Fatalf("matchPayeePrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("matchPayeePrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+				}
+			}
+		})
+	}
+}
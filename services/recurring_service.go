@@ -0,0 +1,488 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 周期账单服务：管理周期规则，并由后台任务按计划把到期的发生生成为账单
+type RecurringService struct {
+	masterDB *sql.DB
+	runMu    sync.Mutex // 同一进程内同时只做一轮生成
+}
+
+// 新建周期账单服务的方法
+func NewRecurringService(masterDB *sql.DB) *RecurringService {
+	return &RecurringService{masterDB: masterDB}
+}
+
+// 允许的重复频率
+var recurringFrequencies = map[string]bool{
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+}
+
+// 每个事务最多写入的发生次数，停机很久后补生成时分批提交
+const materializeBatchSize = 500
+
+// CreateRecurringRuleInput 新建周期规则的输入（金额、时间均为用户输入的原始字符串）
+type CreateRecurringRuleInput struct {
+	Type      string // "income" 或 "expense"
+	Amount    string
+	Category  string
+	Note      string
+	AccountID int64
+	Frequency string // daily / weekly / monthly / yearly
+	Interval  int    // 0 视为 1
+	StartDate string // 第一次发生的日期或日期时间，空表示当前时间
+	EndDate   string // 最后可发生的日期或日期时间（包含），空表示不限
+	Count     int    // 最多发生次数，0 表示不限
+}
+
+// UpdateRecurringRuleInput 修改周期规则的输入（nil 表示不更新）
+type UpdateRecurringRuleInput struct {
+	Type      *string
+	Amount    *string
+	Category  *string
+	Note      *string
+	AccountID *int64 // 0 表示清空
+	Frequency *string
+	Interval  *int
+	StartDate *string
+	EndDate   *string // 空字符串表示不限
+	Count     *int
+	Active    *bool
+}
+
+// 新建周期规则服务；开始时间已过去的发生会立即补生成（补生成失败时规则仍然创建成功，由后台任务补齐）
+func (s *RecurringService) CreateRule(actor Actor, input CreateRecurringRuleInput) (int64, error) {
+	rule := models.RecurringRule{
+		Type:      input.Type,
+		Note:      input.Note,
+		AccountID: input.AccountID,
+		Frequency: input.Frequency,
+		Interval:  input.Interval,
+		Count:     input.Count,
+		Active:    true,
+	}
+	if rule.Type != "income" && rule.Type != "expense" {
+		return 0, utils.ErrInvalidTransactionType
+	}
+	cents, err := utils.ParseToCents(input.Amount)
+	if err != nil {
+		return 0, err
+	}
+	if cents == 0 {
+		return 0, utils.ErrAmountZero
+	}
+	rule.Amount = signedAmount(rule.Type, cents)
+	if rule.StartDate, err = parseOccurredAt(input.StartDate); err != nil {
+		return 0, err
+	}
+	if rule.EndDate, err = parseRuleEndDate(input.EndDate); err != nil {
+		return 0, err
+	}
+	if err := normalizeRecurringSchedule(&rule); err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return 0, err
+	}
+	if rule.CategoryID, err = resolveCategoryID(tx, input.Category, rule.Type); err != nil {
+		return 0, err
+	}
+	if err := checkCategoryKind(tx, rule.CategoryID, rule.Type); err != nil {
+		return 0, err
+	}
+	if rule.AccountID != 0 {
+		if err := checkAccountUsable(tx, rule.AccountID); err != nil {
+			return 0, err
+		}
+	}
+	rule.NextRun = nextRunString(&rule, 0)
+
+	ruleID, err := database.CreateRecurringRule(tx, &rule)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	s.catchUp(userDB, actor)
+	return ruleID, nil
+}
+
+// 获取周期规则列表服务
+func (s *RecurringService) GetRules(userID int64) ([]models.RecurringRule, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetRecurringRules(userDB)
+}
+
+// 获取单个周期规则服务
+func (s *RecurringService) GetRule(userID int64, ruleID int64) (*models.RecurringRule, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetRecurringRuleByID(userDB, ruleID)
+}
+
+// 修改周期规则服务。已生成的账单不受影响；规则已生成过账单后不能再修改开始时间、频率与间隔
+// （第 n 次发生由它们算出，修改后已生成的次数会对应到新计划中的其他日期），可以修改结束条件后新建规则。
+// 暂停（active=false）后重新启用会补生成暂停期间的发生。
+func (s *RecurringService) UpdateRule(actor Actor, ruleID int64, input UpdateRecurringRuleInput) error {
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return err
+	}
+	rule, err := database.GetRecurringRuleByID(tx, ruleID)
+	if err != nil {
+		return err
+	}
+	startDate, frequency, interval := rule.StartDate, rule.Frequency, rule.Interval
+
+	if input.Type != nil {
+		if *input.Type != "income" && *input.Type != "expense" {
+			return utils.ErrInvalidTransactionType
+		}
+		rule.Type = *input.Type
+		rule.Amount = signedAmount(rule.Type, abs(rule.Amount))
+	}
+	if input.Amount != nil {
+		cents, err := utils.ParseToCents(*input.Amount)
+		if err != nil {
+			return err
+		}
+		if cents == 0 {
+			return utils.ErrAmountZero
+		}
+		rule.Amount = signedAmount(rule.Type, cents)
+	}
+	if input.Category != nil {
+		if rule.CategoryID, err = resolveCategoryID(tx, *input.Category, rule.Type); err != nil {
+			return err
+		}
+	}
+	if input.Category != nil || input.Type != nil {
+		if err := checkCategoryKind(tx, rule.CategoryID, rule.Type); err != nil {
+			return err
+		}
+	}
+	if input.Note != nil {
+		rule.Note = *input.Note
+	}
+	if input.AccountID != nil {
+		if *input.AccountID != 0 {
+			if err := checkAccountUsable(tx, *input.AccountID); err != nil {
+				return err
+			}
+		}
+		rule.AccountID = *input.AccountID
+	}
+	if input.Frequency != nil {
+		rule.Frequency = *input.Frequency
+	}
+	if input.Interval != nil {
+		rule.Interval = *input.Interval
+	}
+	if input.StartDate != nil {
+		if rule.StartDate, err = parseOccurredAt(*input.StartDate); err != nil {
+			return err
+		}
+	}
+	if input.EndDate != nil {
+		if rule.EndDate, err = parseRuleEndDate(*input.EndDate); err != nil {
+			return err
+		}
+	}
+	if input.Count != nil {
+		rule.Count = *input.Count
+	}
+	if input.Active != nil {
+		rule.Active = *input.Active
+	}
+	if err := normalizeRecurringSchedule(rule); err != nil {
+		return err
+	}
+	if rule.Occurrences > 0 && (rule.StartDate != startDate || rule.Frequency != frequency || rule.Interval != interval) {
+		return utils.ErrRecurringScheduleUsed
+	}
+	rule.NextRun = nextRunString(rule, rule.Occurrences)
+
+	if err := database.UpdateRecurringRule(tx, rule); err != nil {
+		return err
+	}
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	s.catchUp(userDB, actor)
+	return nil
+}
+
+// catchUp 规则保存后立即补生成已到期的发生。规则已经提交，这里失败不能再返回错误（客户端重试会重复创建规则），
+// 只记录日志，由后台任务下一轮补齐
+func (s *RecurringService) catchUp(userDB *sql.DB, actor Actor) {
+	if err := s.materializeUser(userDB, actor, time.Now()); err != nil {
+		fmt.Printf("周期账单：用户 %d 生成失败（稍后由后台任务补齐）: %v\n", actor.UserID, err)
+	}
+}
+
+// 删除周期规则服务（已生成的账单保留）
+func (s *RecurringService) DeleteRule(userID int64, ruleID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeleteRecurringRule(userDB, ruleID)
+}
+
+// StartMaterializer 启动后台生成任务：启动时立即处理一次（补上停机期间错过的发生），
+// 之后每隔 interval 处理一次。返回的函数用于停止任务。
+func (s *RecurringService) StartMaterializer(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.MaterializeDue(time.Now())
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// MaterializeDue 为所有用户生成截至 now 已到期的周期账单；单个用户出错不影响其他用户
+func (s *RecurringService) MaterializeDue(now time.Time) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	userIDs, err := database.GetAllUserIDs(s.masterDB)
+	if err != nil {
+		fmt.Printf("周期账单：获取用户列表失败: %v\n", err)
+		return
+	}
+	for _, userID := range userIDs {
+		userDB, err := database.GetUserDB(userID)
+		if err != nil {
+			fmt.Printf("周期账单：打开用户 %d 的数据库失败: %v\n", userID, err)
+			continue
+		}
+		if err := s.materializeUser(userDB, systemActor(userID), now); err != nil {
+			fmt.Printf("周期账单：用户 %d 生成失败: %v\n", userID, err)
+		}
+		userDB.Close()
+	}
+}
+
+// materializeUser 生成一个用户所有到期规则的账单，以 actor 的身份记入变更历史
+func (s *RecurringService) materializeUser(userDB *sql.DB, actor Actor, now time.Time) error {
+	rules, err := database.GetDueRecurringRules(userDB, utils.FormatDateTime(now))
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := materializeRule(userDB, actor, &rules[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializeRule 按已生成次数依次生成到期的发生，每批在一个事务中提交。
+// 每次发生以 (规则 id, 序号) 唯一标识，重复执行或多处同时执行都不会重复记账。
+func materializeRule(userDB *sql.DB, actor Actor, rule *models.RecurringRule, now time.Time) error {
+	n := rule.Occurrences
+	for {
+		var txs []models.Transaction
+		for len(txs) < materializeBatchSize {
+			at, ok := ruleOccurrence(rule, n+len(txs))
+			if !ok || at.After(now) {
+				break
+			}
+			txs = append(txs, models.Transaction{
+				Type:            rule.Type,
+				Amount:          rule.Amount,
+				CategoryID:      rule.CategoryID,
+				AccountID:       rule.AccountID,
+				RecurringRuleID: rule.ID,
+				RecurringSeq:    n + len(txs),
+				Note:            rule.Note,
+				OccurredAt:      utils.FormatDateTime(at),
+			})
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+		if err := materializeBatch(userDB, actor, rule, n, txs); err != nil {
+			return err
+		}
+		n += len(txs)
+	}
+}
+
+// materializeBatch 在一个事务中写入一批发生并为每笔账单记录新建历史
+func materializeBatch(userDB *sql.DB, actor Actor, rule *models.RecurringRule, fromOccurrences int, txs []models.Transaction) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	ids, err := database.MaterializeRecurringRule(tx, rule.ID, fromOccurrences, txs, nextRunString(rule, fromOccurrences+len(txs)))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := recordTransactionHistory(tx, actor, historyActionCreate, id, nil); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return nil
+}
+
+// ruleOccurrence 返回规则第 n 次（从 0 开始）发生的时间；超出次数或结束时间时 ok 为 false
+func ruleOccurrence(rule *models.RecurringRule, n int) (time.Time, bool) {
+	if rule.Count > 0 && n >= rule.Count {
+		return time.Time{}, false
+	}
+	start, err := time.ParseInLocation(utils.DateTimeLayout, rule.StartDate, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	at := occurrenceTime(start, rule.Frequency, rule.Interval, n)
+	if rule.EndDate != "" && utils.FormatDateTime(at) > rule.EndDate {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// nextRunString 返回第 n 次发生的时间（数据库格式），规则已结束时返回空字符串
+func nextRunString(rule *models.RecurringRule, n int) string {
+	at, ok := ruleOccurrence(rule, n)
+	if !ok {
+		return ""
+	}
+	return utils.FormatDateTime(at)
+}
+
+// occurrenceTime 从开始时间起算第 n 次发生的时间。每次都从开始时间计算，避免逐月累加造成日期漂移；
+// 按月/年重复时，遇到当月没有的日期（如 31 日、2 月 29 日）取当月最后一天。
+func occurrenceTime(start time.Time, frequency string, interval int, n int) time.Time {
+	steps := n * interval
+	switch frequency {
+	case "daily":
+		return start.AddDate(0, 0, steps)
+	case "weekly":
+		return start.AddDate(0, 0, 7*steps)
+	case "monthly":
+		return addMonthsClamped(start, steps)
+	case "yearly":
+		return addMonthsClamped(start, 12*steps)
+	}
+	return start
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	total := int(t.Month()) - 1 + months
+	year := t.Year() + total/12
+	month := time.Month(total%12 + 1)
+	day := t.Day()
+	// 下个月第 0 天即本月最后一天
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+}
+
+// normalizeRecurringSchedule 校验重复计划，interval 为 0 时按 1 处理
+func normalizeRecurringSchedule(rule *models.RecurringRule) error {
+	if !recurringFrequencies[rule.Frequency] {
+		return utils.ErrInvalidFrequency
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+	if rule.Interval < 0 || rule.Count < 0 {
+		return utils.ErrInvalidParameter
+	}
+	if rule.EndDate != "" && rule.EndDate < rule.StartDate {
+		return utils.ErrInvalidParameter
+	}
+	return nil
+}
+
+// parseRuleEndDate 解析结束时间（包含）；只给出日期时包含当天全天，空表示不限
+func parseRuleEndDate(str string) (string, error) {
+	if str == "" {
+		return "", nil
+	}
+	t, dateOnly, err := utils.ParseDateTime(str)
+	if err != nil {
+		return "", err
+	}
+	if dateOnly {
+		t = t.AddDate(0, 0, 1).Add(-time.Second)
+	}
+	return utils.FormatDateTime(t), nil
+}
+
+// signedAmount 按账单类型给金额（分，正数）加上符号
+func signedAmount(transactionType string, cents int64) int64 {
+	if transactionType == "expense" {
+		return -cents
+	}
+	return cents
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"testing"
+	"time"
+)
+
+func TestOccurrenceTime(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     string
+		frequency string
+		interval  int
+		n         int
+		expected  string
+	}{
+		{"每天", "2025-01-30 08:00:00", "daily", 1, 3, "2025-02-02 08:00:00"},
+		{"每两周", "2025-01-01 00:00:00", "weekly", 2, 2, "2025-01-29 00:00:00"},
+		{"每月", "2025-01-15 09:30:00", "monthly", 1, 13, "2026-02-15 09:30:00"},
+		{"月末取当月最后一天", "2025-01-31 00:00:00", "monthly", 1, 1, "2025-02-28 00:00:00"},
+		{"月末不漂移", "2025-01-31 00:00:00", "monthly", 1, 2, "2025-03-31 00:00:00"},
+		{"每季度", "2025-11-30 00:00:00", "monthly", 3, 1, "2026-02-28 00:00:00"},
+		{"闰日按年", "2024-02-29 00:00:00", "yearly", 1, 1, "2025-02-28 00:00:00"},
+		{"第0次为开始时间", "2025-05-05 05:05:05", "yearly", 1, 0, "2025-05-05 05:05:05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := time.ParseInLocation(utils.DateTimeLayout, tt.start, time.Local)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := utils.FormatDateTime(occurrenceTime(start, tt.frequency, tt.interval, tt.n))
+			if result != tt.expected {
+				t.Errorf("occurrenceTime(%q, %s, %d, %d) = %q, want %q", tt.start, tt.frequency, tt.interval, tt.n, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRuleOccurrenceEnds(t *testing.T) {
+	rule := &models.RecurringRule{
+		Frequency: "monthly", Interval: 1,
+		StartDate: "2025-01-01 00:00:00", EndDate: "2025-03-01 23:59:59",
+	}
+	if _, ok := ruleOccurrence(rule, 2); !ok {
+		t.Errorf("occurrence on end date should be included")
+	}
+	if _, ok := ruleOccurrence(rule, 3); ok {
+		t.Errorf("occurrence after end date should be excluded")
+	}
+
+	rule.EndDate = ""
+	rule.Count = 2
+	if _, ok := ruleOccurrence(rule, 1); !ok {
+		t.Errorf("occurrence within count should be included")
+	}
+	if _, ok := ruleOccurrence(rule, 2); ok {
+		t.Errorf("occurrence beyond count should be excluded")
+	}
+}
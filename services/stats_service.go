@@ -5,6 +5,7 @@ import (
 	"AccountingAssistant/models"
 	"AccountingAssistant/utils"
 	"database/sql"
+	"sort"
 )
 
 // 保持与主数据库连接、方便会话管理
@@ -134,3 +135,191 @@ func (s *StatService) GetRangeAmountStats(userID int64) ([]models.RangeAmountSta
 	}
 	return rangeAmountStats, nil
 }
+
+// 账户余额统计（asOf 为截止日期或日期时间，包含当天/该时刻；空表示全部）
+func (s *StatService) GetAccountBalances(userID int64, asOf string) ([]models.AccountBalance, error) {
+	asOfStr := ""
+	if asOf != "" {
+		end, err := parseEndBound(asOf)
+		if err != nil {
+			return nil, err
+		}
+		asOfStr = end
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	return database.GetAccountBalances(userDB, asOfStr)
+}
+
+// 标签统计：按标签汇总 [start, end] 内的收入与支出（一笔账单有多个标签时计入每个标签，转账不计入）
+func (s *StatService) GetTagStats(userID int64, start string, end string) ([]models.TagStat, error) {
+	startStr, endStr, err := parseStatsRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetTagAmounts(userDB, startStr, endStr)
+}
+
+const defaultTopPayees = 10 // 商户排行默认返回的数量
+
+// 商户排行：[start, end] 内支出最多的 limit 个商户（limit 为 0 时取前 10 个），只统计支出
+func (s *StatService) GetTopPayees(userID int64, start string, end string, limit int) ([]models.PayeeStat, error) {
+	if limit < 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+	if limit == 0 {
+		limit = defaultTopPayees
+	}
+	limit = min(limit, maxPayeeLimit)
+	startStr, endStr, err := parseStatsRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetPayeeSpending(userDB, startStr, endStr, limit)
+}
+
+// parseStatsRange 把统计的起止日期（包含）转换为数据层使用的 [start, end)，空表示不限
+func parseStatsRange(start string, end string) (string, string, error) {
+	startStr := ""
+	if start != "" {
+		t, _, err := utils.ParseDateTime(start)
+		if err != nil {
+			return "", "", err
+		}
+		startStr = utils.FormatDateTime(t)
+	}
+	endStr := ""
+	if end != "" {
+		e, err := parseEndBound(end)
+		if err != nil {
+			return "", "", err
+		}
+		endStr = e
+	}
+	return startStr, endStr, nil
+}
+
+// 类别统计：按类别树汇总 [start, end] 内某一收支类型（默认支出）的账单，子类别的金额计入上级类别。
+// level 大于 0 时只展开到第 level 层（1 为只看顶级类别），更深的类别汇总到该层的类别中。
+func (s *StatService) GetCategoryStats(userID int64, transactionType string, start string, end string, level int) ([]models.CategoryStat, error) {
+	if transactionType == "" {
+		transactionType = "expense"
+	}
+	if transactionType != "income" && transactionType != "expense" {
+		return nil, utils.ErrInvalidTransactionType
+	}
+	if level < 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+	startStr, endStr, err := parseStatsRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	categories, err := database.GetCategories(userDB)
+	if err != nil {
+		return nil, err
+	}
+	amounts, err := database.GetCategoryAmounts(userDB, transactionType, startStr, endStr)
+	if err != nil {
+		return nil, err
+	}
+	return buildCategoryStats(categories, amounts, level), nil
+}
+
+// buildCategoryStats 把每个类别直接的统计按类别树逐级汇总，只保留有账单的类别，
+// 同级按金额绝对值从大到小排列；未分类的账单作为一个单独的顶级节点（category_id 为 0）。
+// 一笔拆分账单可能有多行分别在上级与子类别下，汇总的账单数按拆分账单 id 去重
+func buildCategoryStats(categories []models.Category, amounts map[int64]models.CategoryStat, level int) []models.CategoryStat {
+	// node 返回类别子树的统计，以及子树中的拆分账单 id（其余账单只在一个类别下出现，直接相加）
+	var node func(c models.Category, depth int) (models.CategoryStat, map[int64]bool)
+	var convert func(nodes []models.Category, depth int) ([]models.CategoryStat, []map[int64]bool)
+	node = func(c models.Category, depth int) (models.CategoryStat, map[int64]bool) {
+		own := amounts[c.ID]
+		stat := models.CategoryStat{
+			CategoryID: c.ID,
+			Name:       c.Name,
+			ParentID:   c.ParentID,
+			Amount:     own.OwnAmount,
+			OwnAmount:  own.OwnAmount,
+			OwnCount:   own.OwnCount,
+		}
+		splitIDs := make(map[int64]bool, len(own.SplitIDs))
+		for _, id := range own.SplitIDs {
+			splitIDs[id] = true
+		}
+		count := own.OwnCount - len(own.SplitIDs)
+		children, childSplitIDs := convert(c.Children, depth+1)
+		for i, child := range children {
+			stat.Amount += child.Amount
+			count += child.TransactionCount - len(childSplitIDs[i])
+			for id := range childSplitIDs[i] {
+				splitIDs[id] = true
+			}
+		}
+		stat.TransactionCount = count + len(splitIDs)
+		sortCategoryStats(children)
+		if level == 0 || depth < level {
+			stat.Children = children
+		}
+		stat.AmountStr = utils.CentsToYuanString(stat.Amount)
+		return stat, splitIDs
+	}
+	convert = func(nodes []models.Category, depth int) ([]models.CategoryStat, []map[int64]bool) {
+		stats := []models.CategoryStat{}
+		var splitIDs []map[int64]bool
+		for _, c := range nodes {
+			stat, ids := node(c, depth)
+			if stat.TransactionCount == 0 {
+				continue
+			}
+			stats = append(stats, stat)
+			splitIDs = append(splitIDs, ids)
+		}
+		return stats, splitIDs
+	}
+	stats, _ := convert(buildCategoryTree(categories), 1)
+	sortCategoryStats(stats)
+
+	if own, ok := amounts[0]; ok && own.OwnCount > 0 {
+		stats = append(stats, models.CategoryStat{
+			Name:             "其他", // 与账单列表中未分类的显示名称一致
+			Amount:           own.OwnAmount,
+			AmountStr:        utils.CentsToYuanString(own.OwnAmount),
+			TransactionCount: own.OwnCount,
+			OwnAmount:        own.OwnAmount,
+			OwnCount:         own.OwnCount,
+		})
+		sortCategoryStats(stats)
+	}
+	return stats
+}
+
+func sortCategoryStats(stats []models.CategoryStat) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		return abs(stats[i].Amount) > abs(stats[j].Amount)
+	})
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// 标签服务
+// 标签是账单上的自由标记（如 "出差"、"报销"、"春节旅行"），与类别互不影响，一笔账单可以有多个标签。
+// 记账时输入的标签不存在则自动创建；名称与类别一样忽略大小写与多余空白后不能重复，
+// 因为输入时多个标签可以用逗号分隔，标签名称中不能包含逗号。
+type TagService struct {
+	masterDB *sql.DB
+}
+
+// 新建标签服务的方法
+func NewTagService(masterDB *sql.DB) *TagService {
+	return &TagService{masterDB: masterDB}
+}
+
+const maxTagNameLength = 32 // 标签名称的最大字符数
+
+// 新建标签服务
+func (s *TagService) CreateTag(userID int64, name string) (int64, error) {
+	name, err := cleanTagName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+	return database.CreateTag(userDB, name)
+}
+
+// 获取全部标签（含账单数）
+func (s *TagService) GetTags(userID int64) ([]models.Tag, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+	return database.GetTags(userDB)
+}
+
+// 重命名标签服务
+func (s *TagService) RenameTag(userID int64, tagID int64, name string) error {
+	name, err := cleanTagName(name)
+	if err != nil {
+		return err
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.RenameTag(userDB, tagID, name)
+}
+
+// 删除标签服务：账单本身不受影响，只是去掉该标签
+func (s *TagService) DeleteTag(userID int64, tagID int64) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+	return database.DeleteTag(userDB, tagID)
+}
+
+// 合并标签服务：带 sourceIDs 标签的账单与规则改为带 targetID 标签，并删除来源标签
+func (s *TagService) MergeTags(userID int64, sourceIDs []int64, targetID int64) (*models.TagMergeResult, error) {
+	if len(sourceIDs) == 0 || targetID == 0 {
+		return nil, utils.ErrInvalidParameter
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	if _, err := database.GetTagByID(userDB, targetID); err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	var sources []int64
+	for _, id := range sourceIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == targetID {
+			return nil, utils.ErrInvalidParameter
+		}
+		if _, err := database.GetTagByID(userDB, id); err != nil {
+			return nil, err
+		}
+		sources = append(sources, id)
+	}
+	return database.MergeTags(userDB, sources, targetID)
+}
+
+// cleanTagName 整理并校验标签名称：去掉多余空白，不能为空、不能含逗号、不超过 maxTagNameLength 个字符
+func cleanTagName(name string) (string, error) {
+	name = cleanCategoryName(name)
+	if name == "" || strings.ContainsAny(name, ",，") || utf8.RuneCountInString(name) > maxTagNameLength {
+		return "", utils.ErrInvalidTagName
+	}
+	return name, nil
+}
+
+// parseTagNames 把输入的标签整理为不重复的名称列表：每一项可以是用逗号（含中文逗号）分隔的多个标签，
+// 空项忽略，忽略大小写与多余空白后重复的只保留第一个
+func parseTagNames(inputs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, input := range inputs {
+		for _, part := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == '，' }) {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			name, err := cleanTagName(part)
+			if err != nil {
+				return nil, err
+			}
+			key := strings.ToLower(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// resolveTagIDs 按名称查找标签，不存在的自动创建，返回标签 id（names 应已经过 parseTagNames 整理）
+func resolveTagIDs(db database.DBTX, names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, err := database.GetTagIDByName(db, name)
+		if err != nil {
+			return nil, err
+		}
+		if id == 0 {
+			if id, err = database.CreateTag(db, name); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// resolveTagFilter 把按标签名称筛选转换为标签 id；不存在的标签名称不会匹配任何账单
+func resolveTagFilter(db database.DBTX, filter *models.TransactionFilter, names []string) error {
+	parsed, err := parseTagNames(names)
+	if err != nil {
+		return err
+	}
+	for _, name := range parsed {
+		id, err := database.GetTagIDByName(db, name)
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			id = -1 // 不存在的标签
+		}
+		filter.TagIDs = append(filter.TagIDs, id)
+	}
+	return nil
+}
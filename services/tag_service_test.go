@@ -0,0 +1,65 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTagNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		expected []string
+		valid    bool
+	}{
+		{"多个参数", []string{"出差", "报销"}, []string{"出差", "报销"}, true},
+		{"逗号分隔", []string{"出差, 报销，家庭"}, []string{"出差", "报销", "家庭"}, true},
+		{"忽略大小写去重", []string{"Trip", "trip ", "TRIP,出差"}, []string{"Trip", "出差"}, true},
+		{"空白合并", []string{"  year   end "}, []string{"year end"}, true},
+		{"空项跳过", []string{"", ",,", " , 出差"}, []string{"出差"}, true},
+		{"没有标签", nil, []string{}, true},
+		{"名称过长", []string{strings.Repeat("长", maxTagNameLength+1)}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTagNames(tt.inputs)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parseTagNames(%q) error = %v, want valid %v", tt.inputs, err, tt.valid)
+			}
+			if tt.valid && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseTagNames(%q) = %q, want %q", tt.inputs, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCleanTagName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{" 出差 ", "出差", true},
+		{"Year \t End", "Year End", true},
+		{"   ", "", false},
+		{"a,b", "", false},
+		{"a，b", "", false},
+	}
+	for _, tt := range tests {
+		got, err := cleanTagName(tt.input)
+		if (err == nil) != tt.valid || got != tt.expected {
+			t.Errorf("cleanTagName(%q) = %q, %v, want %q valid %v", tt.input, got, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+func TestMissingTags(t *testing.T) {
+	got := missingTags([]string{"出差", "Trip"}, []string{"trip", "报销", "出差"})
+	if !reflect.DeepEqual(got, []string{"报销"}) {
+		t.Errorf("missingTags = %q, want [报销]", got)
+	}
+	if got := missingTags(nil, nil); len(got) != 0 {
+		t.Errorf("missingTags(nil, nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+)
+
+// 批量操作账单：对一组账单执行同一个操作，整批在一个数据库事务中完成。
+// 每笔账单在各自的保存点中修改，单笔失败只撤销这一笔并记入结果，其余照常提交。
+
+// 批量操作的种类
+const (
+	bulkRecategorize  = "recategorize"   // 修改类别
+	bulkRetag         = "retag"          // 修改标签
+	bulkChangeAccount = "change_account" // 修改账户（转账为转出账户）
+	bulkChangeType    = "change_type"    // 收入、支出互换
+	bulkDelete        = "delete"         // 移到回收站
+	bulkRestore       = "restore"        // 从回收站恢复
+)
+
+// 批量修改标签的方式
+const (
+	bulkTagSet    = "set"    // 替换为给出的标签（空表示清空）
+	bulkTagAdd    = "add"    // 加上给出的标签
+	bulkTagRemove = "remove" // 去掉给出的标签
+)
+
+// 一次批量操作最多的账单数
+const maxBulkTransactions = 5000
+
+// BulkTransactionInput "批量操作账单"的输入
+type BulkTransactionInput struct {
+	Operation string
+	IDs       []int64          // 目标账单 id；为空时使用 Query
+	All       bool             // 为 true 且没有给出 IDs 时，目标为符合 Query 的全部账单（restore 时为回收站中的账单）
+	Query     TransactionQuery // 排序与分页参数不起作用
+	Category  *string          // recategorize：类别名，不存在时自动创建，空字符串表示未分类
+	Type      string           // change_type：income 或 expense
+	AccountID *int64           // change_account：0 表示清空账户
+	Tags      []string         // retag：标签名，每一项可以是逗号分隔的多个标签
+	TagMode   string           // retag：set（默认）/ add / remove
+}
+
+// "批量操作账单"服务：返回每笔账单的结果。转账的两条腿作为整体处理，同一笔转账只执行一次
+func (s *TransactionService) BulkUpdate(actor Actor, input BulkTransactionInput) (*models.BulkTransactionResult, error) {
+	tagNames, err := checkBulkInput(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return nil, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	ids, err := bulkTargets(tx, input)
+	if err != nil {
+		return nil, err
+	}
+	if input.Operation == bulkChangeAccount && *input.AccountID != 0 {
+		if err := checkAccountUsable(tx, *input.AccountID); err != nil {
+			return nil, err
+		}
+	}
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BulkTransactionResult{Operation: input.Operation, Results: []models.BulkTransactionOutcome{}}
+	done := make(map[int64]error) // 历史中使用的 id（转账为 transfer_id）-> 该笔的结果
+	for _, id := range ids {
+		entityID, err := database.HistoryTransactionID(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		itemErr, ok := done[entityID]
+		if !ok {
+			itemErr, err = database.WithSavepoint(tx, func() error {
+				return applyBulkOperation(tx, actor, input, tagNames, id)
+			})
+			if err != nil {
+				return nil, err
+			}
+			done[entityID] = itemErr
+		}
+		outcome := models.BulkTransactionOutcome{ID: id, Success: itemErr == nil}
+		if itemErr != nil {
+			outcome.Error = errorMessage(itemErr)
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, outcome)
+	}
+	result.Total = len(result.Results)
+
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return result, nil
+}
+
+// checkBulkInput 校验操作与其参数，返回 retag 使用的标签名
+func checkBulkInput(input *BulkTransactionInput) ([]string, error) {
+	if len(input.IDs) == 0 && !input.All {
+		return nil, utils.ErrInvalidParameter
+	}
+	if len(input.IDs) > maxBulkTransactions {
+		return nil, utils.ErrBulkTooManyTargets
+	}
+	switch input.Operation {
+	case bulkRecategorize:
+		if input.Category == nil {
+			return nil, utils.ErrInvalidParameter
+		}
+	case bulkRetag:
+		if input.TagMode == "" {
+			input.TagMode = bulkTagSet
+		}
+		if input.TagMode != bulkTagSet && input.TagMode != bulkTagAdd && input.TagMode != bulkTagRemove {
+			return nil, utils.ErrInvalidParameter
+		}
+		return parseTagNames(input.Tags)
+	case bulkChangeAccount:
+		if input.AccountID == nil {
+			return nil, utils.ErrInvalidParameter
+		}
+	case bulkChangeType:
+		if input.Type != "income" && input.Type != "expense" {
+			return nil, utils.ErrInvalidTransactionType
+		}
+	case bulkDelete, bulkRestore:
+	default:
+		return nil, utils.ErrInvalidBulkOperation
+	}
+	return nil, nil
+}
+
+// bulkTargets 返回去重后的目标账单 id：给出 IDs 时按给出的顺序，否则为符合筛选条件的账单（按 id 排序）
+func bulkTargets(tx database.DBTX, input BulkTransactionInput) ([]int64, error) {
+	if len(input.IDs) > 0 {
+		seen := make(map[int64]bool, len(input.IDs))
+		ids := make([]int64, 0, len(input.IDs))
+		for _, id := range input.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	query := input.Query
+	query.SortBy, query.SortOrder, query.Limit, query.Offset, query.PageToken = "", "", 0, 0, ""
+	filter, err := buildTransactionFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	filter.Trashed = input.Operation == bulkRestore
+	if err := resolveTagFilter(tx, &filter, query.Tags); err != nil {
+		return nil, err
+	}
+	ids, err := database.GetTransactionIDs(tx, filter, maxBulkTransactions)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > maxBulkTransactions {
+		return nil, utils.ErrBulkTooManyTargets
+	}
+	return ids, nil
+}
+
+// applyBulkOperation 对一笔账单执行批量操作并记录变更历史。
+// 修改类的操作与"更新账单"走同一套校验（如拆分账单不能指定类别、转账不能有类别和标签）
+func applyBulkOperation(tx database.DBTX, actor Actor, input BulkTransactionInput, tagNames []string, transactionID int64) error {
+	old, entityID, err := snapshotBeforeChange(tx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	action := historyActionUpdate
+	switch input.Operation {
+	case bulkDelete:
+		action = historyActionDelete
+		err = database.DeleteTransaction(tx, transactionID)
+	case bulkRestore:
+		action, old = historyActionRestore, nil
+		err = database.RestoreTransaction(tx, transactionID)
+	default:
+		update := UpdateTransactionInput{Category: input.Category, AccountID: input.AccountID}
+		if input.Operation == bulkChangeType {
+			update.Type = &input.Type
+		}
+		if input.Operation == bulkRetag {
+			var current []string
+			if old != nil {
+				current = old.Tags
+			}
+			tags := retagNames(current, tagNames, input.TagMode)
+			update.Tags = &tags
+		}
+		err = updateTransaction(tx, transactionID, update)
+	}
+	if err != nil {
+		return err
+	}
+	return recordTransactionHistory(tx, actor, action, entityID, old)
+}
+
+// retagNames 按修改方式计算账单新的标签
+func retagNames(current []string, names []string, mode string) []string {
+	switch mode {
+	case bulkTagAdd:
+		return append(append([]string{}, current...), names...)
+	case bulkTagRemove:
+		remove := make(map[string]bool, len(names))
+		for _, name := range names {
+			remove[name] = true
+		}
+		kept := []string{}
+		for _, name := range current {
+			if !remove[name] {
+				kept = append(kept, name)
+			}
+		}
+		return kept
+	}
+	return append([]string{}, names...)
+}
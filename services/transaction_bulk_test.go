@@ -0,0 +1,59 @@
+package services
+
+import (
+	"AccountingAssistant/utils"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRetagNames(t *testing.T) {
+	current := []string{"出差", "报销"}
+	tests := []struct {
+		mode     string
+		names    []string
+		expected []string
+	}{
+		{bulkTagSet, []string{"旅行"}, []string{"旅行"}},
+		{bulkTagSet, nil, []string{}},
+		{bulkTagAdd, []string{"旅行"}, []string{"出差", "报销", "旅行"}},
+		{bulkTagRemove, []string{"报销", "不存在"}, []string{"出差"}},
+		{bulkTagRemove, []string{"出差", "报销"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := retagNames(current, tt.names, tt.mode); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("retagNames(%v, %v, %q) = %v, want %v", current, tt.names, tt.mode, got, tt.expected)
+		}
+	}
+	if current[0] != "出差" || len(current) != 2 {
+		t.Errorf("retagNames should not modify current tags, got %v", current)
+	}
+}
+
+func TestCheckBulkInput(t *testing.T) {
+	category := "餐饮"
+	accountID := int64(0)
+	tests := []struct {
+		name     string
+		input    BulkTransactionInput
+		expected error
+	}{
+		{"no targets", BulkTransactionInput{Operation: bulkDelete}, utils.ErrInvalidParameter},
+		{"unknown operation", BulkTransactionInput{Operation: "merge", IDs: []int64{1}}, utils.ErrInvalidBulkOperation},
+		{"delete by ids", BulkTransactionInput{Operation: bulkDelete, IDs: []int64{1}}, nil},
+		{"restore all", BulkTransactionInput{Operation: bulkRestore, All: true}, nil},
+		{"recategorize without category", BulkTransactionInput{Operation: bulkRecategorize, IDs: []int64{1}}, utils.ErrInvalidParameter},
+		{"recategorize", BulkTransactionInput{Operation: bulkRecategorize, IDs: []int64{1}, Category: &category}, nil},
+		{"change account without account", BulkTransactionInput{Operation: bulkChangeAccount, All: true}, utils.ErrInvalidParameter},
+		{"clear account", BulkTransactionInput{Operation: bulkChangeAccount, All: true, AccountID: &accountID}, nil},
+		{"change type to transfer", BulkTransactionInput{Operation: bulkChangeType, IDs: []int64{1}, Type: "transfer"}, utils.ErrInvalidTransactionType},
+		{"change type", BulkTransactionInput{Operation: bulkChangeType, IDs: []int64{1}, Type: "income"}, nil},
+		{"bad tag mode", BulkTransactionInput{Operation: bulkRetag, IDs: []int64{1}, TagMode: "toggle"}, utils.ErrInvalidParameter},
+		{"too many ids", BulkTransactionInput{Operation: bulkDelete, IDs: make([]int64, maxBulkTransactions+1)}, utils.ErrBulkTooManyTargets},
+	}
+	for _, tt := range tests {
+		if _, err := checkBulkInput(&tt.input); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: checkBulkInput() error = %v, want %v", tt.name, err, tt.expected)
+		}
+	}
+}
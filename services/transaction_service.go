@@ -5,6 +5,8 @@ import (
 	"AccountingAssistant/models"
 	"AccountingAssistant/utils"
 	"database/sql"
+	"strings"
+	"time"
 )
 
 // TransactionService 提供与账单（transactions）相关的业务操作。
@@ -22,47 +24,315 @@ func NewTransactionService(masterDB *sql.DB) *TransactionService {
 	return &TransactionService{masterDB: masterDB}
 }
 
+// RecordTransactionInput "记录账单"的输入（金额、时间均为用户输入的原始字符串）
+type RecordTransactionInput struct {
+	Type        string // "income"、"expense" 或 "transfer"
+	Amount      string
+	Category    string // 类别名，不存在时自动创建，空表示未分类
+	CategoryID  int64  // 已确定的类别（如自动分类规则的结果），非 0 时优先于 Category
+	Note        string
+	OccurredAt  string       // 发生的日期或日期时间，空表示当前时间
+	AccountID   int64        // 0 表示不指定账户；转账时为转出账户
+	ToAccountID int64        // 仅转账使用：转入账户
+	ExternalID  string       // 导入时来源中的唯一编号（如支付宝交易号），用于去重
+	Tags        []string     // 标签名，不存在时自动创建；每一项可以是逗号分隔的多个标签（转账不能有标签）
+	Splits      []SplitInput // 拆分行，各行金额之和须等于 Amount；有拆分时不能再指定类别
+	Payee       string       // 商户名称或别名，不存在时自动创建；空表示没有商户（转账不能有商户）
+	PayeeID     int64        // 已确定的商户，非 0 时优先于 Payee
+	// 导入外部账单时为 true：账单中的退款等常以收入记在支出类别下，不校验类别的收支类型
+	SkipCategoryKindCheck bool
+}
+
 // "记录账单"服务
-func (s *TransactionService) RecordTransaction(userID int64, transactionType string, amountStr string, category string, note string) (int64, error) {
+func (s *TransactionService) RecordTransaction(actor Actor, input RecordTransactionInput) (int64, error) {
+	userDB, err := database.GetUserDB(actor.UserID)
+	if err != nil {
+		return 0, err
+	}
+	defer userDB.Close()
+
+	tx, err := userDB.Begin()
+	if err != nil {
+		return 0, utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return 0, err
+	}
+	var transactionID int64
+	if input.Type == "transfer" {
+		// 转账需要同时写入两条腿
+		if transactionID, err = recordTransfer(tx, input); err != nil {
+			return 0, err
+		}
+	} else {
+		// 没有给出类别时依次使用商户的默认类别与自动分类规则
+		if _, err := applyPayee(tx, &input); err != nil {
+			return 0, err
+		}
+		if _, err := applyCategoryRules(tx, &input, nil); err != nil {
+			return 0, err
+		}
+		if transactionID, err = recordTransaction(tx, input); err != nil {
+			return 0, err
+		}
+	}
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return 0, err
+	}
+	if err := recordTransactionHistory(tx, actor, historyActionCreate, transactionID, nil); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, utils.WrapError(utils.ErrInsertFailed, err)
+	}
+	return transactionID, nil
+}
+
+// recordTransaction 校验输入并写入一条账单，可在事务中调用（见导入等批量场景）
+func recordTransaction(db database.DBTX, input RecordTransactionInput) (int64, error) {
+	if input.Type != "income" && input.Type != "expense" {
+		return 0, utils.ErrInvalidTransactionType
+	}
 	// 解析金额字符串（utils负责清洗和四舍五入到分），业务层负责根据 type 应用符号
-	cents, err := utils.ParseToCents(amountStr)
+	cents, err := utils.ParseToCents(input.Amount)
 	if err != nil {
 		return 0, err
 	}
-	if transactionType == "expense" {
+	if input.Type == "expense" {
 		cents = -cents
 	}
+	occurredAtStr, err := parseOccurredAt(input.OccurredAt)
+	if err != nil {
+		return 0, err
+	}
+	tagNames, err := parseTagNames(input.Tags)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := applyPayee(db, &input); err != nil {
+		return 0, err
+	}
 
-	userDB, err := database.GetUserDB(userID)
+	// 处理拆分：有拆分时账单本身不属于任何类别
+	if len(input.Splits) > 0 && (cleanCategoryName(input.Category) != "" || input.CategoryID != 0) {
+		return 0, utils.ErrInvalidParameter
+	}
+	splits, err := resolveSplits(db, input.Splits, input.Type, cents, input.SkipCategoryKindCheck)
 	if err != nil {
 		return 0, err
 	}
-	defer userDB.Close()
 
-	// 处理类别
-	var categoryIDPtr *int64
-	// 设置类别
-	if category != "" {
-		cid, err := database.GetCategoryIdByName(userDB, category)
+	// 处理类别（新建的类别收支类型与账单一致）
+	cid := input.CategoryID
+	if cid == 0 {
+		if cid, err = resolveCategoryID(db, input.Category, input.Type); err != nil {
+			return 0, err
+		}
+	}
+	if !input.SkipCategoryKindCheck {
+		if err := checkCategoryKind(db, cid, input.Type); err != nil {
+			return 0, err
+		}
+	}
+
+	// 处理账户
+	if input.AccountID != 0 {
+		if err := checkAccountUsable(db, input.AccountID); err != nil {
+			return 0, err
+		}
+	}
+
+	transactionID, err := database.RecordTransaction(db, &models.Transaction{
+		Type:       input.Type,
+		Amount:     cents,
+		CategoryID: cid,
+		AccountID:  input.AccountID,
+		PayeeID:    input.PayeeID,
+		ExternalID: input.ExternalID,
+		Note:       input.Note,
+		OccurredAt: occurredAtStr,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(splits) > 0 {
+		if err := database.SetTransactionSplits(db, transactionID, splits); err != nil {
+			return 0, err
+		}
+	}
+
+	// 处理标签
+	if len(tagNames) > 0 {
+		tagIDs, err := resolveTagIDs(db, tagNames)
+		if err != nil {
+			return 0, err
+		}
+		if err := database.AddTransactionTags(db, transactionID, tagIDs); err != nil {
+			return 0, err
+		}
+	}
+	return transactionID, nil
+}
+
+// resolveCategoryID 按名称查找类别，不存在则以 kind（账单的收支类型）创建；空名称返回 0（未分类）。
+// 没有同名类别且名称中含 ":" 时按层级路径处理，如 "餐饮:早餐" 为 "餐饮" 下的 "早餐"（账本导入使用这种写法）
+func resolveCategoryID(db database.DBTX, category string, kind string) (int64, error) {
+	category = cleanCategoryName(category)
+	if category == "" {
+		return 0, nil
+	}
+	cid, err := database.GetCategoryIdByName(db, category)
+	if err != nil {
+		return 0, err
+	}
+	if cid != 0 {
+		return cid, nil
+	}
+	if strings.Contains(category, ":") {
+		return resolveCategoryPath(db, strings.Split(category, ":"), kind)
+	}
+	// 不存在则创建（二次检查？）
+	return database.CreateCategory(db, models.Category{Name: category, Kind: kind})
+}
+
+// resolveCategoryPath 沿路径逐级查找类别，缺少的层级依次创建。
+// 类别名称不重复，因此已存在的同名类别无论位于哪一级都直接使用。
+func resolveCategoryPath(db database.DBTX, path []string, kind string) (int64, error) {
+	var parentID int64
+	for _, name := range path {
+		name = cleanCategoryName(name)
+		if name == "" {
+			continue
+		}
+		cid, err := database.GetCategoryIdByName(db, name)
 		if err != nil {
 			return 0, err
 		}
 		if cid == 0 {
-			// 不存在则创建（二次检查？）
-			newId, err := database.CreateCategory(userDB, category)
-			if err != nil {
+			if cid, err = database.CreateCategory(db, models.Category{Name: name, ParentID: parentID, Kind: kind}); err != nil {
 				return 0, err
 			}
-			cid = newId
 		}
-		categoryIDPtr = &cid
+		parentID = cid
+	}
+	return parentID, nil
+}
+
+// parseOccurredAt 解析账单发生时间，返回数据库存储格式；空字符串表示当前时间
+// 只给出日期时按当天 00:00:00 记录
+func parseOccurredAt(str string) (string, error) {
+	if str == "" {
+		return utils.FormatDateTime(time.Now()), nil
+	}
+	t, _, err := utils.ParseDateTime(str)
+	if err != nil {
+		return "", err
+	}
+	return utils.FormatDateTime(t), nil
+}
+
+// TransactionQuery 账单列表的原始查询参数（均为用户输入的字符串，由 service 负责校验与转换）
+type TransactionQuery struct {
+	StartDate    string // 日期或日期时间，包含
+	EndDate      string // 日期或日期时间，包含（仅日期时包含当天全天）
+	Type         string
+	CategoryID   *int64
+	CategoryName string
+	AccountID    *int64
+	PayeeID      *int64
+	MinAmount    string // 金额（元），按绝对值比较
+	MaxAmount    string
+	Note         string
+	Tags         []string // 标签名，同时带有这些标签的账单；每一项可以是逗号分隔的多个标签
+	TagIDs       []int64
+	SortBy       string
+	SortOrder    string
+	Limit        int
+	Offset       int
+	PageToken    string
+}
+
+// buildTransactionFilter 把原始查询参数转换为数据层使用的筛选条件
+func buildTransactionFilter(q TransactionQuery) (models.TransactionFilter, error) {
+	filter := models.TransactionFilter{
+		Type:         q.Type,
+		CategoryID:   q.CategoryID,
+		CategoryName: q.CategoryName,
+		AccountID:    q.AccountID,
+		PayeeID:      q.PayeeID,
+		Note:         q.Note,
+		TagIDs:       q.TagIDs,
+		SortBy:       q.SortBy,
+		SortOrder:    q.SortOrder,
+		Limit:        q.Limit,
+		Offset:       q.Offset,
+		Cursor:       q.PageToken,
+	}
+	if q.Type != "" && q.Type != "income" && q.Type != "expense" && q.Type != "transfer" {
+		return filter, utils.ErrInvalidTransactionType
+	}
+	// 排序参数在这里校验，导出等边查询边输出的调用方才能在写出任何内容之前返回错误
+	if err := database.ValidateSort(filter); err != nil {
+		return filter, err
+	}
+	if q.StartDate != "" {
+		start, _, err := utils.ParseDateTime(q.StartDate)
+		if err != nil {
+			return filter, err
+		}
+		filter.StartTime = utils.FormatDateTime(start)
+	}
+	if q.EndDate != "" {
+		end, err := parseEndBound(q.EndDate)
+		if err != nil {
+			return filter, err
+		}
+		filter.EndTime = end
 	}
+	if q.MinAmount != "" {
+		cents, err := utils.ParseToCents(q.MinAmount)
+		if err != nil {
+			return filter, err
+		}
+		filter.MinAmount = &cents
+	}
+	if q.MaxAmount != "" {
+		cents, err := utils.ParseToCents(q.MaxAmount)
+		if err != nil {
+			return filter, err
+		}
+		filter.MaxAmount = &cents
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, utils.ErrInvalidParameter
+	}
+	return filter, nil
+}
 
-	return database.RecordTransaction(userDB, transactionType, cents, categoryIDPtr, note)
+// parseEndBound 把用户输入的结束日期（包含）转换为数据层使用的不包含上界：
+// 纯日期包含当天全天，日期时间包含该秒
+func parseEndBound(str string) (string, error) {
+	end, dateOnly, err := utils.ParseDateTime(str)
+	if err != nil {
+		return "", err
+	}
+	if dateOnly {
+		end = end.AddDate(0, 0, 1)
+	} else {
+		end = end.Add(time.Second)
+	}
+	return utils.FormatDateTime(end), nil
 }
 
-// "获取账单"服务
-func (s *TransactionService) GetTransactions(userID int64) ([]models.DisplayTransaction, error) {
+// "获取账单"服务（筛选、排序、分页）
+func (s *TransactionService) GetTransactions(userID int64, query TransactionQuery) (*models.TransactionPage, error) {
+	filter, err := buildTransactionFilter(query)
+	if err != nil {
+		return nil, err
+	}
 
 	userDB, err := database.GetUserDB(userID)
 	if err != nil {
@@ -70,34 +340,119 @@ func (s *TransactionService) GetTransactions(userID int64) ([]models.DisplayTran
 	}
 	defer userDB.Close()
 
-	return database.GetTransaction(userDB)
+	if err := resolveTagFilter(userDB, &filter, query.Tags); err != nil {
+		return nil, err
+	}
+	return database.GetTransaction(userDB, filter)
 }
 
-// "删除账单"服务
-func (s *TransactionService) DeleteTransaction(userID int64, transactionID int64) error {
+// "获取单个账单"服务
+func (s *TransactionService) GetTransaction(userID int64, transactionID int64) (*models.DisplayTransaction, error) {
 	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	return database.GetDisplayTransactionByID(userDB, transactionID)
+}
+
+// "删除账单"服务：账单移到回收站（附件保留，彻底删除时才清理）
+func (s *TransactionService) DeleteTransaction(actor Actor, transactionID int64) error {
+	userDB, err := database.GetUserDB(actor.UserID)
 	if err != nil {
 		return err
 	}
 	defer userDB.Close()
 
-	return database.DeleteTransaction(userDB, transactionID)
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	old, entityID, err := snapshotBeforeChange(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	if err := database.DeleteTransaction(tx, transactionID); err != nil {
+		return err
+	}
+	if err := recordTransactionHistory(tx, actor, historyActionDelete, entityID, old); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrDeleteFailed, err)
+	}
+	return nil
+}
+
+// UpdateTransactionInput "更新账单"的输入，nil 表示不更新该字段
+type UpdateTransactionInput struct {
+	Type        *string
+	Amount      *string
+	Category    *string // 空字符串表示清空类别
+	Note        *string
+	OccurredAt  *string
+	AccountID   *int64        // 0 表示清空账户；转账时为转出账户
+	ToAccountID *int64        // 仅转账使用：转入账户
+	Tags        *[]string     // 替换账单的全部标签，空列表表示清空
+	Splits      *[]SplitInput // 替换账单的全部拆分行，空列表表示取消拆分
+	Payee       *string       // 商户名称或别名，不存在时自动创建；空字符串表示清空商户
 }
 
 // "更新账单"服务
-func (s *TransactionService) UpdateTransaction(userID int64, transactionID int64, updateType *string, updateAmount *string, updateCategoryName *string, updateNote *string) error {
-	userDB, err := database.GetUserDB(userID)
+func (s *TransactionService) UpdateTransaction(actor Actor, transactionID int64, input UpdateTransactionInput) error {
+	userDB, err := database.GetUserDB(actor.UserID)
 	if err != nil {
 		return err
 	}
 	defer userDB.Close()
 
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	old, entityID, err := snapshotBeforeChange(tx, transactionID)
+	if err != nil {
+		return err
+	}
+	maxCategoryID, err := database.MaxCategoryID(tx)
+	if err != nil {
+		return err
+	}
+	if err := updateTransaction(tx, transactionID, input); err != nil {
+		return err
+	}
+	if err := recordCreatedCategories(tx, actor, maxCategoryID); err != nil {
+		return err
+	}
+	if err := recordTransactionHistory(tx, actor, historyActionUpdate, entityID, old); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	return nil
+}
+
+// updateTransaction 在调用方的事务中校验输入并修改一条账单
+func updateTransaction(tx database.DBTX, transactionID int64, input UpdateTransactionInput) error {
+	updateType, updateAmount, updateCategoryName := input.Type, input.Amount, input.Category
+
 	// 获取原交易信息(如果没有更新Type，就会获取原来的)
-	existingTransaction, err := database.GetTransactionByID(userDB, transactionID)
+	existingTransaction, err := database.GetTransactionByID(tx, transactionID)
 	if err != nil {
 		return err
 	}
 
+	// 转账的两条腿作为整体修改
+	if existingTransaction.TransferID != 0 {
+		return updateTransfer(tx, existingTransaction.TransferID, input)
+	}
+
 	var centsPtr *int64
 	var finalType *string
 
@@ -129,7 +484,7 @@ func (s *TransactionService) UpdateTransaction(userID int64, transactionID int64
 
 		// 业务校验
 		if cents == 0 {
-			return utils.ErrInvalidTransactionType
+			return utils.ErrAmountZero
 		}
 
 		centsPtr = &cents
@@ -149,30 +504,125 @@ func (s *TransactionService) UpdateTransaction(userID int64, transactionID int64
 			centsPtr = &adjustedAmount
 		}
 	}
-	var updateCategoryPtr *int64
-	if updateCategoryName != nil {
-		// 空字符串表示清空类别 -> 约定传入 0 表示设置为 NULL
-		if *updateCategoryName == "" {
-			zero := int64(0)
-			updateCategoryPtr = &zero
-		} else {
-			cid, err := database.GetCategoryIdByName(userDB, *updateCategoryName)
-			if err != nil {
-				return err
-			}
-			if cid == 0 {
-				// 不存在则创建
-				newId, err := database.CreateCategory(userDB, *updateCategoryName)
-				if err != nil {
-					return err
+
+	// 处理拆分：给出拆分时替换全部拆分行；未给出时原有的拆分行必须与修改后的金额仍然一致
+	finalCents := existingTransaction.Amount
+	if centsPtr != nil {
+		finalCents = *centsPtr
+	}
+	var splits []models.TransactionSplit
+	hasSplits, negateSplits := false, false
+	if input.Splits != nil {
+		hasSplits = len(*input.Splits) > 0
+	} else {
+		existingSplits, err := database.GetTransactionSplits(tx, []int64{transactionID})
+		if err != nil {
+			return err
+		}
+		if lines := existingSplits[transactionID]; len(lines) > 0 {
+			hasSplits = true
+			// 收入、支出互换时拆分行随账单改变符号，各行的类别同样要能用于新的收支类型
+			if finalTransactionType != existingTransaction.Type {
+				negateSplits = true
+				for i := range lines {
+					lines[i].Amount = -lines[i].Amount
+					if err := checkCategoryKind(tx, lines[i].CategoryID, finalTransactionType); err != nil {
+						return err
+					}
 				}
-				cid = newId
 			}
-			updateCategoryPtr = &cid
+			if err := checkSplitAmounts(lines, finalCents); err != nil {
+				return err
+			}
+		}
+	}
+	// 有拆分的账单不能再指定类别
+	if hasSplits && updateCategoryName != nil && cleanCategoryName(*updateCategoryName) != "" {
+		return utils.ErrInvalidParameter
+	}
+	if input.Splits != nil {
+		if splits, err = resolveSplits(tx, *input.Splits, finalTransactionType, finalCents, false); err != nil {
+			return err
+		}
+	}
+
+	var updateCategoryPtr *int64
+	finalCategoryID := existingTransaction.CategoryID
+	if len(splits) > 0 {
+		// 拆分后账单本身不再属于任何类别
+		finalCategoryID = 0
+		updateCategoryPtr = &finalCategoryID
+	}
+	if updateCategoryName != nil {
+		// 空字符串表示清空类别 -> resolveCategoryID 返回 0，数据层设置为 NULL
+		cid, err := resolveCategoryID(tx, *updateCategoryName, finalTransactionType)
+		if err != nil {
+			return err
+		}
+		updateCategoryPtr = &cid
+		finalCategoryID = cid
+	}
+	// 类别或类型改变时，校验类别可用于最终的收支类型
+	if updateCategoryName != nil || updateType != nil {
+		if err := checkCategoryKind(tx, finalCategoryID, finalTransactionType); err != nil {
+			return err
+		}
+	}
+
+	// 更换账户时校验新账户可用（0 表示清空）
+	if input.AccountID != nil && *input.AccountID != 0 && *input.AccountID != existingTransaction.AccountID {
+		if err := checkAccountUsable(tx, *input.AccountID); err != nil {
+			return err
+		}
+	}
+
+	var occurredAtPtr *string
+	if input.OccurredAt != nil {
+		occurredAtStr, err := parseOccurredAt(*input.OccurredAt)
+		if err != nil {
+			return err
 		}
+		occurredAtPtr = &occurredAtStr
 	}
 
-	return database.UpdateTransaction(userDB, transactionID, finalType, centsPtr, updateCategoryPtr, updateNote)
+	var tagNames []string
+	if input.Tags != nil {
+		if tagNames, err = parseTagNames(*input.Tags); err != nil {
+			return err
+		}
+	}
+
+	var updatePayeePtr *int64
+	if input.Payee != nil {
+		payeeID, err := resolvePayeeID(tx, *input.Payee)
+		if err != nil {
+			return err
+		}
+		updatePayeePtr = &payeeID
+	}
+	if err := database.UpdateTransaction(tx, transactionID, finalType, centsPtr, updateCategoryPtr, input.AccountID, updatePayeePtr, input.Note, occurredAtPtr); err != nil {
+		return err
+	}
+	if input.Tags != nil {
+		tagIDs, err := resolveTagIDs(tx, tagNames)
+		if err != nil {
+			return err
+		}
+		if err := database.SetTransactionTags(tx, transactionID, tagIDs); err != nil {
+			return err
+		}
+	}
+	if input.Splits != nil {
+		if err := database.SetTransactionSplits(tx, transactionID, splits); err != nil {
+			return err
+		}
+	}
+	if negateSplits {
+		if err := database.NegateTransactionSplits(tx, transactionID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // 辅助函数：获取绝对值
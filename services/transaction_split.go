@@ -0,0 +1,74 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+)
+
+// 拆分账单
+// 一笔账单（如一张超市小票）可以拆成多行，每行有自己的类别、金额与备注，各行金额之和必须等于账单金额。
+// 有拆分的账单本身不属于任何类别，按类别的统计、预算与金额范围统计都按拆分行计。转账不能拆分。
+
+// SplitInput 拆分行的输入
+type SplitInput struct {
+	Category string // 类别名，不存在时按账单的收支类型创建
+	Amount   string // 金额（元，正数），符号随账单类型
+	Note     string
+}
+
+// resolveSplits 校验拆分行并解析类别，返回待保存的拆分行（金额已带符号）；inputs 为空表示不拆分。
+// cents 为账单金额（分，带符号），各行之和必须与之相等
+func resolveSplits(db database.DBTX, inputs []SplitInput, transactionType string, cents int64, skipKindCheck bool) ([]models.TransactionSplit, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	if len(inputs) < 2 {
+		return nil, utils.ErrInvalidSplit
+	}
+	splits := make([]models.TransactionSplit, 0, len(inputs))
+	for _, input := range inputs {
+		if cleanCategoryName(input.Category) == "" {
+			return nil, utils.ErrInvalidSplit
+		}
+		amount, err := utils.ParseToCents(input.Amount)
+		if err != nil {
+			return nil, err
+		}
+		if amount <= 0 {
+			return nil, utils.ErrInvalidSplit
+		}
+		if transactionType == "expense" {
+			amount = -amount
+		}
+		cid, err := resolveCategoryID(db, input.Category, transactionType)
+		if err != nil {
+			return nil, err
+		}
+		if !skipKindCheck {
+			if err := checkCategoryKind(db, cid, transactionType); err != nil {
+				return nil, err
+			}
+		}
+		splits = append(splits, models.TransactionSplit{CategoryID: cid, Amount: amount, Note: input.Note})
+	}
+	if err := checkSplitAmounts(splits, cents); err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// checkSplitAmounts 校验每行金额与账单同号且不为 0，各行之和等于账单金额 cents
+func checkSplitAmounts(splits []models.TransactionSplit, cents int64) error {
+	var sum int64
+	for _, s := range splits {
+		if s.Amount == 0 || (s.Amount < 0) != (cents < 0) {
+			return utils.ErrSplitAmountMismatch
+		}
+		sum += s.Amount
+	}
+	if sum != cents {
+		return utils.ErrSplitAmountMismatch
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"errors"
+	"testing"
+)
+
+func TestCheckSplitAmounts(t *testing.T) {
+	lines := func(amounts ...int64) []models.TransactionSplit {
+		splits := make([]models.TransactionSplit, len(amounts))
+		for i, a := range amounts {
+			splits[i] = models.TransactionSplit{CategoryID: int64(i + 1), Amount: a}
+		}
+		return splits
+	}
+	tests := []struct {
+		name   string
+		splits []models.TransactionSplit
+		cents  int64
+		valid  bool
+	}{
+		{"支出之和相等", lines(-3000, -1500, -550), -5050, true},
+		{"收入之和相等", lines(10000, 2000), 12000, true},
+		{"之和不等", lines(-3000, -1500), -5000, false},
+		{"符号与账单不同", lines(-6000, 1000), -5000, false},
+		{"收入账单的支出行", lines(-1000, -1000), 2000, false},
+		{"金额为 0 的行", lines(-5000, 0), -5000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSplitAmounts(tt.splits, tt.cents)
+			if (err == nil) != tt.valid {
+				t.Fatalf("checkSplitAmounts = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, utils.ErrSplitAmountMismatch) {
+				t.Errorf("checkSplitAmounts error = %v, want ErrSplitAmountMismatch", err)
+			}
+		})
+	}
+}
+
+func TestResolveSplitsNeedsTwoLines(t *testing.T) {
+	splits, err := resolveSplits(nil, nil, "expense", -1000, false)
+	if err != nil || splits != nil {
+		t.Errorf("resolveSplits(nil) = %v, %v, want no splits", splits, err)
+	}
+	_, err = resolveSplits(nil, []SplitInput{{Category: "餐饮", Amount: "10"}}, "expense", -1000, false)
+	if !errors.Is(err, utils.ErrInvalidSplit) {
+		t.Errorf("resolveSplits(one line) error = %v, want ErrInvalidSplit", err)
+	}
+}
+
+// 只修改拆分账单的类型时，拆分行随账单改变符号；行的类别不能用于新类型时拒绝修改
+func TestUpdateSplitTransactionType(t *testing.T) {
+	actor, userDB := newTestUser(t)
+	for _, name := range []string{"代购", "报销"} {
+		if _, err := NewCategoryService(nil).CreateCategory(actor, CategoryInput{Name: name, Kind: "both"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transactions := NewTransactionService(nil)
+	id, err := transactions.RecordTransaction(actor, RecordTransactionInput{
+		Type: "expense", Amount: "30",
+		Splits: []SplitInput{{Category: "代购", Amount: "10"}, {Category: "报销", Amount: "20"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	income := "income"
+	if err := transactions.UpdateTransaction(actor, id, UpdateTransactionInput{Type: &income}); err != nil {
+		t.Fatalf("change type to income: %v", err)
+	}
+	got, _, err := database.GetTransactionSnapshot(userDB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "income" || got.Amount != 3000 || len(got.Splits) != 2 ||
+		got.Splits[0].Amount != 1000 || got.Splits[1].Amount != 2000 {
+		t.Errorf("after change = %+v, want income 3000 with lines 1000, 2000", got)
+	}
+
+	// 餐饮只能用于支出
+	id, err = transactions.RecordTransaction(actor, RecordTransactionInput{
+		Type: "expense", Amount: "30",
+		Splits: []SplitInput{{Category: "代购", Amount: "10"}, {Category: "餐饮", Amount: "20"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transactions.UpdateTransaction(actor, id, UpdateTransactionInput{Type: &income}); !errors.Is(err, utils.ErrCategoryKindMismatch) {
+		t.Errorf("change type with an expense-only line: error = %v, want ErrCategoryKindMismatch", err)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/utils"
+)
+
+// 转账：资金在两个账户之间移动，两条腿作为整体记录、修改和删除，不计入收支统计
+
+// checkTransferAccounts 校验转出、转入账户都可用、互不相同且币种一致
+func checkTransferAccounts(db database.DBTX, fromAccountID int64, toAccountID int64) error {
+	if fromAccountID == 0 || toAccountID == 0 {
+		return utils.ErrInvalidParameter
+	}
+	if fromAccountID == toAccountID {
+		return utils.ErrTransferSameAccount
+	}
+	if err := checkAccountUsable(db, fromAccountID); err != nil {
+		return err
+	}
+	if err := checkAccountUsable(db, toAccountID); err != nil {
+		return err
+	}
+	from, err := database.GetAccountByID(db, fromAccountID)
+	if err != nil {
+		return err
+	}
+	to, err := database.GetAccountByID(db, toAccountID)
+	if err != nil {
+		return err
+	}
+	if from.Currency != to.Currency {
+		return utils.ErrTransferCurrencyDiffer
+	}
+	return nil
+}
+
+// recordTransfer 在调用方的事务中记录一笔转账（input.AccountID 为转出账户，input.ToAccountID 为转入账户），返回 transfer_id
+func recordTransfer(db database.DBTX, input RecordTransactionInput) (int64, error) {
+	cents, occurredAtStr, err := checkTransferInput(db, input)
+	if err != nil {
+		return 0, err
+	}
+	return database.InsertTransferLegs(db, cents, input.AccountID, input.ToAccountID, input.Note, occurredAtStr, input.ExternalID)
+}
+
+// checkTransferInput 校验转账输入，返回金额（分）与发生时间
+func checkTransferInput(db database.DBTX, input RecordTransactionInput) (int64, string, error) {
+	cents, err := utils.ParseToCents(input.Amount)
+	if err != nil {
+		return 0, "", err
+	}
+	if cents == 0 {
+		return 0, "", utils.ErrAmountZero
+	}
+	// 转账不属于任何收支类别，也不能有标签、拆分或商户
+	if input.Category != "" || len(input.Tags) > 0 || len(input.Splits) > 0 || input.Payee != "" {
+		return 0, "", utils.ErrInvalidParameter
+	}
+	occurredAtStr, err := parseOccurredAt(input.OccurredAt)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := checkTransferAccounts(db, input.AccountID, input.ToAccountID); err != nil {
+		return 0, "", err
+	}
+	return cents, occurredAtStr, nil
+}
+
+// updateTransfer 在调用方的事务中修改转账（可通过任意一条腿的 id 修改），两条腿同时更新
+func updateTransfer(db database.DBTX, transferID int64, input UpdateTransactionInput) error {
+	// 转账不能与收入/支出互相转换，也没有类别、标签、拆分和商户
+	if input.Type != nil && *input.Type != "transfer" {
+		return utils.ErrInvalidTransactionType
+	}
+	if input.Category != nil && *input.Category != "" {
+		return utils.ErrInvalidParameter
+	}
+	if input.Tags != nil && len(*input.Tags) > 0 {
+		return utils.ErrInvalidParameter
+	}
+	if input.Splits != nil && len(*input.Splits) > 0 {
+		return utils.ErrInvalidParameter
+	}
+	if input.Payee != nil && *input.Payee != "" {
+		return utils.ErrInvalidParameter
+	}
+
+	out, in, err := database.GetTransferLegs(db, transferID)
+	if err != nil {
+		return err
+	}
+
+	var centsPtr *int64
+	if input.Amount != nil {
+		cents, err := utils.ParseToCents(*input.Amount)
+		if err != nil {
+			return err
+		}
+		if cents == 0 {
+			return utils.ErrAmountZero
+		}
+		centsPtr = &cents
+	}
+
+	// 账户变化时按最终的转出/转入账户重新校验
+	if input.AccountID != nil || input.ToAccountID != nil {
+		fromID, toID := out.AccountID, in.AccountID
+		if input.AccountID != nil {
+			fromID = *input.AccountID
+		}
+		if input.ToAccountID != nil {
+			toID = *input.ToAccountID
+		}
+		if err := checkTransferAccounts(db, fromID, toID); err != nil {
+			return err
+		}
+	}
+
+	var occurredAtPtr *string
+	if input.OccurredAt != nil {
+		occurredAtStr, err := parseOccurredAt(*input.OccurredAt)
+		if err != nil {
+			return err
+		}
+		occurredAtPtr = &occurredAtStr
+	}
+
+	return database.UpdateTransfer(db, transferID, centsPtr, input.AccountID, input.ToAccountID, input.Note, occurredAtPtr)
+}
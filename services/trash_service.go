@@ -0,0 +1,300 @@
+package services
+
+import (
+	"AccountingAssistant/database"
+	"AccountingAssistant/models"
+	"AccountingAssistant/utils"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 回收站服务
+// 删除的账单与类别先进入回收站，可以恢复或彻底删除；超过保留天数的由后台任务自动彻底删除。
+// 彻底删除账单后清理不再被引用的附件文件。
+type TrashService struct {
+	masterDB *sql.DB
+	runMu    sync.Mutex // 同一进程内同时只做一轮自动清理
+}
+
+// 新建回收站服务的方法
+func NewTrashService(masterDB *sql.DB) *TrashService {
+	return &TrashService{masterDB: masterDB}
+}
+
+const (
+	trashRetentionKey         = "trash_retention_days" // 设置表中保留天数的键
+	defaultTrashRetentionDays = 30
+	maxTrashRetentionDays     = 3650
+)
+
+// 查看回收站服务：账单按删除时间倒序分页（limit、pageToken 与账单列表相同）
+func (s *TrashService) GetTrash(userID int64, limit int, pageToken string) (*models.Trash, error) {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer userDB.Close()
+
+	page, err := database.GetTransaction(userDB, models.TransactionFilter{
+		Trashed:   true,
+		SortBy:    "deleted_at",
+		SortOrder: "desc",
+		Limit:     limit,
+		Cursor:    pageToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	categories, err := database.GetTrashedCategories(userDB)
+	if err != nil {
+		return nil, err
+	}
+	days, err := trashRetentionDays(userDB)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Trash{Transactions: page, Categories: categories, RetentionDays: days}, nil
+}
+
+// 恢复账单服务
+func (s *TrashService) RestoreTransaction(actor Actor, transactionID int64) error {
+	return s.inUserTx(actor.UserID, false, func(tx *sql.Tx) error {
+		if err := database.RestoreTransaction(tx, transactionID); err != nil {
+			return err
+		}
+		entityID, err := database.HistoryTransactionID(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		return recordTransactionHistory(tx, actor, historyActionRestore, entityID, nil)
+	})
+}
+
+// 恢复类别服务
+func (s *TrashService) RestoreCategory(actor Actor, categoryID int64) error {
+	return s.inUserTx(actor.UserID, false, func(tx *sql.Tx) error {
+		if err := database.RestoreCategory(tx, categoryID); err != nil {
+			return err
+		}
+		return recordCategoryHistory(tx, actor, historyActionRestore, categoryID, nil)
+	})
+}
+
+// 彻底删除回收站中的账单服务
+func (s *TrashService) PurgeTransaction(actor Actor, transactionID int64) error {
+	return s.inUserTx(actor.UserID, true, func(tx *sql.Tx) error {
+		old, entityID, err := snapshotBeforeChange(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		if err := database.PurgeTransaction(tx, transactionID); err != nil {
+			return err
+		}
+		return recordTransactionHistory(tx, actor, historyActionPurge, entityID, old)
+	})
+}
+
+// 彻底删除回收站中的类别服务
+func (s *TrashService) PurgeCategory(actor Actor, categoryID int64) error {
+	return s.inUserTx(actor.UserID, false, func(tx *sql.Tx) error {
+		baseline, err := captureHistoryBaseline(tx, []int64{categoryID})
+		if err != nil {
+			return err
+		}
+		if err := database.PurgeCategory(tx, categoryID); err != nil {
+			return err
+		}
+		if err := recordCategoryHistory(tx, actor, historyActionPurge, categoryID, baseline.categories[categoryID]); err != nil {
+			return err
+		}
+		return baseline.recordUpdates(tx, actor)
+	})
+}
+
+// 清空回收站服务
+func (s *TrashService) EmptyTrash(actor Actor) (*models.TrashPurgeResult, error) {
+	var result *models.TrashPurgeResult
+	err := s.inUserTx(actor.UserID, true, func(tx *sql.Tx) error {
+		var err error
+		result, err = purgeTrash(tx, actor, "")
+		return err
+	})
+	return result, err
+}
+
+// inUserTx 打开用户数据库，在一个事务中执行 fn（见 trashTx）
+func (s *TrashService) inUserTx(userID int64, cleanFiles bool, fn func(tx *sql.Tx) error) error {
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	return trashTx(userDB, userID, cleanFiles, fn)
+}
+
+// trashTx 在一个事务中执行 fn 并提交；cleanFiles 为 true 时提交后清理不再被引用的附件文件
+func trashTx(userDB *sql.DB, userID int64, cleanFiles bool, fn func(tx *sql.Tx) error) error {
+	tx, err := userDB.Begin()
+	if err != nil {
+		return utils.WrapError(utils.ErrDBConnFailed, err)
+	}
+	defer tx.Rollback() // 提交后再回滚不会有任何影响
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.WrapError(utils.ErrUpdateFailed, err)
+	}
+	if cleanFiles {
+		removeOrphanAttachmentFiles(userDB, userID)
+	}
+	return nil
+}
+
+// purgeTrash 彻底删除 before 之前移入回收站的账单与类别（before 为空表示全部），并为每一项记录彻底删除的历史
+func purgeTrash(tx database.DBTX, actor Actor, before string) (*models.TrashPurgeResult, error) {
+	transactionIDs, categoryIDs, err := database.GetTrashedIDs(tx, before)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make(map[int64]*models.TransactionSnapshot, len(transactionIDs))
+	for _, id := range transactionIDs {
+		if transactions[id], _, err = database.GetTransactionSnapshot(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	baseline, err := captureHistoryBaseline(tx, categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := database.PurgeTrash(tx, before)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range transactionIDs {
+		if err := recordTransactionHistory(tx, actor, historyActionPurge, id, transactions[id]); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range categoryIDs {
+		if err := recordCategoryHistory(tx, actor, historyActionPurge, id, baseline.categories[id]); err != nil {
+			return nil, err
+		}
+	}
+	// 同时被彻底删除的账单在 recordUpdates 中读不到当前快照，需要排除
+	for _, id := range transactionIDs {
+		delete(baseline.transactions, id)
+	}
+	if err := baseline.recordUpdates(tx, actor); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 设置回收站保留天数服务：0 表示不自动彻底删除
+func (s *TrashService) SetRetentionDays(userID int64, days int) error {
+	if days < 0 || days > maxTrashRetentionDays {
+		return utils.ErrInvalidParameter
+	}
+
+	userDB, err := database.GetUserDB(userID)
+	if err != nil {
+		return err
+	}
+	defer userDB.Close()
+
+	return database.SetSetting(userDB, trashRetentionKey, strconv.Itoa(days))
+}
+
+// StartPurger 启动后台清理任务：启动时立即清理一次，之后每隔 interval 清理一次。返回的函数用于停止任务。
+func (s *TrashService) StartPurger(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.PurgeExpired(time.Now())
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// PurgeExpired 为所有用户彻底删除在回收站中超过保留天数的账单与类别；单个用户出错不影响其他用户
+func (s *TrashService) PurgeExpired(now time.Time) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	userIDs, err := database.GetAllUserIDs(s.masterDB)
+	if err != nil {
+		fmt.Printf("回收站：获取用户列表失败: %v\n", err)
+		return
+	}
+	for _, userID := range userIDs {
+		userDB, err := database.GetUserDB(userID)
+		if err != nil {
+			fmt.Printf("回收站：打开用户 %d 的数据库失败: %v\n", userID, err)
+			continue
+		}
+		if err := purgeExpiredTrash(userDB, userID, now); err != nil {
+			fmt.Printf("回收站：用户 %d 清理失败: %v\n", userID, err)
+		}
+		userDB.Close()
+	}
+}
+
+// purgeExpiredTrash 按用户设置的保留天数清理一个用户的回收站，以账户所有者的身份记录历史
+func purgeExpiredTrash(userDB *sql.DB, userID int64, now time.Time) error {
+	days, err := trashRetentionDays(userDB)
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		return nil
+	}
+	var result *models.TrashPurgeResult
+	err = trashTx(userDB, userID, false, func(tx *sql.Tx) error {
+		result, err = purgeTrash(tx, systemActor(userID), trashPurgeCutoff(now, days))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if result.Transactions > 0 {
+		removeOrphanAttachmentFiles(userDB, userID)
+	}
+	return nil
+}
+
+// trashRetentionDays 返回用户设置的回收站保留天数
+func trashRetentionDays(userDB database.DBTX) (int, error) {
+	value, ok, err := database.GetSetting(userDB, trashRetentionKey)
+	if err != nil {
+		return 0, err
+	}
+	return parseRetentionDays(value, ok), nil
+}
+
+// parseRetentionDays 解析设置中的保留天数，未设置或无效时使用默认值
+func parseRetentionDays(value string, ok bool) int {
+	days, err := strconv.Atoi(value)
+	if !ok || err != nil || days < 0 || days > maxTrashRetentionDays {
+		return defaultTrashRetentionDays
+	}
+	return days
+}
+
+// trashPurgeCutoff 返回保留 days 天时的截止时间：在此之前（UTC，与 deleted_at 的格式一致）删除的内容应彻底删除
+func trashPurgeCutoff(now time.Time, days int) string {
+	return now.UTC().AddDate(0, 0, -days).Format("2006-01-02 15:04:05")
+}
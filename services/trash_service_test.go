@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetentionDays(t *testing.T) {
+	tests := []struct {
+		value    string
+		ok       bool
+		expected int
+	}{
+		{"", false, defaultTrashRetentionDays},
+		{"7", true, 7},
+		{"0", true, 0},
+		{"-1", true, defaultTrashRetentionDays},
+		{"abc", true, defaultTrashRetentionDays},
+		{"99999", true, defaultTrashRetentionDays},
+	}
+	for _, tt := range tests {
+		if got := parseRetentionDays(tt.value, tt.ok); got != tt.expected {
+			t.Errorf("parseRetentionDays(%q, %v) = %d, want %d", tt.value, tt.ok, got, tt.expected)
+		}
+	}
+}
+
+func TestTrashPurgeCutoff(t *testing.T) {
+	// 截止时间按 UTC 计算，与 deleted_at（CURRENT_TIMESTAMP）一致
+	now := time.Date(2024, 3, 1, 8, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	if got := trashPurgeCutoff(now, 30); got != "2024-01-31 00:30:00" {
+		t.Errorf("trashPurgeCutoff = %q, want 2024-01-31 00:30:00", got)
+	}
+}
@@ -169,6 +169,26 @@ func ParseToCents(str string) (int64, error) {
 	return result, nil
 }
 
+// 1.1 带符号的字符串转分
+// ParseSignedToCents 与 ParseToCents 规则相同，但保留输入中的负号（如期初余额、导入的带符号金额）。
+// 负号可以出现在开头或结尾（"-12.5"、"12.5-"），也支持会计格式的括号（"(12.50)"）。
+func ParseSignedToCents(str string) (int64, error) {
+	s := strings.TrimSpace(str)
+	negative := strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-")
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+	}
+	cents, err := ParseToCents(s)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
 // 2. 分转字符串
 func CentsToYuanString(cents int64) string {
 	sign := "+"       // 正数加号
@@ -121,6 +121,42 @@ func TestParseToCents(t *testing.T) {
 	}
 }
 
+func TestParseSignedToCents(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    int64
+		expectError bool
+	}{
+		{"正数", "123.45", 12345, false},
+		{"正号", "+123.45", 12345, false},
+		{"负数", "-123.45", -12345, false},
+		{"尾部负号", "123.45-", -12345, false},
+		{"会计括号", "(1,234.50)", -123450, false},
+		{"负数四舍五入", "-0.005", -1, false},
+		{"空字符串", "", 0, true},
+		{"无效字符", "-abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseSignedToCents(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("ParseSignedToCents(%q) expected error, but got none", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("ParseSignedToCents(%q) unexpected error: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("ParseSignedToCents(%q) = %d, want %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCentsToYuanString(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// 日期时间处理
+// 数据库中统一以 "2006-01-02 15:04:05"（本地时间）格式保存与比较时间，
+// 这样 SQLite 的 date()/strftime() 能直接处理，字符串比较也与时间先后一致。
+const (
+	DateLayout     = "2006-01-02"
+	DateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// 可接受的日期时间输入格式（按顺序尝试）
+var dateTimeLayouts = []string{
+	DateTimeLayout,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006/1/2 15:04:05", // 月、日可以不补零（表格软件常见格式）
+	"2006/1/2 15:04",
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+}
+
+// 可接受的纯日期输入格式
+var dateLayouts = []string{
+	DateLayout,
+	"2006/1/2",
+	"2006-1-2",
+	"20060102",
+}
+
+// ParseDateTime 解析用户输入的日期或日期时间（本地时间）。
+// dateOnly 为 true 表示输入只包含日期，调用方可据此决定按"整天"处理。
+// 带时区的 RFC3339 输入会被转换为本地时间。
+func ParseDateTime(str string) (t time.Time, dateOnly bool, err error) {
+	s := strings.TrimSpace(str)
+	if s == "" {
+		return time.Time{}, false, ErrEmptyContent
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true, nil
+		}
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(time.Local), false, nil
+	}
+	return time.Time{}, false, ErrInvalidDate
+}
+
+// FormatDateTime 将时间格式化为数据库中使用的字符串
+func FormatDateTime(t time.Time) string {
+	return t.Format(DateTimeLayout)
+}
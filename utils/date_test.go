@@ -0,0 +1,46 @@
+package utils
+
+import "testing"
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		expected     string
+		expectedDate bool
+		expectError  bool
+	}{
+		{"日期", "2025-01-02", "2025-01-02 00:00:00", true, false},
+		{"斜杠日期", "2025/01/02", "2025-01-02 00:00:00", true, false},
+		{"紧凑日期", "20250102", "2025-01-02 00:00:00", true, false},
+		{"不补零日期", "2025/1/2", "2025-01-02 00:00:00", true, false},
+		{"不补零日期时间", "2025-1-2 8:30", "2025-01-02 08:30:00", false, false},
+		{"日期时间", "2025-01-02 08:30:15", "2025-01-02 08:30:15", false, false},
+		{"ISO日期时间", "2025-01-02T08:30", "2025-01-02 08:30:00", false, false},
+		{"首尾空格", " 2025-01-02 ", "2025-01-02 00:00:00", true, false},
+		{"空字符串", "", "", false, true},
+		{"无效日期", "2025-13-01", "", false, true},
+		{"无效字符", "yesterday", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, dateOnly, err := ParseDateTime(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("ParseDateTime(%q) expected error, but got none", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDateTime(%q) unexpected error: %v", tt.input, err)
+			}
+			if got := FormatDateTime(result); got != tt.expected {
+				t.Errorf("ParseDateTime(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+			if dateOnly != tt.expectedDate {
+				t.Errorf("ParseDateTime(%q) dateOnly = %v, want %v", tt.input, dateOnly, tt.expectedDate)
+			}
+		})
+	}
+}
@@ -78,6 +78,7 @@ const (
 
 	// 增：参数处理错误 15xx
 	CodeInvalidParameter = "1501"
+	CodeInvalidDate      = "1502"
 
 	// 账单相关错误 16xx
 	CodeAmountInvalidFormat    = "1601"
@@ -85,6 +86,73 @@ const (
 	CodeAmountZero             = "1603"
 	CodeInvalidTransactionType = "1604"
 	CodeTransactionNotFound    = "1605"
+
+	// 账户相关错误 17xx
+	CodeAccountNotFound        = "1701"
+	CodeAccountArchived        = "1702"
+	CodeAccountInUse           = "1703"
+	CodeInvalidAccountKind     = "1704"
+	CodeTransferSameAccount    = "1705"
+	CodeTransferCurrencyDiffer = "1706"
+
+	// 周期账单相关错误 18xx
+	CodeRecurringRuleNotFound = "1801"
+	CodeInvalidFrequency      = "1802"
+	CodeRecurringScheduleUsed = "1803"
+
+	// 类别相关错误 19xx
+	CodeCategoryNotFound     = "1901"
+	CodeCategoryCycle        = "1902"
+	CodeCategoryKindMismatch = "1903"
+	CodeInvalidCategoryKind  = "1904"
+	CodeCategoryExists       = "1905"
+
+	// 预算相关错误 20xx
+	CodeBudgetNotFound      = "2001"
+	CodeBudgetExists        = "2002"
+	CodeInvalidBudgetPeriod = "2003"
+
+	// 导入导出相关错误 21xx
+	CodeImportInvalidFile  = "2101"
+	CodeImportInvalidRows  = "2102"
+	CodeImportTooManyRows  = "2103"
+	CodeImportNoColumn     = "2104"
+	CodeImportFileTooLarge = "2105"
+
+	// 备份恢复相关错误 22xx
+	CodeBackupInvalid     = "2201"
+	CodeBackupUnsupported = "2202"
+
+	// 自动分类规则相关错误 23xx
+	CodeCategoryRuleNotFound = "2301"
+	CodeInvalidCategoryRule  = "2302"
+
+	// 标签相关错误 24xx
+	CodeTagNotFound    = "2401"
+	CodeTagExists      = "2402"
+	CodeInvalidTagName = "2403"
+
+	// 拆分账单相关错误 25xx
+	CodeInvalidSplit        = "2501"
+	CodeSplitAmountMismatch = "2502"
+
+	// 商户相关错误 26xx
+	CodePayeeNotFound = "2601"
+	CodePayeeExists   = "2602"
+
+	// 附件相关错误 27xx
+	CodeAttachmentNotFound     = "2701"
+	CodeAttachmentTooLarge     = "2702"
+	CodeAttachmentTypeRejected = "2703"
+	CodeTooManyAttachments     = "2704"
+
+	// 变更历史相关错误 28xx
+	CodeHistoryNotFound      = "2801"
+	CodeHistoryNotRevertible = "2802"
+
+	// 批量操作相关错误 29xx
+	CodeInvalidBulkOperation = "2901"
+	CodeBulkTooManyTargets   = "2902"
 )
 
 // 预定义错误(错误码 错误消息)
@@ -137,6 +205,7 @@ var (
 // 参数处理相关
 var (
 	ErrInvalidParameter = &Error{Code: CodeInvalidParameter, Message: "参数错误"}
+	ErrInvalidDate      = &Error{Code: CodeInvalidDate, Message: "日期格式错误"}
 )
 
 // 账单相关
@@ -147,3 +216,96 @@ var (
 	ErrInvalidTransactionType = &Error{Code: CodeInvalidTransactionType, Message: "无效的账单类型"}
 	ErrTransactionNotFound    = &Error{Code: CodeTransactionNotFound, Message: "账单不存在"}
 )
+
+// 账户相关
+var (
+	ErrAccountNotFound        = &Error{Code: CodeAccountNotFound, Message: "账户不存在"}
+	ErrAccountArchived        = &Error{Code: CodeAccountArchived, Message: "账户已归档"}
+	ErrAccountInUse           = &Error{Code: CodeAccountInUse, Message: "账户下仍有账单，请先归档或转移"}
+	ErrInvalidAccountKind     = &Error{Code: CodeInvalidAccountKind, Message: "无效的账户类型"}
+	ErrTransferSameAccount    = &Error{Code: CodeTransferSameAccount, Message: "转出与转入账户不能相同"}
+	ErrTransferCurrencyDiffer = &Error{Code: CodeTransferCurrencyDiffer, Message: "暂不支持不同币种账户之间转账"}
+)
+
+// 周期账单相关
+var (
+	ErrRecurringRuleNotFound = &Error{Code: CodeRecurringRuleNotFound, Message: "周期账单规则不存在"}
+	ErrInvalidFrequency      = &Error{Code: CodeInvalidFrequency, Message: "无效的重复频率"}
+	ErrRecurringScheduleUsed = &Error{Code: CodeRecurringScheduleUsed, Message: "规则已生成过账单，不能修改开始时间与重复频率，请新建规则"}
+)
+
+// 类别相关
+var (
+	ErrCategoryNotFound     = &Error{Code: CodeCategoryNotFound, Message: "类别不存在"}
+	ErrCategoryCycle        = &Error{Code: CodeCategoryCycle, Message: "不能把类别移动到它自身或它的子类别下"}
+	ErrCategoryKindMismatch = &Error{Code: CodeCategoryKindMismatch, Message: "该类别不能用于此收支类型的账单"}
+	ErrInvalidCategoryKind  = &Error{Code: CodeInvalidCategoryKind, Message: "无效的类别收支类型"}
+	ErrCategoryExists       = &Error{Code: CodeCategoryExists, Message: "类别名称已存在"}
+)
+
+// 预算相关
+var (
+	ErrBudgetNotFound      = &Error{Code: CodeBudgetNotFound, Message: "预算不存在"}
+	ErrBudgetExists        = &Error{Code: CodeBudgetExists, Message: "该类别在此周期已有预算"}
+	ErrInvalidBudgetPeriod = &Error{Code: CodeInvalidBudgetPeriod, Message: "无效的预算周期"}
+)
+
+// 导入导出相关
+var (
+	ErrImportInvalidFile  = &Error{Code: CodeImportInvalidFile, Message: "无法解析导入文件"}
+	ErrImportInvalidRows  = &Error{Code: CodeImportInvalidRows, Message: "导入数据有误，未导入任何记录"}
+	ErrImportTooManyRows  = &Error{Code: CodeImportTooManyRows, Message: "导入记录过多"}
+	ErrImportNoColumn     = &Error{Code: CodeImportNoColumn, Message: "找不到导入所需的列，请检查列映射"}
+	ErrImportFileTooLarge = &Error{Code: CodeImportFileTooLarge, Message: "导入文件过大"}
+)
+
+// 备份恢复相关
+var (
+	ErrBackupInvalid     = &Error{Code: CodeBackupInvalid, Message: "备份文件无效或已损坏"}
+	ErrBackupUnsupported = &Error{Code: CodeBackupUnsupported, Message: "备份文件来自更新的版本，请先升级服务"}
+)
+
+// 自动分类规则相关
+var (
+	ErrCategoryRuleNotFound = &Error{Code: CodeCategoryRuleNotFound, Message: "自动分类规则不存在"}
+	ErrInvalidCategoryRule  = &Error{Code: CodeInvalidCategoryRule, Message: "规则至少需要一个有效的条件"}
+)
+
+// 标签相关
+var (
+	ErrTagNotFound    = &Error{Code: CodeTagNotFound, Message: "标签不存在"}
+	ErrTagExists      = &Error{Code: CodeTagExists, Message: "标签名称已存在"}
+	ErrInvalidTagName = &Error{Code: CodeInvalidTagName, Message: "标签名称不能为空，且不能包含逗号"}
+)
+
+// 拆分账单相关
+var (
+	ErrInvalidSplit        = &Error{Code: CodeInvalidSplit, Message: "拆分至少需要两行，每行都要有类别和大于 0 的金额"}
+	ErrSplitAmountMismatch = &Error{Code: CodeSplitAmountMismatch, Message: "拆分各行金额之和与账单金额不一致"}
+)
+
+// 商户相关
+var (
+	ErrPayeeNotFound = &Error{Code: CodePayeeNotFound, Message: "商户不存在"}
+	ErrPayeeExists   = &Error{Code: CodePayeeExists, Message: "商户名称或别名已被使用"}
+)
+
+// 附件相关
+var (
+	ErrAttachmentNotFound     = &Error{Code: CodeAttachmentNotFound, Message: "附件不存在"}
+	ErrAttachmentTooLarge     = &Error{Code: CodeAttachmentTooLarge, Message: "附件文件过大"}
+	ErrAttachmentTypeRejected = &Error{Code: CodeAttachmentTypeRejected, Message: "只能上传 JPEG、PNG、GIF、WebP 图片或 PDF 文件"}
+	ErrTooManyAttachments     = &Error{Code: CodeTooManyAttachments, Message: "该账单的附件数量已达上限"}
+)
+
+// 变更历史相关
+var (
+	ErrHistoryNotFound      = &Error{Code: CodeHistoryNotFound, Message: "历史记录不存在"}
+	ErrHistoryNotRevertible = &Error{Code: CodeHistoryNotRevertible, Message: "不能还原到该历史记录"}
+)
+
+// 批量操作相关
+var (
+	ErrInvalidBulkOperation = &Error{Code: CodeInvalidBulkOperation, Message: "无效的批量操作"}
+	ErrBulkTooManyTargets   = &Error{Code: CodeBulkTooManyTargets, Message: "一次批量操作的账单过多，请缩小范围"}
+)
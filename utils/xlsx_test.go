@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for i, expected := range tests {
+		if got := xlsxColumnName(i); got != expected {
+			t.Errorf("xlsxColumnName(%d) = %q, want %q", i, got, expected)
+		}
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := NewXLSXWriter(&buf, "账单")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.WriteRow("日期", "金额"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.WriteRow("2025-01-02", XLSXNumber("-12.50"), int64(-1250), "<a&b>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a valid zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `name="账单"`) {
+		t.Errorf("sheet name not written: %s", files["xl/workbook.xml"])
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">日期</t></is></c>`,
+		`<c r="B2"><v>-12.50</v></c>`,
+		`<c r="C2"><v>-1250</v></c>`,
+		`&lt;a&amp;b&gt;`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %q", want)
+		}
+	}
+}
@@ -29,6 +29,7 @@ func SessionMiddleware(sessionManager *services.DBSessionManager) gin.HandlerFun
 		// 将会话信息存入上下文
 		c.Set("userID", userID)
 		c.Set("username", username)
+		c.Set("sessionID", sessionID)
 		c.Next()
 	}
 }
@@ -143,6 +143,183 @@ func HandleError(c *gin.Context, err error) {
 				"success": false,
 				"error":   "账单不存在",
 			})
+		case utils.CodeAmountInvalidFormat, utils.CodeAmountTooLarge, utils.CodeAmountZero,
+			utils.CodeInvalidTransactionType:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 账户相关 17xx
+		case utils.CodeAccountNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "账户不存在",
+			})
+		case utils.CodeAccountArchived, utils.CodeInvalidAccountKind,
+			utils.CodeTransferSameAccount, utils.CodeTransferCurrencyDiffer:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeAccountInUse:
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 周期账单相关 18xx
+		case utils.CodeRecurringRuleNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeInvalidFrequency:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeRecurringScheduleUsed:
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 类别、预算相关 19xx、20xx
+		case utils.CodeCategoryNotFound, utils.CodeBudgetNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeCategoryCycle, utils.CodeCategoryKindMismatch, utils.CodeInvalidCategoryKind:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeCategoryExists, utils.CodeBudgetExists:
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeInvalidBudgetPeriod:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 导入导出相关 21xx
+		case utils.CodeImportInvalidFile, utils.CodeImportInvalidRows, utils.CodeImportTooManyRows,
+			utils.CodeImportNoColumn:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeImportFileTooLarge:
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 备份恢复相关 22xx
+		case utils.CodeBackupInvalid, utils.CodeBackupUnsupported:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 自动分类规则相关 23xx
+		case utils.CodeCategoryRuleNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeInvalidCategoryRule:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 标签相关 24xx
+		case utils.CodeTagNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeTagExists:
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeInvalidTagName:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 拆分账单相关 25xx
+		case utils.CodeInvalidSplit, utils.CodeSplitAmountMismatch:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 商户相关 26xx
+		case utils.CodePayeeNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodePayeeExists:
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 附件相关 27xx
+		case utils.CodeAttachmentNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeAttachmentTooLarge:
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeAttachmentTypeRejected:
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeTooManyAttachments:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 变更历史相关 28xx
+		case utils.CodeHistoryNotFound:
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeHistoryNotRevertible:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+
+		// 批量操作相关 29xx
+		case utils.CodeInvalidBulkOperation:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
+		case utils.CodeBulkTooManyTargets:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   appErr.Message,
+			})
 
 		// 参数处理相关 15xx
 		case utils.CodeInvalidParameter:
@@ -150,6 +327,11 @@ func HandleError(c *gin.Context, err error) {
 				"success": false,
 				"error":   "参数错误",
 			})
+		case utils.CodeInvalidDate:
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "日期格式错误",
+			})
 
 		// 默认情况
 		default: